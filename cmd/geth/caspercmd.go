@@ -0,0 +1,346 @@
+// Copyright 2021 The Cube Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbCasperCmd = cli.Command{
+		Name:  "casper",
+		Usage: "Inspect and repair the Casper FFG block statuses and attestation markers",
+		Subcommands: []cli.Command{
+			dbCasperListCmd,
+			dbCasperCheckCmd,
+			dbCasperResetCmd,
+			dbCasperExportCmd,
+			dbCasperImportCmd,
+		},
+	}
+	dbCasperListCmd = cli.Command{
+		Action:    utils.MigrateFlags(casperList),
+		Name:      "list",
+		Usage:     "List the block statuses in a range",
+		ArgsUsage: "<from> <to>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: "This command lists the justified and finalized block statuses in the given range, with whether they match the canonical chain.",
+	}
+	dbCasperCheckCmd = cli.Command{
+		Action: utils.MigrateFlags(casperCheck),
+		Name:   "check",
+		Usage:  "Check the block statuses and markers against the canonical chain",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: `This command checks that all the block statuses point to canonical blocks,
+and that the last block status, last finalized and last attested markers are
+consistent with them. The inconsistent statuses can be dropped by 'geth db casper reset'.`,
+	}
+	dbCasperResetCmd = cli.Command{
+		Action:    utils.MigrateFlags(casperReset),
+		Name:      "reset",
+		Usage:     "Delete the block statuses above a block",
+		ArgsUsage: "<number>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: `This command deletes the block statuses above the given block, and lowers the
+last block status and last finalized markers to the remaining statuses. The last
+attested markers are left untouched, since lowering them may lead to double votes.`,
+	}
+	dbCasperExportCmd = cli.Command{
+		Action:    utils.MigrateFlags(casperExportAttested),
+		Name:      "export-attested",
+		Usage:     "Export the last attested markers of the local validators",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: "This command exports the last attested block number of each local validator into a JSON file.",
+	}
+	dbCasperImportCmd = cli.Command{
+		Action:    utils.MigrateFlags(casperImportAttested),
+		Name:      "import-attested",
+		Usage:     "Import the last attested markers of the local validators",
+		ArgsUsage: "<file>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+			casperForceFlag,
+		},
+		Description: `This command imports the last attested block numbers exported by 'geth db casper export-attested',
+e.g. when moving a validator to a new node. Markers are only raised, lowering them
+may lead to double votes and is refused unless --force is given.`,
+	}
+	casperForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Allow lowering the last attested markers",
+	}
+)
+
+// casperStatusName returns the readable name of a block status.
+func casperStatusName(status uint8) string {
+	switch status {
+	case types.BasJustified:
+		return "justified"
+	case types.BasFinalized:
+		return "finalized"
+	default:
+		return "unknown"
+	}
+}
+
+// casperHead returns the number of the head header, the block statuses above it
+// can't be checked against the canonical chain.
+func casperHead(db ethdb.Reader) uint64 {
+	if number := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db)); number != nil {
+		return *number
+	}
+	return 0
+}
+
+func casperList(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	from, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid 'from' block number: %v", err)
+	}
+	to, err := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid 'to' block number: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Number", "Hash", "Status", "Canonical"})
+	for n := from; n <= to; n++ {
+		status, hash := rawdb.ReadBlockStatusByNum(db, new(big.Int).SetUint64(n))
+		if status == types.BasUnknown {
+			continue
+		}
+		table.Append([]string{
+			strconv.FormatUint(n, 10),
+			hash.Hex(),
+			casperStatusName(status),
+			strconv.FormatBool(rawdb.ReadCanonicalHash(db, n) == hash),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func casperCheck(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	var (
+		head      = casperHead(db)
+		statuses  = rawdb.ReadAllBlockStatuses(db)
+		lowest    = uint64(math.MaxUint64) // Lowest inconsistent status
+		finalized = make(map[uint64]bool)  // Canonical statuses, and whether they're finalized
+	)
+	for _, bs := range statuses {
+		number := bs.BlockNumber.Uint64()
+		switch {
+		case number > head:
+			log.Error("Block status above the head", "number", number, "hash", bs.Hash, "status", casperStatusName(bs.Status), "head", head)
+		case rawdb.ReadCanonicalHash(db, number) != bs.Hash:
+			log.Error("Block status of non-canonical block", "number", number, "hash", bs.Hash, "status", casperStatusName(bs.Status))
+		default:
+			finalized[number] = bs.Status == types.BasFinalized
+			continue
+		}
+		if number < lowest {
+			lowest = number
+		}
+	}
+	issues := 0
+	if lowest != math.MaxUint64 {
+		issues++
+	}
+	last := rawdb.LastBlockStatusNumber(db).Uint64()
+	if _, ok := finalized[last]; !ok && last != 0 {
+		log.Error("Last block status marker without canonical status", "number", last)
+		issues++
+	}
+	lastFinalized := rawdb.LastFinalizedBlockNumber(db).Uint64()
+	if fin, ok := finalized[lastFinalized]; (!ok || !fin) && lastFinalized != 0 {
+		log.Error("Last finalized marker without canonical finalized status", "number", lastFinalized)
+		issues++
+	}
+	for val, number := range rawdb.ReadAllLastAttestNumbers(db) {
+		if number.Uint64() > head {
+			log.Warn("Last attested marker above the head, attestations are held until the chain passes it", "validator", val, "number", number, "head", head)
+		}
+	}
+	punishes := rawdb.ReadAllViolateCasperFFGPunish(db)
+	log.Info("Checked block statuses", "statuses", len(statuses), "head", head, "last", last, "finalized", lastFinalized, "punishes", len(punishes))
+
+	if issues > 0 {
+		reset := head
+		if lowest != math.MaxUint64 && lowest > 0 {
+			reset = lowest - 1
+		}
+		return fmt.Errorf("found %d inconsistencies, repair with 'geth db casper reset %d'", issues, reset)
+	}
+	return nil
+}
+
+func casperReset(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	number, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	var (
+		batch     = db.NewBatch()
+		deleted   int
+		last      uint64 // Highest remaining status
+		finalized uint64 // Highest remaining finalized status
+	)
+	for _, bs := range rawdb.ReadAllBlockStatuses(db) {
+		n := bs.BlockNumber.Uint64()
+		if n <= number {
+			last = n
+			if bs.Status == types.BasFinalized {
+				finalized = n
+			}
+			continue
+		}
+		rawdb.DeleteBlockStatus(batch, bs.BlockNumber)
+		deleted++
+	}
+	if rawdb.LastBlockStatusNumber(db).Uint64() > number {
+		rawdb.WriteLastBlockStatusNumber(batch, new(big.Int).SetUint64(last))
+	}
+	if rawdb.LastFinalizedBlockNumber(db).Uint64() > number {
+		rawdb.WriteLastFinalizedBlockNumber(batch, new(big.Int).SetUint64(finalized))
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Reset block statuses", "above", number, "deleted", deleted, "last", rawdb.LastBlockStatusNumber(db), "finalized", rawdb.LastFinalizedBlockNumber(db))
+	return nil
+}
+
+func casperExportAttested(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, true)
+	defer db.Close()
+
+	markers := make(map[common.Address]uint64)
+	for val, number := range rawdb.ReadAllLastAttestNumbers(db) {
+		markers[val] = number.Uint64()
+	}
+	blob, err := json.MarshalIndent(markers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(ctx.Args().Get(0), blob, 0600); err != nil {
+		return err
+	}
+	log.Info("Exported last attested markers", "file", ctx.Args().Get(0), "validators", len(markers))
+	return nil
+}
+
+func casperImportAttested(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	blob, err := ioutil.ReadFile(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	markers := make(map[common.Address]uint64)
+	if err := json.Unmarshal(blob, &markers); err != nil {
+		return fmt.Errorf("invalid markers file: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	force := ctx.Bool(casperForceFlag.Name)
+	for val, number := range markers {
+		if current := rawdb.ReadLastAttestNumber(db, val).Uint64(); number < current {
+			if !force {
+				return fmt.Errorf("refusing to lower the last attested marker of %x from %d to %d, use --%s to lower anyway", val, current, number, casperForceFlag.Name)
+			}
+			log.Warn("Lowering last attested marker, the validator may double vote!", "validator", val, "current", current, "number", number)
+		}
+	}
+	for val, number := range markers {
+		rawdb.WriteLastAttestNumber(db, val, new(big.Int).SetUint64(number))
+	}
+	log.Info("Imported last attested markers", "file", ctx.Args().Get(0), "validators", len(markers))
+	return nil
+}
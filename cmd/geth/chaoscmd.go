@@ -0,0 +1,313 @@
+// Copyright 2021 The Cube Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/chaos"
+	"github.com/ethereum/go-ethereum/consensus/chaos/systemcontract"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	chaosCommand = cli.Command{
+		Name:        "chaos",
+		Usage:       "A set of commands for the Chaos consensus engine",
+		Category:    "MISCELLANEOUS COMMANDS",
+		Description: "",
+		Subcommands: []cli.Command{
+			{
+				Name:  "snapshot",
+				Usage: "Maintain the stored Chaos voting snapshots",
+				Subcommands: []cli.Command{
+					chaosSnapshotInspectCmd,
+					chaosSnapshotVerifyCmd,
+					chaosSnapshotRebuildCmd,
+					chaosSnapshotPruneCmd,
+				},
+			},
+		},
+	}
+	chaosSnapshotInspectCmd = cli.Command{
+		Name:      "inspect",
+		Usage:     "Dump the validator set and the recent signers at a block",
+		ArgsUsage: "[<blockNum> | <blockHash>]",
+		Action:    utils.MigrateFlags(chaosSnapshotInspect),
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: `
+geth chaos snapshot inspect [<blockNum> | <blockHash>]
+will dump the Chaos voting snapshot at the given block, derived from the nearest
+stored snapshot if there's none stored at the block. The default block is the
+head header. The stored snapshots are listed by 'geth db inspect chaos'.
+`,
+	}
+	chaosSnapshotVerifyCmd = cli.Command{
+		Name:      "verify",
+		Usage:     "Re-derive the snapshots from the headers and compare with the stored ones",
+		ArgsUsage: "[<epochBlockNum>]",
+		Action:    utils.MigrateFlags(chaosSnapshotVerify),
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: `
+geth chaos snapshot verify [<epochBlockNum>]
+will replay the canonical headers from the given epoch block, the genesis by
+default, and compare the derived snapshots with the stored ones of the canonical
+blocks after it. The snapshot at the epoch block itself is derived from the
+headers of its epoch, without trusting any stored snapshot.
+`,
+	}
+	chaosSnapshotRebuildCmd = cli.Command{
+		Name:      "rebuild",
+		Usage:     "Regenerate the snapshots from a checkpoint",
+		ArgsUsage: "<epochBlockNum>",
+		Action:    utils.MigrateFlags(chaosSnapshotRebuild),
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: `
+geth chaos snapshot rebuild <epochBlockNum>
+will derive the snapshot at the given epoch block from the headers of its epoch,
+and replay the canonical headers up to the head to regenerate the snapshots after
+it. The stored snapshots of the canonical blocks after the epoch block are replaced.
+`,
+	}
+	chaosSnapshotPruneCmd = cli.Command{
+		Name:   "prune",
+		Usage:  "Delete the non-canonical and old snapshots",
+		Action: utils.MigrateFlags(chaosSnapshotPrune),
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+			chaosSnapshotKeepFlag,
+		},
+		Description: `
+geth chaos snapshot prune
+will delete the stored snapshots of the non-canonical blocks, the undecodable ones
+and the ones older than --keep blocks below the head. The newest canonical snapshot
+is always kept.
+`,
+	}
+	chaosSnapshotKeepFlag = cli.Uint64Flag{
+		Name:  "keep",
+		Usage: "Number of blocks below the head to keep the snapshots of",
+		Value: params.FullImmutabilityThreshold,
+	}
+)
+
+// makeChaosHeaderChain opens the header chain of the database with the Chaos
+// engine, it fails if the chain isn't a Chaos one.
+func makeChaosHeaderChain(ctx *cli.Context, stack *node.Node) (*core.HeaderChain, *chaos.Chaos, ethdb.Database) {
+	db := utils.MakeChainDatabase(ctx, stack, false)
+
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if config == nil || config.Chaos == nil {
+		utils.Fatalf("Database is not of a Chaos chain")
+	}
+	engine := chaos.New(config, db)
+	hc, err := core.NewHeaderChain(db, config, engine, func() bool { return false })
+	if err != nil {
+		utils.Fatalf("Failed to open header chain: %v", err)
+	}
+	return hc, engine, db
+}
+
+// parseEpochBlock parses the optional epoch block argument.
+func parseEpochBlock(ctx *cli.Context) (uint64, error) {
+	if ctx.NArg() > 1 {
+		return 0, fmt.Errorf("max 1 argument: %v", ctx.Command.ArgsUsage)
+	}
+	if ctx.NArg() == 0 {
+		return 0, nil
+	}
+	number, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number: %v", err)
+	}
+	return number, nil
+}
+
+func chaosSnapshotInspect(ctx *cli.Context) error {
+	if ctx.NArg() > 1 {
+		return fmt.Errorf("max 1 argument: %v", ctx.Command.ArgsUsage)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	hc, engine, db := makeChaosHeaderChain(ctx, stack)
+	defer db.Close()
+
+	header := hc.CurrentHeader()
+	if ctx.NArg() == 1 {
+		arg := ctx.Args().Get(0)
+		if strings.HasPrefix(arg, "0x") {
+			header = hc.GetHeaderByHash(common.HexToHash(arg))
+		} else if number, err := strconv.ParseUint(arg, 10, 64); err == nil {
+			header = hc.GetHeaderByNumber(number)
+		} else {
+			return fmt.Errorf("invalid block number or hash: %v", arg)
+		}
+	}
+	if header == nil {
+		return fmt.Errorf("block %v not found", ctx.Args().Get(0))
+	}
+	snap, stored, err := engine.InspectSnapshot(hc, header.Number.Uint64(), header.Hash())
+	if err != nil {
+		return err
+	}
+	validators := make([]common.Address, 0, len(snap.Validators))
+	for val := range snap.Validators {
+		validators = append(validators, val)
+	}
+	sort.Sort(systemcontract.AddrAscend(validators))
+
+	out, err := json.MarshalIndent(struct {
+		Number     uint64                    `json:"number"`
+		Hash       common.Hash               `json:"hash"`
+		Stored     bool                      `json:"stored"`
+		Validators []common.Address          `json:"validators"`
+		Recents    map[uint64]common.Address `json:"recents"`
+	}{snap.Number, snap.Hash, stored, validators, snap.Recents}, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func chaosSnapshotVerify(ctx *cli.Context) error {
+	from, err := parseEpochBlock(ctx)
+	if err != nil {
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	hc, engine, db := makeChaosHeaderChain(ctx, stack)
+	defer db.Close()
+
+	verified, mismatches, err := engine.VerifySnapshots(hc, from)
+	if err != nil {
+		log.Error("Failed to verify Chaos snapshots", "err", err)
+		return err
+	}
+	for _, m := range mismatches {
+		log.Error("Chaos snapshot mismatch", "number", m.Stored.Number, "hash", m.Stored.Hash,
+			"stored", len(m.Stored.Validators), "derived", len(m.Derived.Validators))
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d of %d snapshots mismatched, rebuild them with 'geth chaos snapshot rebuild'", len(mismatches), verified)
+	}
+	log.Info("Verified Chaos snapshots", "from", from, "verified", verified)
+	return nil
+}
+
+func chaosSnapshotRebuild(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	from, err := parseEpochBlock(ctx)
+	if err != nil {
+		return err
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	hc, engine, db := makeChaosHeaderChain(ctx, stack)
+	defer db.Close()
+
+	written, err := engine.RebuildSnapshots(hc, from)
+	if err != nil {
+		log.Error("Failed to rebuild Chaos snapshots", "err", err)
+		return err
+	}
+	log.Info("Rebuilt Chaos snapshots", "from", from, "head", hc.CurrentHeader().Number, "written", written)
+	return nil
+}
+
+func chaosSnapshotPrune(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	pruned, err := chaos.PruneSnapshots(db, ctx.Uint64(chaosSnapshotKeepFlag.Name))
+	if err != nil {
+		log.Error("Failed to prune Chaos snapshots", "err", err)
+		return err
+	}
+	log.Info("Pruned Chaos snapshots", "pruned", pruned)
+	return nil
+}
+
+// listChaosSnapshots prints the stored Chaos snapshots.
+func listChaosSnapshots(db ethdb.Database) error {
+	snaps, err := chaos.StoredSnapshots(db)
+	if err != nil {
+		return err
+	}
+	var total common.StorageSize
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Number", "Hash", "Canonical", "Validators", "Recents", "Size"})
+	for _, s := range snaps {
+		total += common.StorageSize(s.Size)
+		if s.Snapshot == nil {
+			table.Append([]string{"-", s.Hash.Hex(), "-", "-", "-", common.StorageSize(s.Size).String()})
+			continue
+		}
+		table.Append([]string{
+			strconv.FormatUint(s.Snapshot.Number, 10),
+			s.Hash.Hex(),
+			strconv.FormatBool(s.Canonical(db)),
+			strconv.Itoa(len(s.Snapshot.Validators)),
+			strconv.Itoa(len(s.Snapshot.Recents)),
+			common.StorageSize(s.Size).String(),
+		})
+	}
+	table.SetFooter([]string{"", "", "", "", "Total", total.String()})
+	table.Render()
+	return nil
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
@@ -62,6 +63,7 @@ Remove blockchain and state databases`,
 			dbInspectCmd,
 			dbStatCmd,
 			dbCompactCmd,
+			dbConvertCmd,
 			dbGetCmd,
 			dbDeleteCmd,
 			dbPutCmd,
@@ -69,6 +71,9 @@ Remove blockchain and state databases`,
 			dbDumpFreezerIndex,
 			dbImportCmd,
 			dbExportCmd,
+			dbSetHeadCmd,
+			dbCasperCmd,
+			dbLogIndexCmd,
 		},
 	}
 	dbInspectCmd = cli.Command{
@@ -83,7 +88,7 @@ Remove blockchain and state databases`,
 			utils.TestnetFlag,
 		},
 		Usage:       "Inspect the storage size for each type of data in the database",
-		Description: `This commands iterates the entire database. If the optional 'prefix' and 'start' arguments are provided, then the iteration is limited to the given subset of data.`,
+		Description: `This commands iterates the entire database. If the optional 'prefix' and 'start' arguments are provided, then the iteration is limited to the given subset of data. The 'prefix' can also be the name of a category with its own inspector, 'chaos' lists the Chaos snapshots.`,
 	}
 	dbStatCmd = cli.Command{
 		Action: utils.MigrateFlags(dbStats),
@@ -111,6 +116,25 @@ Remove blockchain and state databases`,
 		Description: `This command performs a database compaction. 
 WARNING: This operation may take a very long time to finish, and may cause database
 corruption if it is aborted during execution'!`,
+	}
+	dbConvertCmd = cli.Command{
+		Action: utils.MigrateFlags(dbConvert),
+		Name:   "convert",
+		Usage:  "Convert the chain database to another storage engine",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+			utils.CacheFlag,
+			utils.CacheDatabaseFlag,
+		},
+		Description: `This command copies the key-value store of the chain database into a new
+database of the storage engine given by --db.engine, then swaps the new database in.
+The freezer is kept as is, the original database is left next to the new one with a
+'.bak' suffix, to be removed once the node is found to run fine.`,
 	}
 	dbGetCmd = cli.Command{
 		Action:    utils.MigrateFlags(dbGet),
@@ -203,6 +227,25 @@ WARNING: This is a low-level operation which may cause database corruption!`,
 		},
 		Description: "Exports the specified chain data to an RLP encoded stream, optionally gzip-compressed.",
 	}
+	dbSetHeadCmd = cli.Command{
+		Action:    utils.MigrateFlags(dbSetHead),
+		Name:      "sethead",
+		Usage:     "Rewinds the chain head to a previous block",
+		ArgsUsage: "<number>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			forceFlag,
+		},
+		Description: `This command rewinds the local chain to the given block. Rewinding below
+the last finalized block is refused unless --force is given, in which case the
+finality markers above the new head are dropped too.`,
+	}
+	forceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Allow rewinding below the last finalized block",
+	}
 )
 
 func removeDB(ctx *cli.Context) error {
@@ -265,6 +308,11 @@ func confirmAndRemoveDB(database string, kind string) {
 	}
 }
 
+// inspectCategories are the database categories with their own inspectors.
+var inspectCategories = map[string]func(ethdb.Database) error{
+	"chaos": listChaosSnapshots,
+}
+
 func inspect(ctx *cli.Context) error {
 	var (
 		prefix []byte
@@ -273,6 +321,15 @@ func inspect(ctx *cli.Context) error {
 	if ctx.NArg() > 2 {
 		return fmt.Errorf("Max 2 arguments: %v", ctx.Command.ArgsUsage)
 	}
+	if inspector, ok := inspectCategories[ctx.Args().Get(0)]; ok {
+		stack, _ := makeConfigNode(ctx)
+		defer stack.Close()
+
+		db := utils.MakeChainDatabase(ctx, stack, true)
+		defer db.Close()
+
+		return inspector(db)
+	}
 	if ctx.NArg() >= 1 {
 		if d, err := hexutil.Decode(ctx.Args().Get(0)); err != nil {
 			return fmt.Errorf("failed to hex-decode 'prefix': %v", err)
@@ -340,6 +397,109 @@ func dbCompact(ctx *cli.Context) error {
 	return nil
 }
 
+// dbConvert copies the chain database into a database of another engine.
+func dbConvert(ctx *cli.Context) error {
+	engine := ctx.GlobalString(utils.DBEngineFlag.Name)
+	if engine == "" {
+		return errors.New("the target engine is required, use --db.engine")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	name := "chaindata"
+	if ctx.GlobalString(utils.SyncModeFlag.Name) == "light" {
+		name = "lightchaindata"
+	}
+	var (
+		src     = stack.ResolvePath(name)
+		dst     = src + "." + engine
+		cache   = ctx.GlobalInt(utils.CacheFlag.Name) * ctx.GlobalInt(utils.CacheDatabaseFlag.Name) / 100
+		handles = utils.MakeDatabaseHandles()
+	)
+	existing := rawdb.PreexistingDatabase(src)
+	switch existing {
+	case "":
+		return fmt.Errorf("no database found at %s", src)
+	case engine:
+		return fmt.Errorf("the database at %s is already a %s database", src, engine)
+	}
+	if common.FileExist(dst) {
+		return fmt.Errorf("conversion target %s already exists", dst)
+	}
+	srcDb, err := rawdb.Open(rawdb.OpenOptions{Type: existing, Directory: src, Cache: cache / 2, Handles: handles / 2, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer srcDb.Close()
+	dstDb, err := rawdb.Open(rawdb.OpenOptions{Type: engine, Directory: dst, Cache: cache / 2, Handles: handles / 2})
+	if err != nil {
+		return err
+	}
+	if err := copyKeyValues(srcDb, dstDb); err != nil {
+		dstDb.Close()
+		return err
+	}
+	if err := dstDb.Close(); err != nil {
+		return err
+	}
+	srcDb.Close()
+
+	// Move the freezer along, unless it's kept outside of the database
+	if !ctx.GlobalIsSet(utils.AncientFlag.Name) {
+		if ancient := filepath.Join(src, "ancient"); common.FileExist(ancient) {
+			if err := os.Rename(ancient, filepath.Join(dst, "ancient")); err != nil {
+				return err
+			}
+		}
+	}
+	backup := src + "." + existing + ".bak"
+	if err := os.Rename(src, backup); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, src); err != nil {
+		return err
+	}
+	log.Info("Database converted", "engine", engine, "path", src, "backup", backup)
+	return nil
+}
+
+// copyKeyValues copies all the entries of a key-value store into another one.
+func copyKeyValues(src ethdb.KeyValueStore, dst ethdb.KeyValueStore) error {
+	var (
+		it     = src.NewIterator(nil, nil)
+		batch  = dst.NewBatch()
+		count  int
+		start  = time.Now()
+		logged = time.Now()
+	)
+	defer it.Release()
+
+	for it.Next() {
+		if err := batch.Put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		count++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Converting database", "entries", count, "key", fmt.Sprintf("%#x", it.Key()), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Copied database entries", "entries", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 // dbGet shows the value of a given database key
 func dbGet(ctx *cli.Context) error {
 	if ctx.NArg() != 1 {
@@ -393,6 +553,35 @@ func dbDelete(ctx *cli.Context) error {
 	return nil
 }
 
+// dbSetHead rewinds the chain head, only crossing the finality if forced.
+func dbSetHead(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	number, err := strconv.ParseUint(ctx.Args().Get(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, _ := utils.MakeChain(ctx, stack)
+	defer chain.Stop()
+
+	// The chain is loaded with the finality already, so the rewind below it has
+	// to go through the forced path dropping the finality markers
+	if ctx.Bool(forceFlag.Name) {
+		err = chain.SetHeadForce(number)
+	} else if err = chain.SetHead(number); errors.Is(err, core.ErrFinalizedRewind) {
+		err = fmt.Errorf("%v, use --%s to rewind anyway", err, forceFlag.Name)
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("Rewound chain head", "number", chain.CurrentBlock().Number(), "hash", chain.CurrentBlock().Hash())
+	return nil
+}
+
 // dbPut overwrite a value in the database
 func dbPut(ctx *cli.Context) error {
 	if ctx.NArg() != 2 {
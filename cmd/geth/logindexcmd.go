@@ -0,0 +1,81 @@
+// Copyright 2021 The Cube Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbLogIndexCmd = cli.Command{
+		Name:  "logindex",
+		Usage: "Build the log index used for fast log filtering with --logindex",
+		Subcommands: []cli.Command{
+			dbLogIndexBuildCmd,
+			dbLogIndexRebuildCmd,
+		},
+	}
+	dbLogIndexBuildCmd = cli.Command{
+		Action: utils.MigrateFlags(logIndexBuild),
+		Name:   "build",
+		Usage:  "Index the logs of the chain, resuming from the last indexed section",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: `This command indexes the logs of the local chain by address and first topic,
+up to the confirmed head, instead of letting the node catch up in the background
+once started with --logindex.`,
+	}
+	dbLogIndexRebuildCmd = cli.Command{
+		Action: utils.MigrateFlags(logIndexRebuild),
+		Name:   "rebuild",
+		Usage:  "Delete the log index and index the logs of the chain from scratch",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.SyncModeFlag,
+			utils.MainnetFlag,
+			utils.TestnetFlag,
+		},
+		Description: "This command deletes the log index, e.g. if it's corrupted, and indexes the logs of the local chain again.",
+	}
+)
+
+func logIndexBuild(ctx *cli.Context) error {
+	return buildLogIndex(ctx, false)
+}
+
+func logIndexRebuild(ctx *cli.Context) error {
+	return buildLogIndex(ctx, true)
+}
+
+// buildLogIndex indexes the logs of the local chain, deleting the log index first
+// if rebuild is set.
+func buildLogIndex(ctx *cli.Context, rebuild bool) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	return core.BuildLogIndex(db, params.LogIndexBlocks, params.BloomConfirms, rebuild)
+}
@@ -66,6 +66,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
 	"gopkg.in/urfave/cli.v1"
@@ -117,6 +118,10 @@ var (
 		Name:  "datadir.ancient",
 		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
 	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Backing database implementation to use ('leveldb' or 'pebble', default = the pre-existing one, or leveldb)",
+	}
 	MinFreeDiskSpaceFlag = DirectoryFlag{
 		Name:  "datadir.minfreedisk",
 		Usage: "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -223,6 +228,10 @@ var (
 		Usage: "Number of recent blocks to maintain transactions index for (default = about one year, 0 = entire chain)",
 		Value: ethconfig.Defaults.TxLookupLimit,
 	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Maintain an index of the logs by address and first topic for fast log filtering over long ranges",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -231,6 +240,14 @@ var (
 		Name:  "whitelist",
 		Usage: "Comma separated block number-to-hash mappings to enforce (<number>=<hash>)",
 	}
+	CheckpointFinalizedFlag = cli.StringFlag{
+		Name:  "checkpoint.finalized",
+		Usage: "Hash of a trusted finalized epoch block to sync a fresh Chaos node from",
+	}
+	CheckpointProofFlag = cli.StringFlag{
+		Name:  "checkpoint.proof",
+		Usage: "File of the proof of the trusted finalized checkpoint (exported by admin.exportCheckpoint)",
+	}
 	BloomFilterSizeFlag = cli.Uint64Flag{
 		Name:  "bloomfilter.size",
 		Usage: "Megabytes of memory allocated to bloom-filter for pruning",
@@ -330,6 +347,19 @@ var (
 		Name:  "txpool.nolocals",
 		Usage: "Disables price exemptions for locally submitted transactions",
 	}
+	TxPoolNoPriorityFlag = cli.BoolFlag{
+		Name:  "txpool.nopriority",
+		Usage: "Disables the priority lane for validator operations on system contracts",
+	}
+	TxPoolPrivateFallbackFlag = cli.Uint64Flag{
+		Name:  "txpool.privatefallback",
+		Usage: "Number of blocks to wait before broadcasting a private transaction publicly",
+		Value: ethconfig.Defaults.PrivateTxFallback,
+	}
+	TxPoolPrivateRelaysFlag = cli.StringFlag{
+		Name:  "txpool.privaterelays",
+		Usage: "Comma separated validator-to-RPC endpoint mappings to offer private transactions to (<validator>=<url>)",
+	}
 	TxPoolJournalFlag = cli.StringFlag{
 		Name:  "txpool.journal",
 		Usage: "Disk journal for local transaction to survive node restarts",
@@ -465,6 +495,11 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerPriorityGasFlag = cli.Uint64Flag{
+		Name:  "miner.prioritygas",
+		Usage: "Percentage of block gas reserved for validator operations on system contracts (0 = disabled)",
+		Value: ethconfig.Defaults.Miner.PriorityGas,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -504,6 +539,21 @@ var (
 		Usage: "Sets a cap on transaction fee (in ether) that can be sent via the RPC APIs (0 = no cap)",
 		Value: ethconfig.Defaults.RPCTxFeeCap,
 	}
+	HealthMinPeersFlag = cli.IntFlag{
+		Name:  "health.minpeers",
+		Usage: "Minimum number of peers of a healthy node on the /health endpoint (0 = no minimum)",
+		Value: ethconfig.Defaults.HealthMinPeers,
+	}
+	HealthMaxHeadAgeFlag = cli.DurationFlag{
+		Name:  "health.maxheadage",
+		Usage: "Maximum age of the head block of a healthy node on the /health endpoint (0 = no maximum)",
+		Value: ethconfig.Defaults.HealthMaxHeadAge,
+	}
+	HealthMaxFinalityLagFlag = cli.Uint64Flag{
+		Name:  "health.maxfinalitylag",
+		Usage: "Maximum number of blocks of the head above the last finalized block of a healthy node on the /health endpoint (0 = no maximum)",
+		Value: ethconfig.Defaults.HealthMaxFinalityLag,
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -615,6 +665,18 @@ var (
 		Name:  "rpc.allow-unprotected-txs",
 		Usage: "Allow for unprotected (non EIP155 signed) transactions to be submitted via RPC",
 	}
+	RPCRateLimitFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit",
+		Usage: "Comma separated HTTP/WS limits per client, as method|namespace|*=rate[:burst[:concurrency[:maxresponse]]] (e.g. eth_getLogs=5:10:2,debug=1::1,*=100)",
+	}
+	RPCRateLimitAPIKeysFlag = cli.StringFlag{
+		Name:  "rpc.ratelimit.apikeys",
+		Usage: "Comma separated API keys which, sent in the X-API-Key header, identify the clients of the rate limits instead of their IP address",
+	}
+	RPCRateLimitTrustProxyFlag = cli.BoolFlag{
+		Name:  "rpc.ratelimit.trustproxy",
+		Usage: "Take the IP address of the rate limited clients from the X-Forwarded-For header",
+	}
 
 	// Network Settings
 	MaxPeersFlag = cli.IntFlag{
@@ -948,6 +1010,30 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setRateLimits configures the rate limits of the HTTP and WS RPC servers from
+// the set command line flags.
+func setRateLimits(ctx *cli.Context, cfg *node.Config) {
+	if !ctx.GlobalIsSet(RPCRateLimitFlag.Name) && !ctx.GlobalIsSet(RPCRateLimitAPIKeysFlag.Name) && !ctx.GlobalIsSet(RPCRateLimitTrustProxyFlag.Name) {
+		return
+	}
+	if cfg.RPCRateLimits == nil {
+		cfg.RPCRateLimits = new(rpc.RateLimits)
+	}
+	if ctx.GlobalIsSet(RPCRateLimitFlag.Name) {
+		limits, err := rpc.ParseRateLimits(ctx.GlobalString(RPCRateLimitFlag.Name))
+		if err != nil {
+			Fatalf("Invalid --%s: %v", RPCRateLimitFlag.Name, err)
+		}
+		cfg.RPCRateLimits.Limits = limits
+	}
+	if ctx.GlobalIsSet(RPCRateLimitAPIKeysFlag.Name) {
+		cfg.RPCRateLimits.APIKeys = SplitAndTrim(ctx.GlobalString(RPCRateLimitAPIKeysFlag.Name))
+	}
+	if ctx.GlobalIsSet(RPCRateLimitTrustProxyFlag.Name) {
+		cfg.RPCRateLimits.TrustProxy = ctx.GlobalBool(RPCRateLimitTrustProxyFlag.Name)
+	}
+}
+
 // setGraphQL creates the GraphQL listener interface string from the set
 // command line flags, returning empty if the GraphQL endpoint is disabled.
 func setGraphQL(ctx *cli.Context, cfg *node.Config) {
@@ -1191,10 +1277,21 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
+	setRateLimits(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		engine := ctx.GlobalString(DBEngineFlag.Name)
+		if engine != rawdb.DBLeveldb && engine != rawdb.DBPebble {
+			Fatalf("Invalid choice for db.engine '%s', allowed 'leveldb' or 'pebble'", engine)
+		}
+		if engine == rawdb.DBPebble && !rawdb.PebbleEnabled {
+			Fatalf("The pebble db.engine is not supported on this platform")
+		}
+		cfg.DBEngine = engine
+	}
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
@@ -1284,6 +1381,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolNoLocalsFlag.Name) {
 		cfg.NoLocals = ctx.GlobalBool(TxPoolNoLocalsFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolNoPriorityFlag.Name) {
+		cfg.NoPriority = ctx.GlobalBool(TxPoolNoPriorityFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolJournalFlag.Name) {
 		cfg.Journal = ctx.GlobalString(TxPoolJournalFlag.Name)
 	}
@@ -1360,6 +1460,12 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerifyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerifyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerPriorityGasFlag.Name) {
+		cfg.PriorityGas = ctx.GlobalUint64(MinerPriorityGasFlag.Name)
+		if cfg.PriorityGas > 100 {
+			Fatalf("Option %q must be in range [0, 100]", MinerPriorityGasFlag.Name)
+		}
+	}
 	if ctx.GlobalIsSet(LegacyMinerGasTargetFlag.Name) {
 		log.Warn("The generic --miner.gastarget flag is deprecated and will be removed in the future!")
 	}
@@ -1388,6 +1494,37 @@ func setWhitelist(ctx *cli.Context, cfg *ethconfig.Config) {
 	}
 }
 
+func setPrivateTxRelays(ctx *cli.Context, cfg *ethconfig.Config) {
+	relays := ctx.GlobalString(TxPoolPrivateRelaysFlag.Name)
+	if relays == "" {
+		return
+	}
+	cfg.PrivateTxRelays = make(map[common.Address]string)
+	for _, entry := range strings.Split(relays, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			Fatalf("Invalid private relay entry: %s", entry)
+		}
+		if !common.IsHexAddress(parts[0]) {
+			Fatalf("Invalid private relay validator: %s", parts[0])
+		}
+		cfg.PrivateTxRelays[common.HexToAddress(parts[0])] = parts[1]
+	}
+}
+
+func setFinalizedCheckpoint(ctx *cli.Context, cfg *ethconfig.Config) {
+	if !ctx.GlobalIsSet(CheckpointFinalizedFlag.Name) {
+		return
+	}
+	if err := cfg.FinalizedCheckpoint.UnmarshalText([]byte(ctx.GlobalString(CheckpointFinalizedFlag.Name))); err != nil {
+		Fatalf("Invalid checkpoint hash %s: %v", ctx.GlobalString(CheckpointFinalizedFlag.Name), err)
+	}
+	if !ctx.GlobalIsSet(CheckpointProofFlag.Name) {
+		Fatalf("Option %q requires %q", CheckpointFinalizedFlag.Name, CheckpointProofFlag.Name)
+	}
+	cfg.FinalizedCheckpointProof = ctx.GlobalString(CheckpointProofFlag.Name)
+}
+
 // CheckExclusive verifies that only a single instance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1449,9 +1586,14 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setEtherbase(ctx, ks, cfg)
 	setGPO(ctx, &cfg.GPO, ctx.GlobalString(SyncModeFlag.Name) == "light")
 	setTxPool(ctx, &cfg.TxPool)
+	if ctx.GlobalIsSet(TxPoolPrivateFallbackFlag.Name) {
+		cfg.PrivateTxFallback = ctx.GlobalUint64(TxPoolPrivateFallbackFlag.Name)
+	}
+	setPrivateTxRelays(ctx, cfg)
 	setEthash(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
 	setWhitelist(ctx, cfg)
+	setFinalizedCheckpoint(ctx, cfg)
 	setLes(ctx, cfg)
 
 	// Cap the cache allowance and tune the garbage collector
@@ -1506,6 +1648,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1552,6 +1697,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.GlobalIsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.GlobalFloat64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.GlobalIsSet(HealthMinPeersFlag.Name) {
+		cfg.HealthMinPeers = ctx.GlobalInt(HealthMinPeersFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxHeadAgeFlag.Name) {
+		cfg.HealthMaxHeadAge = ctx.GlobalDuration(HealthMaxHeadAgeFlag.Name)
+	}
+	if ctx.GlobalIsSet(HealthMaxFinalityLagFlag.Name) {
+		cfg.HealthMaxFinalityLag = ctx.GlobalUint64(HealthMaxFinalityLagFlag.Name)
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
@@ -1580,6 +1734,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 			cfg.NetworkId = 1337
 		}
 		cfg.SyncMode = downloader.FullSync
+		// A developer chain runs without peers, sealing blocks on demand
+		if !ctx.GlobalIsSet(HealthMinPeersFlag.Name) {
+			cfg.HealthMinPeers = 0
+		}
+		if !ctx.GlobalIsSet(HealthMaxHeadAgeFlag.Name) {
+			cfg.HealthMaxHeadAge = 0
+		}
 		// Create new developer account or reuse existing one
 		var (
 			developer  accounts.Account
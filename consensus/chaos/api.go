@@ -18,9 +18,12 @@ package chaos
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/chaos/systemcontract"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -145,3 +148,141 @@ func (api *API) Status() (*status, error) {
 		NumBlocks:     numBlocks,
 	}, nil
 }
+
+type nextValidators struct {
+	Number           uint64                    `json:"number"`           // Current head number
+	NextEpoch        uint64                    `json:"nextEpoch"`        // Number of the next checkpoint block
+	BlocksUntilEpoch uint64                    `json:"blocksUntilEpoch"` // Blocks left until the next checkpoint block
+	Current          []common.Address          `json:"current"`          // Validators sealing blocks now
+	Pending          []common.Address          `json:"pending"`          // Validators sealed in the last checkpoint, active after next checkpoint
+	PendingEffective uint64                    `json:"pendingEffective"` // First block sealed by the pending validators
+	Next             []common.Address          `json:"next"`             // Forecast of validators to be sealed in the next checkpoint
+	NextEffective    uint64                    `json:"nextEffective"`    // First block sealed by the forecast validators
+	Joining          []common.Address          `json:"joining"`          // Validators in the forecast but not in the pending set
+	Leaving          []common.Address          `json:"leaving"`          // Validators in the pending set but not in the forecast
+	FirstInturn      map[common.Address]uint64 `json:"firstInturn"`      // First in-turn block of each joining validator
+}
+
+// GetNextValidators evaluates the top validators against the latest state, which
+// is what the next checkpoint block will carry, and reports when each validator
+// set takes effect.
+//
+// Note that the validators sealed in a checkpoint block are only used after the
+// following checkpoint, so there is always a pending set besides the forecast.
+func (api *API) GetNextValidators() (*nextValidators, error) {
+	header := api.chain.CurrentHeader()
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	var (
+		number     = header.Number.Uint64()
+		epoch      = api.chaos.config.Epoch
+		nextEpoch  = (number/epoch + 1) * epoch
+		checkpoint = api.chain.GetHeaderByNumber(nextEpoch - epoch)
+	)
+	if checkpoint == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := api.chaos.snapshot(api.chain, number, header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if api.chaos.stateFn == nil {
+		return nil, errStateUnavailable
+	}
+	statedb, err := api.chaos.stateFn(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	next, err := systemcontract.GetTopValidators(&systemcontract.CallContext{
+		Statedb:      statedb,
+		Header:       header,
+		ChainContext: newChainContext(api.chain, api.chaos),
+		ChainConfig:  api.chaos.chainConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pending := core.CheckpointValidators(checkpoint)
+	result := &nextValidators{
+		Number:           number,
+		NextEpoch:        nextEpoch,
+		BlocksUntilEpoch: nextEpoch - number,
+		Current:          snap.validators(),
+		Pending:          pending,
+		PendingEffective: nextEpoch + 1,
+		Next:             next,
+		NextEffective:    nextEpoch + epoch + 1,
+		Joining:          core.DiffValidators(next, pending),
+		Leaving:          core.DiffValidators(pending, next),
+		FirstInturn:      make(map[common.Address]uint64),
+	}
+	continuousInturn := api.chaos.chainConfig.ChaosContinuousInturn(header.Number)
+	for _, val := range result.Joining {
+		result.FirstInturn[val] = firstInturnBlock(next, val, result.NextEffective, continuousInturn)
+	}
+	return result, nil
+}
+
+// maxGasBudgets is the max number of upcoming blocks returned by GetGasBudgets
+const maxGasBudgets = 256
+
+type gasBudget struct {
+	Number    uint64         `json:"number"`    // Number of the upcoming block
+	Validator common.Address `json:"validator"` // In-turn validator of the block
+	GasLimit  uint64         `json:"gasLimit"`  // Gas limit of the block, assumed to be the same as the head
+	Budget    uint64         `json:"budget"`    // Max gas that can be used by the transactions in the block
+}
+
+// GetGasBudgets returns the gas budgets of the upcoming blocks, 16 blocks by default.
+// The in-turn validators are based on the current snapshot, so they are only
+// accurate until the next checkpoint takes effect.
+func (api *API) GetGasBudgets(count *uint64) ([]*gasBudget, error) {
+	n := uint64(16)
+	if count != nil {
+		n = *count
+	}
+	if n > maxGasBudgets {
+		return nil, fmt.Errorf("too many blocks requested, max %d", maxGasBudgets)
+	}
+	header := api.chain.CurrentHeader()
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	snap, err := api.chaos.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	budgets := make([]*gasBudget, 0, n)
+	for i := uint64(1); i <= n; i++ {
+		number := header.Number.Uint64() + i
+		budgets = append(budgets, &gasBudget{
+			Number:    number,
+			Validator: snap.inturnValidator(number),
+			GasLimit:  header.GasLimit,
+			Budget:    api.chaos.GasBudget(new(big.Int).SetUint64(number), header.GasLimit),
+		})
+	}
+	return budgets, nil
+}
+
+// firstInturnBlock returns the first block no earlier than `from` that the validator
+// is in-turn to seal with the given ascending ordered validators.
+func firstInturnBlock(validators []common.Address, validator common.Address, from uint64, continuousInturn uint64) uint64 {
+	offset := 0
+	for offset < len(validators) && validators[offset] != validator {
+		offset++
+	}
+	if offset == len(validators) {
+		return 0
+	}
+	round := uint64(len(validators)) * continuousInturn
+	start := from/round*round + uint64(offset)*continuousInturn
+	if start+continuousInturn <= from {
+		start += round
+	}
+	if start < from {
+		return from
+	}
+	return start
+}
@@ -0,0 +1,204 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package chaos
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	minBackupStep = 100 * time.Millisecond // Default minimum delay between two backup validators
+	maxBackupStep = 500 * time.Millisecond // Default maximum delay between two backup validators
+	latencyFactor = 2                      // Default multiple of the propagation latency between two backup validators
+
+	latencyImpact   = 0.1 // Weight of a new sample in the propagation latency estimate
+	maxLatencyShare = 0.5 // Share of the block period a propagation latency sample is capped at
+	recentSiblings  = 64  // Number of recent parents to track the sibling blocks of
+)
+
+var (
+	propagationTimer = metrics.NewRegisteredTimer("chaos/propagation/latency", nil)
+	backupDelayTimer = metrics.NewRegisteredTimer("chaos/backup/delay", nil)
+
+	siblingMeter       = metrics.NewRegisteredMeter("chaos/fork/siblings", nil)
+	inturnSiblingMeter = metrics.NewRegisteredMeter("chaos/fork/inturn", nil)
+	backupSiblingMeter = metrics.NewRegisteredMeter("chaos/fork/backup", nil)
+)
+
+// backupRank returns the rank of a validator in the backup order of a block, the
+// order in which the out-of-turn validators seal it if the in-turn one doesn't.
+// The backups follow the in-turn validator in the address order, skipping the
+// ones which signed recently. The first backup is of rank 1, zero is returned if
+// the validator can't back up the block.
+func (s *Snapshot) backupRank(number uint64, validator common.Address) int {
+	var (
+		validators = s.validators()
+		inturn     = s.inturnValidator(number)
+		offset     int
+	)
+	for offset < len(validators) && validators[offset] != inturn {
+		offset++
+	}
+	rank := 0
+	for i := 1; i < len(validators); i++ {
+		backup := validators[(offset+i)%len(validators)]
+		if s.SignedRecently(number, backup) {
+			continue
+		}
+		rank++
+		if backup == validator {
+			return rank
+		}
+	}
+	return 0
+}
+
+// latencyTracker estimates the block propagation latency of the network, from
+// the arrival times of the in-turn blocks, which are sealed at their timestamp.
+type latencyTracker struct {
+	latency time.Duration // Moving average of the propagation latency
+	samples uint64        // Number of latencies measured
+	lock    sync.Mutex
+}
+
+// update adds a measured propagation latency to the estimate.
+func (t *latencyTracker) update(latency time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.samples == 0 {
+		t.latency = latency
+	} else {
+		t.latency = time.Duration((1-latencyImpact)*float64(t.latency) + latencyImpact*float64(latency))
+	}
+	t.samples++
+	propagationTimer.Update(latency)
+}
+
+// estimate returns the estimated propagation latency, and whether any latency
+// was measured yet.
+func (t *latencyTracker) estimate() (time.Duration, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.latency, t.samples > 0
+}
+
+// siblingTracker detects the sibling blocks, the blocks sealed on the same
+// parent, to measure the forks caused by the out-of-turn sealing.
+type siblingTracker struct {
+	children *lru.Cache // Recent parent hashes to the children seen of them
+	lock     sync.Mutex
+}
+
+func newSiblingTracker() *siblingTracker {
+	children, _ := lru.New(recentSiblings)
+	return &siblingTracker{children: children}
+}
+
+// track records a block header, and meters it if it's a sibling of the blocks
+// already seen.
+func (t *siblingTracker) track(header *types.Header) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var (
+		hash     = header.Hash()
+		siblings []*types.Header
+	)
+	if cached, ok := t.children.Get(header.ParentHash); ok {
+		siblings = cached.([]*types.Header)
+	}
+	for _, sibling := range siblings {
+		if sibling.Hash() == hash {
+			return
+		}
+	}
+	if len(siblings) > 0 {
+		siblingMeter.Mark(1)
+
+		inturn := header.Difficulty.Cmp(diffInTurn) == 0
+		for _, sibling := range siblings {
+			inturn = inturn || sibling.Difficulty.Cmp(diffInTurn) == 0
+		}
+		if inturn {
+			inturnSiblingMeter.Mark(1)
+		} else {
+			backupSiblingMeter.Mark(1)
+		}
+	}
+	t.children.Add(header.ParentHash, append(siblings, header))
+}
+
+// observeBlock measures the propagation latency of an in-turn block received
+// from the network, and tracks its siblings. The single headers are verified for
+// the synced, refetched and reimported blocks too, so only the blocks received
+// within a period of their timestamp are measured, and the samples are capped at
+// a share of the period to keep a slow network from stalling the backups.
+//
+// Only the blocks sealed on schedule are measured: a late sealer stamps its block
+// with the current time truncated to the second and seals it right away, so the
+// arrival time of such a block includes up to a second of the sealer's lateness.
+func (c *Chaos) observeBlock(header *types.Header, parent *types.Header) {
+	c.siblings.track(header)
+
+	if header.Difficulty.Cmp(diffInTurn) != 0 || parent == nil {
+		return
+	}
+	if header.Time != parent.Time+c.config.Period {
+		return
+	}
+	var (
+		period  = time.Duration(c.config.Period) * time.Second
+		latency = c.now().Sub(time.Unix(int64(header.Time), 0))
+	)
+	if latency < 0 || latency >= period {
+		return
+	}
+	if max := time.Duration(maxLatencyShare * float64(period)); latency > max {
+		latency = max
+	}
+	c.latency.update(latency)
+}
+
+// backupStep returns the delay between two backup validators, derived from the
+// observed propagation latency. The maximum step is used until a latency is
+// measured.
+func (c *Chaos) backupStep() time.Duration {
+	var (
+		min = time.Duration(c.config.BackupStepMin) * time.Millisecond
+		max = time.Duration(c.config.BackupStepMax) * time.Millisecond
+	)
+	latency, ok := c.latency.estimate()
+	if !ok {
+		return max
+	}
+	step := time.Duration(c.config.LatencyFactor) * latency
+	if step < min {
+		step = min
+	}
+	if step > max {
+		step = max
+	}
+	return step
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package chaos
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestSnapshot_backupRank(t *testing.T) {
+	type args struct {
+		number    uint64
+		validator common.Address
+	}
+	// Validator 4 is in turn at block 101, validators 1 to 10 signed recently
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   int
+	}{
+		{"inturn", genFields(100), args{101, validatorAddress(4)}, 0},
+		{"first", genFields(100), args{101, validatorAddress(11)}, 1},
+		{"last", genFields(100), args{101, validatorAddress(20)}, 10},
+		{"wrapped", genFields(100), args{101, validatorAddress(0)}, 11},
+		{"recent", genFields(100), args{101, validatorAddress(5)}, 0},
+		{"unknown", genFields(100), args{101, validatorAddress(21)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snapshot{
+				config:     tt.fields.config,
+				sigcache:   tt.fields.sigcache,
+				Number:     tt.fields.Number,
+				Hash:       tt.fields.Hash,
+				Validators: tt.fields.Validators,
+				Recents:    tt.fields.Recents,
+			}
+			if got := s.backupRank(tt.args.number, tt.args.validator); got != tt.want {
+				t.Errorf("Snapshot.backupRank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupStep(t *testing.T) {
+	config := &params.ChainConfig{ChainID: big.NewInt(1), Chaos: &params.ChaosConfig{Period: 3}}
+	engine := New(config, nil)
+
+	// Until a latency is measured the backups are spaced by the maximum step
+	if step := engine.backupStep(); step != maxBackupStep {
+		t.Fatalf("unmeasured step mismatch: have %v, want %v", step, maxBackupStep)
+	}
+	tests := []struct {
+		latency time.Duration
+		want    time.Duration
+	}{
+		{10 * time.Millisecond, minBackupStep},
+		{80 * time.Millisecond, 160 * time.Millisecond},
+		{time.Second, maxBackupStep},
+	}
+	for i, tt := range tests {
+		engine.latency = latencyTracker{}
+		engine.latency.update(tt.latency)
+		if step := engine.backupStep(); step != tt.want {
+			t.Errorf("test %d: step mismatch: have %v, want %v", i, step, tt.want)
+		}
+	}
+	// The estimate follows the latency changes gradually
+	engine.latency = latencyTracker{}
+	engine.latency.update(100 * time.Millisecond)
+	engine.latency.update(200 * time.Millisecond)
+	if latency, _ := engine.latency.estimate(); latency != 110*time.Millisecond {
+		t.Errorf("latency estimate mismatch: have %v, want %v", latency, 110*time.Millisecond)
+	}
+}
+
+func TestObserveBlock(t *testing.T) {
+	config := &params.ChainConfig{ChainID: big.NewInt(1), Chaos: &params.ChaosConfig{Period: 3}}
+	engine := New(config, nil)
+
+	clock := new(mclock.Simulated)
+	clock.Run(100 * time.Second)
+	engine.SetClock(clock)
+
+	// Only the in-turn blocks sealed on schedule within a period are measured, the
+	// older ones are synced or refetched, the late sealed ones carry the lateness
+	tests := []struct {
+		time       uint64
+		parent     uint64
+		difficulty *big.Int
+		sampled    bool
+	}{
+		{97, 94, diffInTurn, false},
+		{100, 97, diffNoTurn, false},
+		{101, 98, diffInTurn, false},
+		{100, 96, diffInTurn, false},
+		{100, 97, diffInTurn, true},
+		{99, 96, diffInTurn, true},
+	}
+	for i, tt := range tests {
+		engine.latency = latencyTracker{}
+		parent := &types.Header{Number: big.NewInt(int64(i)), Time: tt.parent}
+		engine.observeBlock(&types.Header{Number: big.NewInt(int64(i + 1)), Time: tt.time, Difficulty: tt.difficulty}, parent)
+		if _, sampled := engine.latency.estimate(); sampled != tt.sampled {
+			t.Errorf("test %d: sampled mismatch: have %v, want %v", i, sampled, tt.sampled)
+		}
+	}
+}
+
+// Tests that on a network slower than the backup steps the propagation latency
+// is still measured, capped at a share of the period, so the backups keep out of
+// each other's way instead of falling back to the unmeasured default.
+func TestObserveSlowNetwork(t *testing.T) {
+	config := &params.ChainConfig{ChainID: big.NewInt(1), Chaos: &params.ChaosConfig{Period: 3}}
+	engine := New(config, nil)
+
+	clock := new(mclock.Simulated)
+	clock.Run(100 * time.Second)
+	engine.SetClock(clock)
+
+	var (
+		parent = &types.Header{Number: big.NewInt(1), Time: 95}
+		header = &types.Header{Number: big.NewInt(2), Time: 98, ParentHash: parent.Hash(), Difficulty: diffInTurn}
+	)
+	engine.observeBlock(header, parent)
+	latency, sampled := engine.latency.estimate()
+	if !sampled {
+		t.Fatalf("slow block not measured")
+	}
+	if want := 1500 * time.Millisecond; latency != want {
+		t.Errorf("latency mismatch: have %v, want %v", latency, want)
+	}
+	if step := engine.backupStep(); step != maxBackupStep {
+		t.Errorf("step mismatch: have %v, want %v", step, maxBackupStep)
+	}
+}
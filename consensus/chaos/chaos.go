@@ -23,21 +23,23 @@ import (
 	"fmt"
 	"io"
 	"math/big"
-	"math/rand"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/chaos/systemcontract"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/contracts/system"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -52,10 +54,20 @@ const (
 	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
 	inmemoryAccesslist = 21   // Number of recent accesslist snapshots to keep in memory
 
-	wiggleTime        = 500 * time.Millisecond // Random delay (per validator) to allow concurrent validators
-	minNotInTurnDelay = 100 * time.Millisecond // Minimal delay for a not-in-turn validator to seal a block
-	maxValidators     = 21                     // Max validators allowed to seal.
-	blocksPerDay      = 60 * 60 * 24 / 3       // blocks produced per day
+	maxValidators = 21               // Max validators allowed to seal.
+	blocksPerDay  = 60 * 60 * 24 / 3 // blocks produced per day
+)
+
+// Metrics of the Chaos engine. The system executions are counted as the blocks
+// are imported, the blocks sealed locally are not.
+var (
+	sealInturnMeter  = metrics.NewRegisteredMeter("chaos/seal/inturn", nil)  // In-turn blocks sealed by our validator
+	sealNoturnMeter  = metrics.NewRegisteredMeter("chaos/seal/noturn", nil)  // Out-of-turn blocks sealed by our validator
+	sealMissedMeter  = metrics.NewRegisteredMeter("chaos/seal/missed", nil)  // In-turn blocks of our validator sealed by others
+	lazyPunishMeter  = metrics.NewRegisteredMeter("chaos/punish/lazy", nil)  // Lazy punish executions
+	epochChangeMeter = metrics.NewRegisteredMeter("chaos/epoch/change", nil) // Epochs changing the validator set
+
+	validatorsGauge = metrics.NewRegisteredGauge("chaos/epoch/validators", nil)
 )
 
 // Chaos proof-of-stake-authority protocol constants.
@@ -217,10 +229,14 @@ type Chaos struct {
 
 	rewardsUpdatePeroid uint64 // block rewards update perroid in number of blocks
 
+	latency  latencyTracker  // Block propagation latency to space the backup validators by
+	siblings *siblingTracker // Sibling blocks seen, to meter the out-of-turn forks
+
 	chain consensus.ChainHeaderReader
 
 	// The fields below are for testing only
-	fakeDiff bool // Skip difficulty verifications
+	fakeDiff bool         // Skip difficulty verifications
+	clock    mclock.Clock // Simulated clock to time the blocks with, nil for the wall clock
 
 	attestationStatus uint8
 }
@@ -233,6 +249,18 @@ func New(chainConfig *params.ChainConfig, db ethdb.Database) *Chaos {
 	if conf.Epoch <= 1 {
 		conf.Epoch = epochLength
 	}
+	if conf.BackupStepMin == 0 {
+		conf.BackupStepMin = uint64(minBackupStep / time.Millisecond)
+	}
+	if conf.BackupStepMax == 0 {
+		conf.BackupStepMax = uint64(maxBackupStep / time.Millisecond)
+	}
+	if conf.BackupStepMax < conf.BackupStepMin {
+		conf.BackupStepMax = conf.BackupStepMin
+	}
+	if conf.LatencyFactor == 0 {
+		conf.LatencyFactor = latencyFactor
+	}
 
 	// set admin in system contracts of GravitationHardFork if it's provided for private/develop chain
 	if (conf.AdminDevnet != common.Address{}) {
@@ -255,9 +283,25 @@ func New(chainConfig *params.ChainConfig, db ethdb.Database) *Chaos {
 		eventCheckRules:     eventCheckRules,
 		signer:              types.LatestSignerForChainID(chainConfig.ChainID),
 		rewardsUpdatePeroid: blocksPerDay, // default value is one day
+		siblings:            newSiblingTracker(),
 	}
 }
 
+// SetClock replaces the wall clock the blocks are timed with. The absolute time
+// of the clock is taken as unix nanoseconds, so it's meant for the simulated
+// clocks of the network simulations.
+func (c *Chaos) SetClock(clock mclock.Clock) {
+	c.clock = clock
+}
+
+// now returns the current time of the engine clock.
+func (c *Chaos) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return time.Unix(0, int64(c.clock.Now()))
+}
+
 func (c *Chaos) GetDb() ethdb.Database {
 	return c.db
 }
@@ -296,7 +340,13 @@ func (c *Chaos) Author(header *types.Header) (common.Address, error) {
 
 // VerifyHeader checks whether a header conforms to the consensus rules.
 func (c *Chaos) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
-	return c.verifyHeader(chain, header, nil)
+	if err := c.verifyHeader(chain, header, nil); err != nil {
+		return err
+	}
+	// Single headers are mostly verified as the blocks are propagated, measure the
+	// fresh ones
+	c.observeBlock(header, chain.GetHeader(header.ParentHash, header.Number.Uint64()-1))
+	return nil
 }
 
 // VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
@@ -331,7 +381,7 @@ func (c *Chaos) verifyHeader(chain consensus.ChainHeaderReader, header *types.He
 	number := header.Number.Uint64()
 
 	// Don't waste time checking blocks from the future
-	if header.Time > uint64(time.Now().Unix()) {
+	if header.Time > uint64(c.now().Unix()) {
 		return consensus.ErrFutureBlock
 	}
 	// Check that the extra-data contains the vanity, validators and signature.
@@ -438,8 +488,9 @@ func (c *Chaos) snapshot(chain consensus.ChainHeaderReader, number uint64, hash
 			snap = s.(*Snapshot)
 			break
 		}
-		// If an on-disk checkpoint snapshot can be found, use that
-		if number%checkpointInterval == 0 {
+		// If an on-disk checkpoint snapshot can be found, use that. Epoch blocks
+		// are also looked up for the snapshots seeded by the checkpoint sync.
+		if number%checkpointInterval == 0 || number%c.config.Epoch == 0 {
 			if s, err := loadSnapshot(c.chainConfig, c.signatures, c.db, hash); err == nil {
 				log.Trace("Loaded voting snapshot from disk", "number", number, "hash", hash)
 				snap = s
@@ -612,8 +663,8 @@ func (c *Chaos) Prepare(chain consensus.ChainHeaderReader, header *types.Header)
 		return consensus.ErrUnknownAncestor
 	}
 	header.Time = parent.Time + c.config.Period
-	if header.Time < uint64(time.Now().Unix()) {
-		header.Time = uint64(time.Now().Unix())
+	if now := uint64(c.now().Unix()); header.Time < now {
+		header.Time = now
 	}
 	return nil
 }
@@ -669,58 +720,80 @@ func (c *Chaos) FinalizeAndAssemble(chain consensus.ChainHeaderReader, header *t
 // * process proposal tx (after Gravitation hardfork)
 func (c *Chaos) prepareFinalize(chain consensus.ChainHeaderReader, header *types.Header,
 	state *state.StateDB, txs *[]*types.Transaction, receipts *[]*types.Receipt, punishTxs []*types.Transaction, proposalTxs []*types.Transaction, mined bool) error {
+	if err := c.applySystemCalls(chain, header, state, len(*txs) > 0, mined, nil); err != nil {
+		return err
+	}
+	// punish double sign
+	if err := c.punishDoubleSign(chain, header, state, txs, receipts, punishTxs, mined); err != nil {
+		return err
+	}
+	if chain.Config().IsGravitation(header.Number) {
+		// process proposal
+		return c.processProposalTx(chain, header, state, txs, receipts, proposalTxs, mined)
+	}
+	return nil
+}
+
+// systemCallTracer returns the EVM logger to trace a system call with, nil
+// for no tracing.
+type systemCallTracer func(call string) vm.EVMLogger
+
+// callContext returns the context of a system call, traced if a tracer is given.
+func (c *Chaos) callContext(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, tracer systemCallTracer, call string) *systemcontract.CallContext {
+	ctx := &systemcontract.CallContext{
+		Statedb:      state,
+		Header:       header,
+		ChainContext: newChainContext(chain, c),
+		ChainConfig:  c.chainConfig,
+	}
+	if tracer != nil {
+		ctx.Tracer = tracer(call)
+	}
+	return ctx
+}
+
+// applySystemCalls applies the state changes made by the system contract calls
+// of the block finalization, before the system transactions.
+func (c *Chaos) applySystemCalls(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, hasTxs bool, mined bool, tracer systemCallTracer) error {
 	// punish validator if low difficulty block found
 	if header.Difficulty.Cmp(diffInTurn) != 0 {
-		if err := c.tryLazyPunish(chain, header, state); err != nil {
+		if err := c.tryLazyPunish(chain, header, state, mined, tracer); err != nil {
 			return err
 		}
 	}
 	// execute block reward tx.
-	if len(*txs) > 0 {
-		if err := c.tryDistributeBlockFee(chain, header, state); err != nil {
+	if hasTxs {
+		if err := c.tryDistributeBlockFee(chain, header, state, tracer); err != nil {
 			return err
 		}
 	}
 	// do epoch thing at the end, because it will update active validators
 	if header.Number.Uint64()%c.config.Epoch == 0 {
-		vmCtx := &systemcontract.CallContext{
-			Statedb:      state,
-			Header:       header,
-			ChainContext: newChainContext(chain, c),
-			ChainConfig:  c.chainConfig,
-		}
-		if err := c.updateValidators(vmCtx, chain, mined); err != nil {
+		if err := c.updateValidators(c.callContext(chain, header, state, tracer, "updateActiveValidatorSet"), chain, mined, tracer); err != nil {
 			return err
 		}
 		//  decrease validator missed blocks counter at epoch
-		if err := systemcontract.DecreaseMissedBlocksCounter(vmCtx); err != nil {
+		if err := systemcontract.DecreaseMissedBlocksCounter(c.callContext(chain, header, state, tracer, "decreaseMissedBlocksCounter")); err != nil {
 			return err
 		}
 	}
 	// UpdateRewardsInfo once a day
 	if header.Number.Uint64()%c.rewardsUpdatePeroid == 0 {
-		if err := systemcontract.UpdateRewardsInfo(&systemcontract.CallContext{
-			Statedb:      state,
-			Header:       header,
-			ChainContext: newChainContext(chain, c),
-			ChainConfig:  c.chainConfig,
-		}); err != nil {
+		if err := systemcontract.UpdateRewardsInfo(c.callContext(chain, header, state, tracer, "updateRewardsInfo")); err != nil {
 			return err
 		}
 	}
-	// punish double sign
-	if err := c.punishDoubleSign(chain, header, state, txs, receipts, punishTxs, mined); err != nil {
-		return err
-	}
-	if chain.Config().IsGravitation(header.Number) {
-		// process proposal
-		return c.processProposalTx(chain, header, state, txs, receipts, proposalTxs, mined)
-	}
 	return nil
 }
 
+// TraceFinalize implements consensus.ChaosEngine, applying the system calls of
+// the block finalization with the EVM loggers returned by trace.
+func (c *Chaos) TraceFinalize(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, commonTxs int, trace func(call string) vm.EVMLogger) error {
+	return c.applySystemCalls(chain, header, state, commonTxs > 0, false, trace)
+}
+
 // updateValidators updates validators info to system contracts
-func (c *Chaos) updateValidators(vmCtx *systemcontract.CallContext, chain consensus.ChainHeaderReader, mined bool) error {
+func (c *Chaos) updateValidators(vmCtx *systemcontract.CallContext, chain consensus.ChainHeaderReader, mined bool, tracer systemCallTracer) error {
 	newValidators, err := c.getTopValidators(chain, vmCtx.Header)
 	if err != nil {
 		return err
@@ -740,31 +813,50 @@ func (c *Chaos) updateValidators(vmCtx *systemcontract.CallContext, chain consen
 		log.Error("Fail to update validators to system contract", "err", err)
 		return err
 	}
+	if !mined && tracer == nil {
+		c.trackValidatorSet(chain, vmCtx.Header, newValidators)
+	}
 	return nil
 }
 
+// trackValidatorSet meters the validator set of an imported epoch block.
+func (c *Chaos) trackValidatorSet(chain consensus.ChainHeaderReader, header *types.Header, validators []common.Address) {
+	validatorsGauge.Update(int64(len(validators)))
+
+	snap, err := c.snapshot(chain, header.Number.Uint64()-1, header.ParentHash, nil)
+	if err != nil {
+		return
+	}
+	changed := len(validators) != len(snap.Validators)
+	for _, validator := range validators {
+		if _, ok := snap.Validators[validator]; !ok {
+			changed = true
+		}
+	}
+	if changed {
+		epochChangeMeter.Mark(1)
+	}
+}
+
 // tryDistributeBlockFee distributes block fee to validators
-func (c *Chaos) tryDistributeBlockFee(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) error {
+func (c *Chaos) tryDistributeBlockFee(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, tracer systemCallTracer) error {
 	fee := state.GetBalance(consensus.FeeRecoder)
 	if fee.Cmp(common.Big0) <= 0 {
 		return nil
 	}
+	// The fee moves are traced with the call distributing them
+	ctx := c.callContext(chain, header, state, tracer, "distributeBlockFee")
 
 	// Miner will send tx to deposit block fees to contract, add to his balance first.
 	state.AddBalance(header.Coinbase, fee)
 	// reset fee
 	state.SetBalance(consensus.FeeRecoder, common.Big0)
 
-	return systemcontract.DistributeBlockFee(&systemcontract.CallContext{
-		Statedb:      state,
-		Header:       header,
-		ChainContext: newChainContext(chain, c),
-		ChainConfig:  c.chainConfig,
-	}, fee)
+	return systemcontract.DistributeBlockFee(ctx, fee)
 }
 
 // tryLazyPunish punishes validators that didn't produce blocks
-func (c *Chaos) tryLazyPunish(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB) error {
+func (c *Chaos) tryLazyPunish(chain consensus.ChainHeaderReader, header *types.Header, state *state.StateDB, mined bool, tracer systemCallTracer) error {
 	number := header.Number.Uint64()
 	snap, err := c.snapshot(chain, number-1, header.ParentHash, nil)
 	if err != nil {
@@ -773,6 +865,16 @@ func (c *Chaos) tryLazyPunish(chain consensus.ChainHeaderReader, header *types.H
 	validators := snap.validators()
 	continuousBlocks := c.chainConfig.ChaosContinuousInturn(header.Number)
 	outTurnValidator := validators[number%(uint64(len(validators))*continuousBlocks)/continuousBlocks]
+
+	imported := !mined && tracer == nil
+	if imported {
+		c.lock.RLock()
+		val := c.validator
+		c.lock.RUnlock()
+		if outTurnValidator == val {
+			sealMissedMeter.Mark(1)
+		}
+	}
 	// check sigend recently or not
 	signedRecently := false
 	for _, recent := range snap.Recents {
@@ -782,12 +884,12 @@ func (c *Chaos) tryLazyPunish(chain consensus.ChainHeaderReader, header *types.H
 		}
 	}
 	if !signedRecently {
-		return systemcontract.LazyPunish(&systemcontract.CallContext{
-			Statedb:      state,
-			Header:       header,
-			ChainContext: newChainContext(chain, c),
-			ChainConfig:  c.chainConfig,
-		}, outTurnValidator)
+		if err := systemcontract.LazyPunish(c.callContext(chain, header, state, tracer, "lazyPunish"), outTurnValidator); err != nil {
+			return err
+		}
+		if imported {
+			lazyPunishMeter.Mark(1)
+		}
 	}
 
 	return nil
@@ -857,17 +959,18 @@ func (c *Chaos) Seal(chain consensus.ChainHeaderReader, block *types.Block, resu
 	}
 
 	// Sweet, the protocol permits us to sign the block, wait for our time
-	delay := time.Until(time.Unix(int64(header.Time), 0))
+	delay := time.Unix(int64(header.Time), 0).Sub(c.now())
 	if header.Difficulty.Cmp(diffNoTurn) == 0 {
-		// It's not our turn explicitly to sign, delay it a bit
-		wiggle := time.Duration(len(snap.Validators)/2+1) * wiggleTime
-		wiggle = time.Duration(rand.Int63n(int64(wiggle)))
-		if wiggle < minNotInTurnDelay {
-			wiggle += minNotInTurnDelay
+		// It's not our turn explicitly to sign, wait for the backups before us
+		rank := snap.backupRank(number, val)
+		if rank == 0 {
+			return errRecentlySigned
 		}
+		wiggle := time.Duration(rank) * c.backupStep()
 		delay += wiggle
+		backupDelayTimer.Update(wiggle)
 
-		log.Trace("Out-of-turn signing requested", "wiggle", common.PrettyDuration(wiggle))
+		log.Trace("Out-of-turn signing requested", "rank", rank, "wiggle", common.PrettyDuration(wiggle))
 	}
 	// Sign all the things!
 	sighash, err := signFn(accounts.Account{Address: val}, accounts.MimetypeChaos, ChaosRLP(header))
@@ -877,15 +980,25 @@ func (c *Chaos) Seal(chain consensus.ChainHeaderReader, block *types.Block, resu
 	copy(header.Extra[len(header.Extra)-extraSeal:], sighash)
 	// Wait until sealing is terminated or delay timeout.
 	log.Trace("Waiting for slot to sign and propagate", "delay", common.PrettyDuration(delay))
+	clock := c.clock
+	if clock == nil {
+		clock = mclock.System{}
+	}
 	go func() {
 		select {
 		case <-stop:
 			return
-		case <-time.After(delay):
+		case <-clock.After(delay):
 		}
+		c.siblings.track(header)
 
 		select {
 		case results <- block.WithSeal(header):
+			if header.Difficulty.Cmp(diffInTurn) == 0 {
+				sealInturnMeter.Mark(1)
+			} else {
+				sealNoturnMeter.Mark(1)
+			}
 		default:
 			log.Warn("Sealing result is not read by miner", "sealhash", SealHash(header))
 		}
@@ -935,6 +1048,11 @@ func (c *Chaos) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 		Version:   "1.0",
 		Service:   &API{chain: chain, chaos: c},
 		Public:    false,
+	}, {
+		Namespace: "staking",
+		Version:   "1.0",
+		Service:   &StakingAPI{chain: chain, chaos: c},
+		Public:    true,
 	}}
 }
 
@@ -1001,11 +1119,28 @@ func (c *Chaos) PreHandle(chain consensus.ChainHeaderReader, header *types.Heade
 
 // CalculateGasPool determines gas limit of each block
 func (c *Chaos) CalculateGasPool(header *types.Header) uint64 {
-	idxInturn := header.Number.Uint64() % params.ContinousInturn
-	if idxInturn == 0 || idxInturn == params.ContinousInturn-1 {
-		return header.GasLimit / 2
+	return c.GasBudget(header.Number, header.GasLimit)
+}
+
+// GasBudget returns the gas budget of the block with the given number and gas limit.
+// The first and the last block of each in-turn run only get half of the gas limit,
+// so that the hand over between validators won't be blocked by a heavy block.
+func (c *Chaos) GasBudget(number *big.Int, gasLimit uint64) uint64 {
+	continuousInturn := c.chainConfig.ChaosContinuousInturn(number)
+	idxInturn := number.Uint64() % continuousInturn
+	if idxInturn == 0 || idxInturn == continuousInturn-1 {
+		return gasLimit / 2
 	}
-	return header.GasLimit
+	return gasLimit
+}
+
+// MaxGasBudget returns the largest gas budget among the blocks of an in-turn run
+// around the given number, it's the upper bound of the gas of a single transaction.
+func (c *Chaos) MaxGasBudget(number *big.Int, gasLimit uint64) uint64 {
+	if c.chainConfig.ChaosContinuousInturn(number) <= 2 {
+		return gasLimit / 2
+	}
+	return gasLimit
 }
 
 func (c *Chaos) ExtraValidateOfTx(sender common.Address, tx *types.Transaction, header *types.Header) error {
@@ -1013,15 +1148,41 @@ func (c *Chaos) ExtraValidateOfTx(sender common.Address, tx *types.Transaction,
 	// Miner should not call the following funcs through transaction:
 	// "doubleSignPunish(bytes32,address)": "01036cae",
 	// "lazyPunish(address)": "e818ef86",
-	if sender == header.Coinbase &&
-		tx.To() != nil && *(tx.To()) == (system.StakingContract) {
-		if len(tx.Data()) >= 4 {
-			b4 := tx.Data()[:4]
-			if bytes.Equal(b4, doubleSignPunishByte4) || bytes.Equal(b4, lazyPunishByte4) {
-				log.Error(errInvalidDifficulty.Error(), "number", header.Number, "blockHash", header.Hash().String(), "miner", header.Coinbase.String(), "txHash", tx.Hash().String(), "txData", common.Bytes2Hex(tx.Data()))
-				return errContainIllegalTx
-			}
-		}
+	if sender == header.Coinbase && isPunishCall(tx) {
+		log.Error(errInvalidDifficulty.Error(), "number", header.Number, "blockHash", header.Hash().String(), "miner", header.Coinbase.String(), "txHash", tx.Hash().String(), "txData", common.Bytes2Hex(tx.Data()))
+		return errContainIllegalTx
 	}
 	return nil
 }
+
+// isPunishCall checks whether a transaction calls the punishing functions of the
+// Staking contract, reserved to the system transactions.
+func isPunishCall(tx *types.Transaction) bool {
+	if tx.To() == nil || *tx.To() != system.StakingContract || len(tx.Data()) < 4 {
+		return false
+	}
+	b4 := tx.Data()[:4]
+	return bytes.Equal(b4, doubleSignPunishByte4) || bytes.Equal(b4, lazyPunishByte4)
+}
+
+// IsPriorityTx checks whether a transaction belongs to the priority lane, that is
+// a call to the Staking or OnChainDao contract sent by an active validator.
+// The header is the one of the block that the transaction is going to be packed.
+func (c *Chaos) IsPriorityTx(sender common.Address, tx *types.Transaction, header *types.Header) bool {
+	to := tx.To()
+	if to == nil || (*to != system.StakingContract && *to != system.OnChainDaoContract) {
+		return false
+	}
+	// The validator may seal the block itself, so the rules on the miner's transactions apply.
+	if isPunishCall(tx) {
+		return false
+	}
+	if c.chain == nil || header.Number.Sign() == 0 {
+		return false
+	}
+	snap, err := c.snapshot(c.chain, header.Number.Uint64()-1, header.ParentHash, nil)
+	if err != nil {
+		return false
+	}
+	return snap.IsAuthorized(sender)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -32,6 +33,8 @@ var (
 	// event ExecutedDoubleSignPunish(address indexed plaintiff, address indexed defendant, uint8 indexed value, bytes data);
 	// event signature:  crypto.Keccak256([]byte("ExecutedDoubleSignPunish(address,address,uint8,bytes)"))
 	executedDoubleSignPunishEventSig = common.HexToHash("0x250969e8ccb0e19752686619d1ce1af974eeea52b88479ca3ec6cced6b7c9198")
+
+	doubleSignPunishMeter = metrics.NewRegisteredMeter("chaos/punish/doublesign", nil)
 )
 
 // punishDoubleSign punishes double sign attack in casper ffg
@@ -49,6 +52,7 @@ func (c *Chaos) punishDoubleSign(chain consensus.ChainHeaderReader, header *type
 			if err != nil {
 				return err
 			}
+			doubleSignPunishMeter.Mark(1)
 			*txs = append(*txs, tx)
 			*receipts = append(*receipts, receipt)
 		}
@@ -139,6 +143,18 @@ func (c *Chaos) CurrentValidator() common.Address {
 	return c.validator
 }
 
+// SignData signs the data with the key of the current validator.
+func (c *Chaos) SignData(data []byte) ([]byte, error) {
+	c.lock.RLock()
+	val, signFn := c.validator, c.signFn
+	c.lock.RUnlock()
+
+	if signFn == nil {
+		return nil, errUnauthorizedValidator
+	}
+	return signFn(accounts.Account{Address: val}, "", data)
+}
+
 func (c *Chaos) MaxValidators() uint8 {
 	return systemcontract.TopValidatorNum
 }
@@ -399,6 +415,9 @@ func (c *Chaos) ApplyDoubleSignPunishTx(evm *vm.EVM, sender common.Address, tx *
 	if err = rlp.DecodeBytes(tx.Data(), p); err != nil {
 		return
 	}
+	evm.CaptureTxStart(sender)
+	defer evm.CaptureTxEnd()
+
 	nonce := evm.StateDB.GetNonce(sender)
 	//add nonce for validator
 	evm.StateDB.SetNonce(sender, nonce+1)
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -41,6 +42,9 @@ import (
 var (
 	proposalTxMark           = common.HexToAddress("0x000000000000000000000000000000000000FFFF")
 	proposalExecutedEventSig = common.HexToHash("0xce6004e6e4497b8f4978e17f771f74179bea0aeb34ed808a76f26ae79f23c541")
+
+	proposalMeter       = metrics.NewRegisteredMeter("chaos/proposal/executed", nil)
+	proposalFailedMeter = metrics.NewRegisteredMeter("chaos/proposal/failed", nil)
 )
 
 // processProposalTx process tx of system proposal
@@ -85,6 +89,11 @@ func (c *Chaos) processProposalTx(chain consensus.ChainHeaderReader, header *typ
 			if receipt, err = c.replayProposal(chain, header, state, prop, len(*txs), tx); err != nil {
 				return err
 			}
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				proposalMeter.Mark(1)
+			} else {
+				proposalFailedMeter.Mark(1)
+			}
 		} else if tx, receipt, err = c.executeProposal(chain, header, state, prop, len(*txs)); err != nil {
 			return err
 		}
@@ -264,6 +273,14 @@ func (c *Chaos) IsSysTransaction(sender common.Address, tx *types.Transaction, h
 	return false
 }
 
+// IsProposalTransaction checks whether a system transaction executes a passed proposal.
+func (c *Chaos) IsProposalTransaction(sender common.Address, tx *types.Transaction, header *types.Header) bool {
+	if tx.To() == nil {
+		return false
+	}
+	return sender == header.Coinbase && *tx.To() == proposalTxMark && tx.GasPrice().Sign() == 0
+}
+
 // Methods for debug trace
 
 // ApplyProposalTx applies a system-transaction using a given evm,
@@ -274,6 +291,10 @@ func (c *Chaos) ApplyProposalTx(evm *vm.EVM, state *state.StateDB, txIndex int,
 		return
 	}
 	evm.Context.AccessFilter = nil
+	// The code deletion is made outside of the EVM
+	evm.CaptureTxStart(sender, prop.To)
+	defer evm.CaptureTxEnd()
+
 	//add nonce for validator
 	evm.StateDB.SetNonce(sender, evm.StateDB.GetNonce(sender)+1)
 
@@ -471,3 +471,33 @@ func runChaosTest(t *testing.T, testID int, tc *testcase) {
 		}
 	}
 }
+
+func TestGasBudget(t *testing.T) {
+	engine := &Chaos{chainConfig: &params.ChainConfig{}}
+	gasLimit := uint64(40000000)
+	tests := []struct {
+		number uint64
+		want   uint64
+	}{
+		{0, gasLimit / 2},
+		{1, gasLimit},
+		{2, gasLimit},
+		{3, gasLimit / 2},
+		{4, gasLimit / 2},
+		{5, gasLimit},
+		{103, gasLimit / 2},
+	}
+	for _, tt := range tests {
+		number := new(big.Int).SetUint64(tt.number)
+		if got := engine.GasBudget(number, gasLimit); got != tt.want {
+			t.Errorf("block %d: gas budget mismatch: have %d, want %d", tt.number, got, tt.want)
+		}
+		header := &types.Header{Number: number, GasLimit: gasLimit}
+		if got := engine.CalculateGasPool(header); got != tt.want {
+			t.Errorf("block %d: gas pool mismatch: have %d, want %d", tt.number, got, tt.want)
+		}
+	}
+	if got := engine.MaxGasBudget(common.Big1, gasLimit); got != gasLimit {
+		t.Errorf("max gas budget mismatch: have %d, want %d", got, gasLimit)
+	}
+}
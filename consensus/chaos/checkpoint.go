@@ -0,0 +1,150 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// errInvalidCheckpoint is returned if the checkpoint proof doesn't prove the
+	// trusted checkpoint block.
+	errInvalidCheckpoint = errors.New("invalid checkpoint proof")
+)
+
+// CheckpointProof is everything needed to start syncing from a finalized epoch
+// block instead of the genesis. The headers of the last epoch carry the validator
+// set at the checkpoint, and the attestations prove the checkpoint is justified
+// by that validator set.
+type CheckpointProof struct {
+	Headers      []*types.Header      // Headers of the last epoch, ending with the checkpoint
+	Body         *types.Body          // Body of the checkpoint block
+	Receipts     []*types.Receipt     // Receipts of the checkpoint block
+	TD           *big.Int             // Total difficulty of the checkpoint block
+	Attestations []*types.Attestation // Attestations targeting the checkpoint block
+}
+
+// Checkpoint returns the header of the checkpoint block.
+func (p *CheckpointProof) Checkpoint() *types.Header {
+	return p.Headers[len(p.Headers)-1]
+}
+
+// VerifyCheckpoint checks that the proof is for the trusted checkpoint block, the
+// headers are chained and the checkpoint is attested by the validators of its
+// epoch. The validator set at an epoch block is the one in the extra-data of the
+// previous epoch block, since the validator sets are looked back.
+func (c *Chaos) VerifyCheckpoint(hash common.Hash, proof *CheckpointProof) error {
+	if len(proof.Headers) != int(c.config.Epoch)+1 {
+		return fmt.Errorf("%w: have %d headers, want %d", errInvalidCheckpoint, len(proof.Headers), c.config.Epoch+1)
+	}
+	checkpoint := proof.Checkpoint()
+	if checkpoint.Hash() != hash {
+		return fmt.Errorf("%w: checkpoint hash %x, trusted %x", errInvalidCheckpoint, checkpoint.Hash(), hash)
+	}
+	if checkpoint.Number.Uint64()%c.config.Epoch != 0 {
+		return fmt.Errorf("%w: block %d is not an epoch block", errInvalidCheckpoint, checkpoint.Number)
+	}
+	for i := 1; i < len(proof.Headers); i++ {
+		parent, header := proof.Headers[i-1], proof.Headers[i]
+		if header.Number.Uint64() != parent.Number.Uint64()+1 || header.ParentHash != parent.Hash() {
+			return fmt.Errorf("%w: header %d not chained", errInvalidCheckpoint, header.Number)
+		}
+	}
+	// Verify the content of the checkpoint block
+	if proof.Body == nil || proof.TD == nil {
+		return fmt.Errorf("%w: missing body or total difficulty", errInvalidCheckpoint)
+	}
+	if root := types.DeriveSha(types.Transactions(proof.Body.Transactions), trie.NewStackTrie(nil)); root != checkpoint.TxHash {
+		return fmt.Errorf("%w: transaction root mismatch", errInvalidCheckpoint)
+	}
+	if root := types.DeriveSha(types.Receipts(proof.Receipts), trie.NewStackTrie(nil)); root != checkpoint.ReceiptHash {
+		return fmt.Errorf("%w: receipt root mismatch", errInvalidCheckpoint)
+	}
+	// Verify the attestations against the validators of the epoch
+	validators := make(map[common.Address]struct{})
+	for _, val := range core.CheckpointValidators(proof.Headers[0]) {
+		validators[val] = struct{}{}
+	}
+	signers := make(map[common.Address]struct{})
+	for _, a := range proof.Attestations {
+		if err := a.SanityCheck(); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidCheckpoint, err)
+		}
+		if a.TargetRangeEdge.Hash != hash || a.TargetRangeEdge.Number.Cmp(checkpoint.Number) != 0 {
+			return fmt.Errorf("%w: attestation %x not targeting the checkpoint", errInvalidCheckpoint, a.Hash())
+		}
+		signer, err := a.RecoverSigner()
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidCheckpoint, err)
+		}
+		if _, ok := validators[signer]; !ok {
+			return fmt.Errorf("%w: attestation signer %x not a validator", errInvalidCheckpoint, signer)
+		}
+		signers[signer] = struct{}{}
+	}
+	if threshold := attestationThreshold(len(validators)); len(signers) < threshold {
+		return fmt.Errorf("%w: %d attestations, threshold %d", errInvalidCheckpoint, len(signers), threshold)
+	}
+	return nil
+}
+
+// SeedCheckpoint stores the snapshot at a verified checkpoint, so the headers
+// after it can be verified without the ones before.
+func (c *Chaos) SeedCheckpoint(proof *CheckpointProof) error {
+	snap, err := c.epochSnapshot(proof.Headers)
+	if err != nil {
+		return err
+	}
+	if err := snap.store(c.db); err != nil {
+		return err
+	}
+	c.recents.Add(snap.Hash, snap)
+
+	log.Info("Seeded checkpoint snapshot", "number", snap.Number, "hash", snap.Hash, "validators", len(snap.Validators))
+	return nil
+}
+
+// epochSnapshot creates the snapshot at an epoch block from the headers of the
+// epoch ending with it. The validators are from the extra-data of the previous
+// epoch block, and the recent signers are recovered from the last headers.
+func (c *Chaos) epochSnapshot(headers []*types.Header) (*Snapshot, error) {
+	var (
+		checkpoint = headers[len(headers)-1]
+		number     = checkpoint.Number.Uint64()
+		snap       = newSnapshot(c.chainConfig, c.signatures, number, checkpoint.Hash(), core.CheckpointValidators(headers[0]))
+		limit      = uint64(len(snap.Validators)/2+1) * c.chainConfig.ChaosContinuousInturn(checkpoint.Number)
+	)
+	for _, header := range headers[1:] {
+		if header.Number.Uint64()+limit <= number {
+			continue
+		}
+		signer, err := ecrecover(header, c.signatures)
+		if err != nil {
+			return nil, err
+		}
+		snap.Recents[header.Number.Uint64()] = signer
+	}
+	return snap, nil
+}
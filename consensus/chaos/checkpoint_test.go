@@ -0,0 +1,132 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package chaos
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestCheckpointProof creates the proof of the epoch block at 2*epoch, with
+// attestations from the given number of validators.
+func newTestCheckpointProof(t *testing.T, ap *testerAccountPool, validators []string, epoch uint64, attesters int) *CheckpointProof {
+	var (
+		headers []*types.Header
+		parent  common.Hash
+	)
+	for n := epoch; n <= 2*epoch; n++ {
+		header := &types.Header{
+			ParentHash:  parent,
+			Number:      new(big.Int).SetUint64(n),
+			Difficulty:  big.NewInt(2),
+			Coinbase:    ap.address(validators[int(n)%len(validators)]),
+			TxHash:      types.EmptyRootHash,
+			ReceiptHash: types.EmptyRootHash,
+			Extra:       make([]byte, extraVanity+extraSeal),
+		}
+		if n%epoch == 0 {
+			header.Extra = make([]byte, extraVanity+len(validators)*common.AddressLength+extraSeal)
+			ap.checkpoint(header, validators)
+		}
+		ap.sign(header)
+		headers = append(headers, header)
+		parent = header.Hash()
+	}
+	var (
+		checkpoint = headers[len(headers)-1]
+		source     = &types.RangeEdge{Hash: headers[0].Hash(), Number: headers[0].Number}
+		target     = &types.RangeEdge{Hash: checkpoint.Hash(), Number: checkpoint.Number}
+		proof      = &CheckpointProof{
+			Headers: headers,
+			Body:    &types.Body{},
+			TD:      big.NewInt(int64(4 * epoch)),
+		}
+	)
+	for _, val := range validators[:attesters] {
+		sig, err := crypto.Sign(types.AttestationSignHash(source, target).Bytes(), ap.accounts[val])
+		if err != nil {
+			t.Fatalf("failed to sign attestation: %v", err)
+		}
+		proof.Attestations = append(proof.Attestations, types.NewAttestation(source, target, sig))
+	}
+	return proof
+}
+
+func TestVerifyCheckpoint(t *testing.T) {
+	var (
+		ap         = newTesterAccountPool()
+		validators = []string{"A", "B", "C"}
+		config     = &params.ChainConfig{ChainID: big.NewInt(1), Chaos: &params.ChaosConfig{Epoch: 4}}
+		engine     = New(config, rawdb.NewMemoryDatabase())
+	)
+	proof := newTestCheckpointProof(t, ap, validators, 4, 3)
+	hash := proof.Checkpoint().Hash()
+	if err := engine.VerifyCheckpoint(hash, proof); err != nil {
+		t.Fatalf("valid checkpoint rejected: %v", err)
+	}
+	if err := engine.VerifyCheckpoint(common.Hash{0x01}, proof); !errors.Is(err, errInvalidCheckpoint) {
+		t.Errorf("untrusted checkpoint accepted: %v", err)
+	}
+	// A duplicated attestation doesn't count towards the threshold
+	short := newTestCheckpointProof(t, ap, validators, 4, 2)
+	short.Attestations = append(short.Attestations, short.Attestations[0])
+	if err := engine.VerifyCheckpoint(short.Checkpoint().Hash(), short); !errors.Is(err, errInvalidCheckpoint) {
+		t.Errorf("checkpoint below attestation threshold accepted: %v", err)
+	}
+	// An unchained header invalidates the validator set
+	broken := newTestCheckpointProof(t, ap, validators, 4, 3)
+	broken.Headers[1].ParentHash = common.Hash{0x02}
+	if err := engine.VerifyCheckpoint(broken.Checkpoint().Hash(), broken); !errors.Is(err, errInvalidCheckpoint) {
+		t.Errorf("unchained checkpoint accepted: %v", err)
+	}
+}
+
+func TestSeedCheckpoint(t *testing.T) {
+	var (
+		ap         = newTesterAccountPool()
+		validators = []string{"A", "B", "C"}
+		config     = &params.ChainConfig{ChainID: big.NewInt(1), Chaos: &params.ChaosConfig{Epoch: 4}}
+		db         = rawdb.NewMemoryDatabase()
+	)
+	proof := newTestCheckpointProof(t, ap, validators, 4, 3)
+	if err := New(config, db).SeedCheckpoint(proof); err != nil {
+		t.Fatalf("failed to seed checkpoint: %v", err)
+	}
+	engine := New(config, db)
+	snap, err := loadSnapshot(config, engine.signatures, db, proof.Checkpoint().Hash())
+	if err != nil {
+		t.Fatalf("failed to load seeded snapshot: %v", err)
+	}
+	if snap.Number != 8 || len(snap.Validators) != len(validators) {
+		t.Errorf("snapshot mismatch: have number %d, %d validators", snap.Number, len(snap.Validators))
+	}
+	for _, val := range validators {
+		if _, ok := snap.Validators[ap.address(val)]; !ok {
+			t.Errorf("validator %s missing from snapshot", val)
+		}
+	}
+	if len(snap.Recents) == 0 {
+		t.Errorf("recent signers not seeded")
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package chaos
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// replayBatch is the maximum number of headers applied to a snapshot at once
+// while replaying the chain.
+const replayBatch = 2048
+
+// StoredSnapshot is a snapshot entry in the database.
+type StoredSnapshot struct {
+	Hash     common.Hash // Block hash in the database key
+	Size     int         // Size of the encoded snapshot
+	Snapshot *Snapshot   // Decoded snapshot, nil if undecodable
+	Err      error       // Decoding error, if any
+}
+
+// Canonical reports whether the snapshot is of a canonical block.
+func (s *StoredSnapshot) Canonical(db ethdb.Reader) bool {
+	return s.Snapshot != nil && rawdb.ReadCanonicalHash(db, s.Snapshot.Number) == s.Hash
+}
+
+// SnapshotMismatch is a stored snapshot differing from the one re-derived from
+// the headers.
+type SnapshotMismatch struct {
+	Stored  *Snapshot
+	Derived *Snapshot
+}
+
+// StoredSnapshots retrieves all the snapshots in the database, ordered by block
+// number. The undecodable ones are returned last.
+func StoredSnapshots(db ethdb.Iteratee) ([]*StoredSnapshot, error) {
+	it := db.NewIterator(snapshotPrefix, nil)
+	defer it.Release()
+
+	var snaps []*StoredSnapshot
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(snapshotPrefix)+common.HashLength {
+			continue
+		}
+		stored := &StoredSnapshot{
+			Hash: common.BytesToHash(key[len(snapshotPrefix):]),
+			Size: len(key) + len(it.Value()),
+		}
+		snap := new(Snapshot)
+		if err := json.Unmarshal(it.Value(), snap); err != nil {
+			stored.Err = err
+		} else if snap.Hash != stored.Hash {
+			stored.Err = fmt.Errorf("snapshot hash %x mismatch", snap.Hash)
+		} else {
+			stored.Snapshot = snap
+		}
+		snaps = append(snaps, stored)
+	}
+	sort.SliceStable(snaps, func(i, j int) bool {
+		if snaps[i].Snapshot == nil || snaps[j].Snapshot == nil {
+			return snaps[j].Snapshot == nil && snaps[i].Snapshot != nil
+		}
+		return snaps[i].Snapshot.Number < snaps[j].Snapshot.Number
+	})
+	return snaps, it.Error()
+}
+
+// InspectSnapshot retrieves the snapshot at a block, deriving it from the nearest
+// stored one if needed. It also reports whether the snapshot itself is stored.
+func (c *Chaos) InspectSnapshot(chain consensus.ChainHeaderReader, number uint64, hash common.Hash) (*Snapshot, bool, error) {
+	_, err := loadSnapshot(c.chainConfig, c.signatures, c.db, hash)
+	stored := err == nil
+
+	snap, err := c.snapshot(chain, number, hash, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return snap, stored, nil
+}
+
+// VerifySnapshots re-derives the snapshots from the canonical headers, starting
+// from the trusted snapshot of the given epoch block, and compares them with the
+// stored ones after it. The snapshots of the non-canonical blocks are skipped.
+func (c *Chaos) VerifySnapshots(chain consensus.ChainHeaderReader, from uint64) (int, []*SnapshotMismatch, error) {
+	all, err := StoredSnapshots(c.db)
+	if err != nil {
+		return 0, nil, err
+	}
+	stored := make(map[uint64]*Snapshot)
+	var last uint64
+	for _, s := range all {
+		if s.Snapshot == nil || s.Snapshot.Number <= from || !s.Canonical(c.db) {
+			continue
+		}
+		stored[s.Snapshot.Number] = s.Snapshot
+		last = s.Snapshot.Number
+	}
+	if len(stored) == 0 {
+		return 0, nil, nil
+	}
+	base, err := c.baseSnapshot(chain, from)
+	if err != nil {
+		return 0, nil, err
+	}
+	var mismatches []*SnapshotMismatch
+	err = c.replaySnapshots(chain, base, last, func(number uint64) bool {
+		_, ok := stored[number]
+		return ok
+	}, func(snap *Snapshot) error {
+		have := stored[snap.Number]
+		if have.Hash != snap.Hash || !reflect.DeepEqual(have.Validators, snap.Validators) || !reflect.DeepEqual(have.Recents, snap.Recents) {
+			mismatches = append(mismatches, &SnapshotMismatch{Stored: have, Derived: snap})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(stored), mismatches, nil
+}
+
+// RebuildSnapshots regenerates the snapshots from the trusted snapshot of the
+// given epoch block up to the head. The stored snapshots of the canonical blocks
+// after it are replaced, the ones of the other blocks are left to pruning.
+func (c *Chaos) RebuildSnapshots(chain consensus.ChainHeaderReader, from uint64) (int, error) {
+	head := chain.CurrentHeader().Number.Uint64()
+	if from > head {
+		return 0, fmt.Errorf("block %d above the head %d", from, head)
+	}
+	base, err := c.baseSnapshot(chain, from)
+	if err != nil {
+		return 0, err
+	}
+	all, err := StoredSnapshots(c.db)
+	if err != nil {
+		return 0, err
+	}
+	batch := c.db.NewBatch()
+	for _, s := range all {
+		if s.Snapshot != nil && s.Snapshot.Number > from && s.Canonical(c.db) {
+			batch.Delete(snapshotKey(s.Hash))
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return 0, err
+	}
+	c.recents.Purge()
+
+	if err := base.store(c.db); err != nil {
+		return 0, err
+	}
+	written := 1
+	err = c.replaySnapshots(chain, base, head, func(number uint64) bool {
+		return number%checkpointInterval == 0
+	}, func(snap *Snapshot) error {
+		written++
+		return snap.store(c.db)
+	})
+	return written, err
+}
+
+// PruneSnapshots deletes the stored snapshots of the non-canonical blocks, the
+// undecodable ones and the ones more than keep blocks below the head. The newest
+// canonical snapshot is always kept, so the snapshots can still be derived without
+// replaying the chain from the genesis.
+func PruneSnapshots(db ethdb.Database, keep uint64) (int, error) {
+	var head uint64
+	if number := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db)); number != nil {
+		head = *number
+	}
+	all, err := StoredSnapshots(db)
+	if err != nil {
+		return 0, err
+	}
+	// Find the newest canonical snapshot to keep regardless of its age
+	var newest common.Hash
+	for _, s := range all {
+		if s.Snapshot != nil && s.Snapshot.Number <= head && s.Canonical(db) {
+			newest = s.Hash
+		}
+	}
+	var (
+		batch  = db.NewBatch()
+		pruned int
+	)
+	for _, s := range all {
+		switch {
+		case s.Snapshot == nil:
+			log.Debug("Pruning undecodable Chaos snapshot", "hash", s.Hash, "err", s.Err)
+		case !s.Canonical(db):
+			log.Debug("Pruning non-canonical Chaos snapshot", "number", s.Snapshot.Number, "hash", s.Hash)
+		case s.Hash != newest && s.Snapshot.Number+keep < head:
+			log.Debug("Pruning old Chaos snapshot", "number", s.Snapshot.Number, "hash", s.Hash)
+		default:
+			continue
+		}
+		batch.Delete(snapshotKey(s.Hash))
+		pruned++
+
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return pruned, err
+			}
+			batch.Reset()
+		}
+	}
+	return pruned, batch.Write()
+}
+
+// baseSnapshot creates the snapshot at an epoch block from the headers, without
+// looking up the stored snapshots.
+func (c *Chaos) baseSnapshot(chain consensus.ChainHeaderReader, number uint64) (*Snapshot, error) {
+	if number%c.config.Epoch != 0 {
+		return nil, fmt.Errorf("block %d is not an epoch block", number)
+	}
+	if number == 0 {
+		genesis := chain.GetHeaderByNumber(0)
+		if genesis == nil {
+			return nil, errUnknownBlock
+		}
+		return newSnapshot(c.chainConfig, c.signatures, 0, genesis.Hash(), core.CheckpointValidators(genesis)), nil
+	}
+	headers := make([]*types.Header, 0, c.config.Epoch+1)
+	for n := number - c.config.Epoch; n <= number; n++ {
+		header := chain.GetHeaderByNumber(n)
+		if header == nil {
+			return nil, fmt.Errorf("header %d not found", n)
+		}
+		headers = append(headers, header)
+	}
+	return c.epochSnapshot(headers)
+}
+
+// replaySnapshots applies the canonical headers on top of the snapshot up to the
+// given block, and calls back with the snapshots at the blocks wanted.
+func (c *Chaos) replaySnapshots(chain consensus.ChainHeaderReader, snap *Snapshot, to uint64, want func(uint64) bool, fn func(*Snapshot) error) error {
+	var (
+		headers = make([]*types.Header, 0, replayBatch)
+		start   = time.Now()
+		logged  = time.Now()
+		err     error
+	)
+	for n := snap.Number + 1; n <= to; n++ {
+		header := chain.GetHeaderByNumber(n)
+		if header == nil {
+			return fmt.Errorf("header %d not found", n)
+		}
+		headers = append(headers, header)
+		if len(headers) < replayBatch && n < to && !want(n) {
+			continue
+		}
+		if snap, err = snap.apply(headers, chain, nil); err != nil {
+			return fmt.Errorf("failed to apply headers %d-%d: %v", headers[0].Number, n, err)
+		}
+		headers = headers[:0]
+
+		if want(n) {
+			if err := fn(snap); err != nil {
+				return err
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Replaying Chaos snapshots", "number", n, "to", to, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	return nil
+}
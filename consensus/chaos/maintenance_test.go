@@ -0,0 +1,136 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package chaos
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testHeaderReader is a header chain reader backed by the canonical headers in
+// a database.
+type testHeaderReader struct {
+	config *params.ChainConfig
+	db     ethdb.Database
+}
+
+func (r *testHeaderReader) Config() *params.ChainConfig { return r.config }
+func (r *testHeaderReader) CurrentHeader() *types.Header {
+	return rawdb.ReadHeader(r.db, rawdb.ReadHeadHeaderHash(r.db), *rawdb.ReadHeaderNumber(r.db, rawdb.ReadHeadHeaderHash(r.db)))
+}
+func (r *testHeaderReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return rawdb.ReadHeader(r.db, hash, number)
+}
+func (r *testHeaderReader) GetHeaderByNumber(number uint64) *types.Header {
+	return rawdb.ReadHeader(r.db, rawdb.ReadCanonicalHash(r.db, number), number)
+}
+func (r *testHeaderReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	if number := rawdb.ReadHeaderNumber(r.db, hash); number != nil {
+		return rawdb.ReadHeader(r.db, hash, *number)
+	}
+	return nil
+}
+
+// newTestSnapshotChain writes a canonical chain of headers signed in turn by the
+// given validators.
+func newTestSnapshotChain(ap *testerAccountPool, validators []string, epoch uint64, length uint64) ethdb.Database {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		parent common.Hash
+	)
+	for n := uint64(0); n <= length; n++ {
+		header := &types.Header{
+			ParentHash: parent,
+			Number:     new(big.Int).SetUint64(n),
+			Difficulty: big.NewInt(2),
+			Coinbase:   ap.address(validators[int(n)%len(validators)]),
+			Extra:      make([]byte, extraVanity+extraSeal),
+		}
+		if n%epoch == 0 {
+			header.Extra = make([]byte, extraVanity+len(validators)*common.AddressLength+extraSeal)
+			ap.checkpoint(header, validators)
+		}
+		if n > 0 {
+			ap.sign(header)
+		}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), n)
+		rawdb.WriteHeadHeaderHash(db, header.Hash())
+		parent = header.Hash()
+	}
+	return db
+}
+
+func TestSnapshotMaintenance(t *testing.T) {
+	var (
+		ap         = newTesterAccountPool()
+		validators = []string{"A", "B", "C"}
+		config     = &params.ChainConfig{ChainID: big.NewInt(1), Chaos: &params.ChaosConfig{Epoch: 4}}
+		db         = newTestSnapshotChain(ap, validators, 4, checkpointInterval+10)
+		chain      = &testHeaderReader{config: config, db: db}
+		engine     = New(config, db)
+	)
+	// Rebuild the snapshots from the genesis and check they verify
+	written, err := engine.RebuildSnapshots(chain, 0)
+	if err != nil {
+		t.Fatalf("failed to rebuild snapshots: %v", err)
+	}
+	if written != 2 {
+		t.Fatalf("written snapshots mismatch: have %d, want %d", written, 2)
+	}
+	verified, mismatches, err := engine.VerifySnapshots(chain, 0)
+	if err != nil {
+		t.Fatalf("failed to verify snapshots: %v", err)
+	}
+	if verified != 1 || len(mismatches) != 0 {
+		t.Fatalf("verification mismatch: verified %d, mismatched %d", verified, len(mismatches))
+	}
+	// Tamper the stored snapshot and check it's detected
+	snap, err := loadSnapshot(config, engine.signatures, db, chain.GetHeaderByNumber(checkpointInterval).Hash())
+	if err != nil {
+		t.Fatalf("failed to load rebuilt snapshot: %v", err)
+	}
+	delete(snap.Validators, ap.address("A"))
+	if err := snap.store(db); err != nil {
+		t.Fatalf("failed to store tampered snapshot: %v", err)
+	}
+	if _, mismatches, _ = New(config, db).VerifySnapshots(chain, 0); len(mismatches) != 1 {
+		t.Fatalf("tampered snapshot not detected: %d mismatches", len(mismatches))
+	}
+	// Store a side chain snapshot, and prune everything but the newest one
+	side := newSnapshot(config, nil, 5, common.Hash{0x01}, nil)
+	if err := side.store(db); err != nil {
+		t.Fatalf("failed to store side snapshot: %v", err)
+	}
+	pruned, err := PruneSnapshots(db, 0)
+	if err != nil {
+		t.Fatalf("failed to prune snapshots: %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("pruned snapshots mismatch: have %d, want %d", pruned, 2)
+	}
+	stored, _ := StoredSnapshots(db)
+	if len(stored) != 1 || stored[0].Snapshot.Number != checkpointInterval {
+		t.Errorf("remaining snapshots mismatch: %d left", len(stored))
+	}
+}
@@ -0,0 +1,660 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package simulation implements an in-process network of Chaos validators to
+// reproduce consensus incidents in tests.
+//
+// The validators are full eth.Ethereum nodes connected over the simulated devp2p
+// transport of p2p/simulations. The block production is timed by a simulated
+// clock shared by all the engines, which is only moved by the network steps, and
+// every step waits for the network to settle before returning. This way the block
+// times, the turns and the out-of-turn delays are the same as on a real network,
+// while the simulation runs as fast as the blocks can be processed. Each engine
+// sees the shared clock through its own offset, to simulate the clock skews.
+package simulation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/consensus/chaos"
+	"github.com/ethereum/go-ethereum/consensus/chaos/systemcontract"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	serviceName = "chaos" // Name of the validator service in the simulated nodes
+
+	settlePoll    = 5 * time.Millisecond   // Interval to poll the nodes while settling
+	settleQuiet   = 100 * time.Millisecond // Time without changes for the network to be settled
+	settleTimeout = 5 * time.Second        // Maximum time to wait for the network to settle
+)
+
+var errUnknownValidator = errors.New("unknown validator")
+
+// Config contains the parameters of a simulated network.
+type Config struct {
+	Validators int           // Number of validators in the genesis
+	Epoch      uint64        // Chaos epoch length in blocks
+	Tick       time.Duration // Simulated time moved by a network step
+}
+
+// DefaultConfig contains the default parameters of a simulated network.
+var DefaultConfig = Config{
+	Validators: 4,
+	Epoch:      200,
+	Tick:       100 * time.Millisecond,
+}
+
+// Validator is a simulated validator node.
+type Validator struct {
+	ID      enode.ID
+	Key     *ecdsa.PrivateKey
+	Address common.Address
+
+	stack   *node.Node
+	backend *eth.Ethereum
+	clock   *networkClock
+	offline bool
+}
+
+// Chain returns the block chain of the validator.
+func (v *Validator) Chain() *core.BlockChain {
+	return v.backend.BlockChain()
+}
+
+// Head returns the current head header of the validator.
+func (v *Validator) Head() *types.Header {
+	return v.backend.BlockChain().CurrentHeader()
+}
+
+// td returns the total difficulty of the validator's head.
+func (v *Validator) td() *big.Int {
+	head := v.Head()
+	return v.Chain().GetTd(head.Hash(), head.Number.Uint64())
+}
+
+// Finalized returns the number of the last block finalized by the attestations
+// on the validator, zero if none is finalized yet.
+func (v *Validator) Finalized() uint64 {
+	_, finalized := v.backend.BlockChain().FinalityEdges()
+	return finalized.Number.Uint64()
+}
+
+// Network is a simulated network of Chaos validators.
+type Network struct {
+	config  Config
+	genesis *core.Genesis
+	clock   *mclock.Simulated
+	fired   uint64 // Number of engine timers fired, accessed atomically
+
+	adapter    *adapters.SimAdapter
+	net        *simulations.Network
+	validators []*Validator
+	byID       map[enode.ID]*Validator
+}
+
+// NewNetwork creates a network of Chaos validators sharing a genesis, connects
+// them in a full mesh and starts them mining.
+func NewNetwork(config Config) (*Network, error) {
+	if config.Validators < 1 {
+		return nil, errors.New("no validators")
+	}
+	if config.Epoch == 0 {
+		config.Epoch = DefaultConfig.Epoch
+	}
+	if config.Tick == 0 {
+		config.Tick = DefaultConfig.Tick
+	}
+	n := &Network{
+		config: config,
+		clock:  new(mclock.Simulated),
+		byID:   make(map[enode.ID]*Validator),
+	}
+	// Start the simulated clock at the next whole second of the wall clock, so the
+	// block times are aligned with the ticks and the attestation catch-up check,
+	// which uses the wall clock, sees the chain in sync.
+	now := time.Now()
+	n.clock.Run(time.Duration(now.Truncate(time.Second).Add(time.Second).UnixNano()))
+
+	// Create the validator keys and the genesis
+	addrs := make([]common.Address, 0, config.Validators)
+	for i := 0; i < config.Validators; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		v := &Validator{Key: key, Address: crypto.PubkeyToAddress(key.PublicKey)}
+		n.validators = append(n.validators, v)
+		addrs = append(addrs, v.Address)
+	}
+	sort.Sort(systemcontract.AddrAscend(addrs))
+
+	// The basic genesis allocation predates the Gravitation contracts, they are
+	// deployed by the hard fork
+	chainConfig := *params.AllChaosProtocolChanges
+	chainConfig.GravitationBlock = common.Big2
+	chainConfig.Chaos = &params.ChaosConfig{
+		Period:           1,
+		Epoch:            config.Epoch,
+		AttestationDelay: params.AllChaosProtocolChanges.Chaos.AttestationDelay,
+	}
+	n.genesis = core.BasicChaosGenesisBlock(&chainConfig, addrs, n.validators[0].Address)
+
+	// Create the simulated nodes and start them up
+	n.adapter = adapters.NewSimAdapter(adapters.LifecycleConstructors{serviceName: n.newValidatorService})
+	n.net = simulations.NewNetwork(n.adapter, &simulations.NetworkConfig{DefaultService: serviceName})
+
+	for _, v := range n.validators {
+		conf := adapters.RandomNodeConfig()
+		conf.Lifecycles = []string{serviceName}
+		v.ID = conf.ID
+		n.byID[v.ID] = v
+
+		if _, err := n.net.NewNodeWithConfig(conf); err != nil {
+			n.Close()
+			return nil, err
+		}
+		if err := n.net.Start(v.ID); err != nil {
+			n.Close()
+			return nil, err
+		}
+	}
+	if err := n.Heal(); err != nil {
+		n.Close()
+		return nil, err
+	}
+	for _, v := range n.validators {
+		if err := v.backend.StartMining(1); err != nil {
+			n.Close()
+			return nil, err
+		}
+	}
+	// Let the validators schedule their first blocks
+	n.settle()
+	return n, nil
+}
+
+// newValidatorService creates the eth.Ethereum service of a simulated node, with
+// the engine timed by the network clock and the validator key unlocked.
+func (n *Network) newValidatorService(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
+	v := n.byID[ctx.Config.ID]
+	if v == nil {
+		return nil, errUnknownValidator
+	}
+	ks := keystore.NewKeyStore(stack.KeyStoreDir(), keystore.LightScryptN, keystore.LightScryptP)
+	stack.AccountManager().AddBackend(ks)
+	account, err := ks.ImportECDSA(v.Key, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.Unlock(account, ""); err != nil {
+		return nil, err
+	}
+	config := ethconfig.Defaults
+	config.Genesis = n.genesis
+	config.NetworkId = n.genesis.Config.ChainID.Uint64()
+	config.SyncMode = downloader.FullSync
+	config.SnapshotCache = 0
+	config.TxPool.Journal = ""
+	config.Miner.Etherbase = v.Address
+	config.Miner.GasCeil = n.genesis.GasLimit
+
+	backend, err := eth.New(stack, &config)
+	if err != nil {
+		return nil, err
+	}
+	engine, ok := backend.Engine().(*chaos.Chaos)
+	if !ok {
+		return nil, errors.New("engine is not chaos")
+	}
+	clock := &networkClock{Simulated: n.clock, fired: &n.fired}
+	engine.SetClock(clock)
+
+	v.stack, v.backend, v.clock = stack, backend, clock
+	return backend, nil
+}
+
+// Close stops all the nodes of the network.
+func (n *Network) Close() {
+	n.net.Shutdown()
+}
+
+// Validators returns the validators of the network.
+func (n *Network) Validators() []*Validator {
+	return n.validators
+}
+
+// Validator returns the i-th validator of the network.
+func (n *Network) Validator(i int) *Validator {
+	return n.validators[i]
+}
+
+// Now returns the current time of the simulated clock.
+func (n *Network) Now() time.Time {
+	return time.Unix(0, int64(n.clock.Now()))
+}
+
+// Skew sets the offset of a validator's clock from the network time, positive if
+// the clock of the validator is ahead.
+func (n *Network) Skew(i int, offset time.Duration) {
+	atomic.StoreInt64(&n.validators[i].clock.skew, int64(offset))
+}
+
+// Step moves the simulated clock by a tick, and waits for the network to settle
+// if any block was sealed meanwhile.
+func (n *Network) Step() {
+	fired := atomic.LoadUint64(&n.fired)
+	n.clock.Run(n.config.Tick)
+	if atomic.LoadUint64(&n.fired) != fired {
+		n.settle()
+	}
+}
+
+// Run steps the network for the given simulated duration.
+func (n *Network) Run(d time.Duration) {
+	for end := n.Now().Add(d); n.Now().Before(end); {
+		n.Step()
+	}
+}
+
+// RunUntil steps the network until the condition holds, at most for the given
+// simulated duration. It reports whether the condition was met.
+func (n *Network) RunUntil(cond func() bool, limit time.Duration) bool {
+	for end := n.Now().Add(limit); n.Now().Before(end); {
+		if cond() {
+			return true
+		}
+		n.Step()
+	}
+	return cond()
+}
+
+// settle waits until the heads and the finality of the validators stop changing.
+func (n *Network) settle() {
+	var (
+		last     []common.Hash
+		quiet    = time.Now()
+		deadline = time.Now().Add(settleTimeout)
+	)
+	for time.Now().Before(deadline) {
+		state := make([]common.Hash, 0, 2*len(n.validators))
+		for _, v := range n.validators {
+			justified, _ := v.Chain().FinalityEdges()
+			state = append(state, v.Head().Hash(), justified.Hash)
+		}
+		if !equalHashes(state, last) {
+			last, quiet = state, time.Now()
+		} else if time.Since(quiet) >= settleQuiet {
+			if !n.catchUp() {
+				return
+			}
+			quiet = time.Now()
+		}
+		time.Sleep(settlePoll)
+	}
+}
+
+// catchUp syncs the online validators lagging behind a connected validator. The
+// block fetcher and the downloader run on the wall clock, so without a nudge a
+// lagging validator may miss its turns while the simulated clock moves on. It
+// reports whether any validator was synced.
+func (n *Network) catchUp() bool {
+	var synced bool
+	for i, v := range n.validators {
+		if v.offline {
+			continue
+		}
+		var best *Validator
+		for j, other := range n.validators {
+			if j == i || other.offline {
+				continue
+			}
+			if conn := n.net.GetConn(v.ID, other.ID); conn == nil || !conn.Up {
+				continue
+			}
+			if best == nil || other.td().Cmp(best.td()) > 0 {
+				best = other
+			}
+		}
+		if best != nil && best.td().Cmp(v.td()) > 0 {
+			if n.sync(v, best) == nil {
+				synced = true
+			}
+		}
+	}
+	return synced
+}
+
+// sync synchronises a validator with the chain of another one.
+func (n *Network) sync(v, with *Validator) error {
+	head := with.Head()
+	return v.backend.Downloader().Synchronise(with.ID.String(), head.Hash(), with.td(), downloader.FullSync)
+}
+
+// Partition splits the network into the given groups of validator indexes, the
+// validators of different groups are disconnected. The validators left out of
+// all the groups are isolated.
+func (n *Network) Partition(groups ...[]int) error {
+	group := make(map[int]int)
+	for g, members := range groups {
+		for _, i := range members {
+			group[i] = g + 1
+		}
+	}
+	for i := range n.validators {
+		for j := i + 1; j < len(n.validators); j++ {
+			if group[i] != 0 && group[i] == group[j] {
+				continue
+			}
+			if err := n.disconnect(i, j); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Heal connects all the online validators with each other.
+func (n *Network) Heal() error {
+	for i, one := range n.validators {
+		for j := i + 1; j < len(n.validators); j++ {
+			if one.offline || n.validators[j].offline {
+				continue
+			}
+			if err := n.connect(i, j); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Offline takes a validator offline: it stops producing blocks and attesting,
+// and it's disconnected from the network.
+func (n *Network) Offline(i int) error {
+	v := n.validators[i]
+	v.backend.StopMining()
+	v.offline = true
+	for j := range n.validators {
+		if j != i {
+			if err := n.disconnect(i, j); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Online brings an offline validator back, it's connected to all the other online
+// validators and restarts producing blocks once it has synced with them. The
+// attestations restart after it has caught up with the network, as on a restarted
+// node.
+func (n *Network) Online(i int) error {
+	var (
+		v    = n.validators[i]
+		best *Validator
+	)
+	v.offline = false
+	for j, other := range n.validators {
+		if j == i || other.offline {
+			continue
+		}
+		if err := n.connect(i, j); err != nil {
+			return err
+		}
+		if best == nil || other.td().Cmp(best.td()) > 0 {
+			best = other
+		}
+	}
+	// Sync right away rather than waiting for the forced sync cycle, the network
+	// is smaller than the minimum number of peers the chain syncer waits for
+	if best != nil && best.td().Cmp(v.td()) > 0 {
+		if err := n.sync(v, best); err != nil {
+			return fmt.Errorf("failed to sync validator %d: %v", i, err)
+		}
+	}
+	return v.backend.StartMining(1)
+}
+
+// connect connects two validators, waiting for the connection to be up.
+//
+// The connection is set up over a fresh pipe rather than with admin_addPeer, as
+// the devp2p dialer refuses to redial a peer for longer after a disconnection
+// than it takes for a simulated partition to heal.
+func (n *Network) connect(i, j int) error {
+	if i > j {
+		i, j = j, i
+	}
+	one, other := n.validators[i], n.validators[j]
+	if conn := n.net.GetConn(one.ID, other.ID); conn != nil && conn.Up {
+		return nil
+	}
+	dest := other.stack.Server().Self()
+	err := n.changeConn(one.ID, other.ID, true, func() error {
+		fd, err := n.adapter.Dial(context.Background(), dest)
+		if err != nil {
+			return err
+		}
+		go one.stack.Server().SetupConn(fd, 0, dest)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Wait for the eth handshakes too, the validators don't exchange blocks before
+	for deadline := time.Now().Add(settleTimeout); !ethPeered(one, other.ID) || !ethPeered(other, one.ID); time.Sleep(settlePoll) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for eth handshake %s-%s", one.ID.TerminalString(), other.ID.TerminalString())
+		}
+	}
+	return nil
+}
+
+// ethPeered reports whether a validator has completed the eth handshake with a
+// peer.
+func ethPeered(v *Validator, id enode.ID) bool {
+	for _, peer := range v.stack.Server().PeersInfo() {
+		if peer.ID == id.String() {
+			_, pending := peer.Protocols["eth"].(string)
+			return peer.Protocols["eth"] != nil && !pending
+		}
+	}
+	return false
+}
+
+// disconnect disconnects two validators, waiting for the connection to be down.
+func (n *Network) disconnect(i, j int) error {
+	if i > j {
+		i, j = j, i
+	}
+	one, other := n.validators[i].ID, n.validators[j].ID
+	if conn := n.net.GetConn(one, other); conn == nil || !conn.Up {
+		return nil
+	}
+	return n.changeConn(one, other, false, func() error {
+		return n.net.Disconnect(one, other)
+	})
+}
+
+// changeConn runs an action changing the connection between two nodes, and waits
+// for the network to report the connection in the given state.
+func (n *Network) changeConn(one, other enode.ID, up bool, change func() error) error {
+	events := make(chan *simulations.Event, 16)
+	sub := n.net.Events().Subscribe(events)
+	defer sub.Unsubscribe()
+
+	if err := change(); err != nil {
+		return err
+	}
+	timeout := time.NewTimer(settleTimeout)
+	defer timeout.Stop()
+
+	for {
+		if conn := n.net.GetConn(one, other); conn != nil && conn.Up == up {
+			return nil
+		}
+		select {
+		case <-events:
+		case <-timeout.C:
+			return fmt.Errorf("timeout waiting for connection %s-%s up=%v", one.TerminalString(), other.TerminalString(), up)
+		}
+	}
+}
+
+// Equivocate makes a validator sign two attestations of the same target block
+// with different sources, and gossips them from its node like its own ones. The
+// target is the parent of the validator's head, so the validator is expected to
+// be punished for the double signing by the peers receiving them.
+func (n *Network) Equivocate(i int) error {
+	var (
+		signer = n.validators[i]
+		chain  = signer.Chain()
+		head   = chain.CurrentHeader().Number.Uint64()
+	)
+	if head < 3 {
+		return fmt.Errorf("chain too short to equivocate: %d", head)
+	}
+	var (
+		target  = rangeEdge(chain.GetHeaderByNumber(head - 1))
+		sources = []*types.RangeEdge{
+			rangeEdge(chain.GetHeaderByNumber(head - 3)),
+			rangeEdge(chain.GetHeaderByNumber(head - 2)),
+		}
+	)
+	for _, source := range sources {
+		a, err := signAttestation(signer.Key, source, target)
+		if err != nil {
+			return err
+		}
+		chain.BroadcastNewAttestationToOtherNodes(a)
+	}
+	return nil
+}
+
+// MissedBlocks returns the missed blocks counter of a validator in the Staking
+// contract, on the head state of another validator.
+func (n *Network) MissedBlocks(i, on int) (*big.Int, error) {
+	var (
+		chain = n.validators[on].Chain()
+		head  = chain.CurrentBlock()
+	)
+	state, err := chain.StateAt(head.Root())
+	if err != nil {
+		return nil, err
+	}
+	return systemcontract.GetPunishRecord(&systemcontract.CallContext{
+		Statedb:      state,
+		Header:       head.Header(),
+		ChainContext: chain,
+		ChainConfig:  chain.Config(),
+	}, n.validators[i].Address)
+}
+
+// DoubleSignPunished reports whether the canonical chain of a validator contains
+// a double sign punishment of another validator.
+func (n *Network) DoubleSignPunished(i, on int) bool {
+	var (
+		chain  = n.validators[on].Chain()
+		engine = chain.Engine().(*chaos.Chaos)
+		signer = types.LatestSignerForChainID(chain.Config().ChainID)
+	)
+	for number := chain.CurrentBlock().NumberU64(); number > 0; number-- {
+		block := chain.GetBlockByNumber(number)
+		for _, tx := range block.Transactions() {
+			sender, err := types.Sender(signer, tx)
+			if err != nil || !engine.IsDoubleSignPunishTransaction(sender, tx, block.Header()) {
+				continue
+			}
+			var p types.ViolateCasperFFGPunish
+			if err := rlp.DecodeBytes(tx.Data(), &p); err != nil {
+				continue
+			}
+			if defendant, err := p.RecoverSigner(); err == nil && defendant == n.validators[i].Address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// networkClock is the simulated clock of an engine, it's offset from the network
+// time by the skew of the validator, and it counts the timers fired for the
+// network to know when to settle.
+type networkClock struct {
+	*mclock.Simulated
+	fired *uint64
+	skew  int64 // Offset from the network time in nanoseconds, accessed atomically
+}
+
+// Now implements mclock.Clock.
+func (c *networkClock) Now() mclock.AbsTime {
+	return c.Simulated.Now().Add(time.Duration(atomic.LoadInt64(&c.skew)))
+}
+
+// After implements mclock.Clock.
+func (c *networkClock) After(d time.Duration) <-chan mclock.AbsTime {
+	ch := make(chan mclock.AbsTime, 1)
+	c.Simulated.AfterFunc(d, func() {
+		atomic.AddUint64(c.fired, 1)
+		ch <- c.Now()
+	})
+	return ch
+}
+
+func signAttestation(key *ecdsa.PrivateKey, source, target *types.RangeEdge) (*types.Attestation, error) {
+	sig, err := crypto.Sign(types.AttestationSignHash(source, target).Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewAttestation(source, target, sig), nil
+}
+
+func rangeEdge(header *types.Header) *types.RangeEdge {
+	return &types.RangeEdge{Hash: header.Hash(), Number: new(big.Int).Set(header.Number)}
+}
+
+func equalHashes(a, b []common.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
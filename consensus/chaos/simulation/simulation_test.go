@@ -0,0 +1,220 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulation
+
+import (
+	"testing"
+	"time"
+)
+
+// inflightBlocks is the number of blocks the finality may still progress by after
+// a partition, from the attestations already delivered.
+const inflightBlocks = 4
+
+func newTestNetwork(t *testing.T, validators int) *Network {
+	config := DefaultConfig
+	config.Validators = validators
+
+	n, err := NewNetwork(config)
+	if err != nil {
+		t.Fatalf("failed to create network: %v", err)
+	}
+	t.Cleanup(n.Close)
+	return n
+}
+
+// checkInSync checks that the online validators have the same head.
+func checkInSync(t *testing.T, n *Network) {
+	t.Helper()
+
+	var want *Validator
+	for _, v := range n.Validators() {
+		if v.offline {
+			continue
+		}
+		if want == nil {
+			want = v
+			continue
+		}
+		if have := v.Head(); have.Hash() != want.Head().Hash() {
+			t.Fatalf("validator %x out of sync: head %d %x, want %d %x", v.Address, have.Number, have.Hash(), want.Head().Number, want.Head().Hash())
+		}
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	n := newTestNetwork(t, 4)
+
+	n.Run(30 * time.Second)
+	if head := n.Validator(0).Head().Number.Uint64(); head < 28 {
+		t.Fatalf("chain stalled: head %d after 30s", head)
+	}
+	checkInSync(t, n)
+}
+
+// finalized returns the lowest finalized block number of the online validators.
+func finalized(n *Network) uint64 {
+	var lowest uint64
+	for i, v := range n.Validators() {
+		if v.offline {
+			continue
+		}
+		if number := v.Finalized(); i == 0 || number < lowest {
+			lowest = number
+		}
+	}
+	return lowest
+}
+
+func TestFinality(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long simulation in short mode")
+	}
+	n := newTestNetwork(t, 4)
+
+	// The attestations start after the validators have caught up for a while
+	if !n.RunUntil(func() bool { return finalized(n) > 0 }, 300*time.Second) {
+		t.Fatalf("no block finalized: head %d", n.Validator(0).Head().Number)
+	}
+	first := finalized(n)
+	n.Run(30 * time.Second)
+	if last := finalized(n); last <= first {
+		t.Fatalf("finality stalled at %d", last)
+	}
+	checkInSync(t, n)
+}
+
+func TestOfflineValidator(t *testing.T) {
+	n := newTestNetwork(t, 4)
+	if err := n.Offline(2); err != nil {
+		t.Fatalf("failed to take validator offline: %v", err)
+	}
+	n.Run(60 * time.Second)
+	if head := n.Validator(0).Head().Number.Uint64(); head < 50 {
+		t.Fatalf("chain stalled without a validator: head %d after 60s", head)
+	}
+	checkInSync(t, n)
+
+	// The validator missing its turns is punished by the others
+	missed, err := n.MissedBlocks(2, 0)
+	if err != nil {
+		t.Fatalf("failed to read missed blocks: %v", err)
+	}
+	if missed.Sign() == 0 {
+		t.Errorf("offline validator not lazy punished")
+	}
+	for _, i := range []int{0, 1, 3} {
+		if missed, _ := n.MissedBlocks(i, 0); missed.Sign() != 0 {
+			t.Errorf("online validator %d lazy punished: %v", i, missed)
+		}
+	}
+	// Back online, it catches up with the network
+	if err := n.Online(2); err != nil {
+		t.Fatalf("failed to bring validator online: %v", err)
+	}
+	n.Run(10 * time.Second)
+	checkInSync(t, n)
+}
+
+func TestPartition(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping long simulation in short mode")
+	}
+	n := newTestNetwork(t, 5)
+	if !n.RunUntil(func() bool { return finalized(n) > 0 }, 300*time.Second) {
+		t.Fatalf("no block finalized: head %d", n.Validator(0).Head().Number)
+	}
+	// Split the network in a majority, which keeps producing blocks, and a minority
+	// whose validators soon all signed recently. None reaches the attestation
+	// threshold.
+	if err := n.Partition([]int{0, 1, 2}, []int{3, 4}); err != nil {
+		t.Fatalf("failed to partition network: %v", err)
+	}
+	var (
+		stalled = finalized(n)
+		major   = n.Validator(0).Head().Number.Uint64()
+		minor   = n.Validator(3).Head().Number.Uint64()
+	)
+	n.Run(30 * time.Second)
+	if head := n.Validator(0).Head().Number.Uint64(); head < major+20 {
+		t.Errorf("majority partition stalled: head %d", head)
+	}
+	if head := n.Validator(3).Head().Number.Uint64(); head >= minor+20 {
+		t.Errorf("minority partition kept producing blocks: head %d", head)
+	}
+	for _, i := range []int{0, 3} {
+		if number := n.Validator(i).Finalized(); number > stalled+inflightBlocks {
+			t.Errorf("partition of validator %d finalized block %d", i, number)
+		}
+	}
+	// Once healed, the network converges and the finality resumes
+	if err := n.Heal(); err != nil {
+		t.Fatalf("failed to heal network: %v", err)
+	}
+	if !n.RunUntil(func() bool { return finalized(n) > stalled+inflightBlocks }, 120*time.Second) {
+		t.Fatalf("finality didn't resume after healing: finalized %d", finalized(n))
+	}
+	checkInSync(t, n)
+}
+
+// TestClockSkew checks that a validator with a skewed clock doesn't stall the
+// others. Its blocks, or the others' blocks, are from the future on one side or
+// the other and rejected by the header verification, so the skewed validator may
+// fall behind, but the rest of the network keeps sealing in sync.
+func TestClockSkew(t *testing.T) {
+	for _, skew := range []time.Duration{-700 * time.Millisecond, 300 * time.Millisecond} {
+		n := newTestNetwork(t, 4)
+		n.Skew(1, skew)
+
+		n.Run(30 * time.Second)
+		want := n.Validator(0).Head()
+		if head := want.Number.Uint64(); head < 25 {
+			t.Fatalf("chain stalled with a clock skewed by %v: head %d after 30s", skew, head)
+		}
+		for _, i := range []int{2, 3} {
+			if have := n.Validator(i).Head(); have.Hash() != want.Hash() {
+				t.Fatalf("validator %d out of sync with a clock skewed by %v: head %d %x, want %d %x", i, skew, have.Number, have.Hash(), want.Number, want.Hash())
+			}
+		}
+	}
+}
+
+func TestEquivocation(t *testing.T) {
+	n := newTestNetwork(t, 4)
+	n.Run(10 * time.Second)
+
+	if err := n.Equivocate(1); err != nil {
+		t.Fatalf("failed to equivocate: %v", err)
+	}
+	punished := func() bool {
+		for i := range n.Validators() {
+			if !n.DoubleSignPunished(1, i) {
+				return false
+			}
+		}
+		return true
+	}
+	if !n.RunUntil(punished, 60*time.Second) {
+		t.Fatalf("double signing not punished")
+	}
+	for _, i := range []int{0, 2, 3} {
+		if n.DoubleSignPunished(i, 0) {
+			t.Errorf("honest validator %d punished", i)
+		}
+	}
+	checkInSync(t, n)
+}
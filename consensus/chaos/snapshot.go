@@ -30,6 +30,15 @@ import (
 	lru "github.com/hashicorp/golang-lru"
 )
 
+// snapshotPrefix is the database key prefix of the stored snapshots, followed by
+// the block hash.
+var snapshotPrefix = []byte("chaos-")
+
+// snapshotKey = snapshotPrefix + hash
+func snapshotKey(hash common.Hash) []byte {
+	return append(append([]byte{}, snapshotPrefix...), hash.Bytes()...)
+}
+
 // Snapshot is the state of the authorization voting at a given point in time.
 type Snapshot struct {
 	config   *params.ChainConfig // Consensus engine parameters to fine tune behavior
@@ -61,7 +70,7 @@ func newSnapshot(config *params.ChainConfig, sigcache *lru.ARCCache, number uint
 
 // loadSnapshot loads an existing snapshot from the database.
 func loadSnapshot(config *params.ChainConfig, sigcache *lru.ARCCache, db ethdb.Database, hash common.Hash) (*Snapshot, error) {
-	blob, err := db.Get(append([]byte("chaos-"), hash[:]...))
+	blob, err := db.Get(snapshotKey(hash))
 	if err != nil {
 		return nil, err
 	}
@@ -81,7 +90,7 @@ func (s *Snapshot) store(db ethdb.Database) error {
 	if err != nil {
 		return err
 	}
-	return db.Put(append([]byte("chaos-"), s.Hash[:]...), blob)
+	return db.Put(snapshotKey(s.Hash), blob)
 }
 
 // copy creates a deep copy of the snapshot, though not the individual votes.
@@ -228,6 +237,13 @@ func (s *Snapshot) inturn(number uint64, validator common.Address) bool {
 	return (number%(uint64(len(validators))*continousInturn))/continousInturn == uint64(offset)
 }
 
+// inturnValidator returns the in-turn validator at a given block height.
+func (s *Snapshot) inturnValidator(number uint64) common.Address {
+	validators := s.validators()
+	continousInturn := s.config.ChaosContinuousInturn(big.NewInt(int64(number)))
+	return validators[(number%(uint64(len(validators))*continousInturn))/continousInturn]
+}
+
 func (s *Snapshot) IsAuthorized(addr common.Address) bool {
 	_, exist := s.Validators[addr]
 	return exist
@@ -121,3 +121,35 @@ func TestSnapshot_inturn(t *testing.T) {
 		})
 	}
 }
+
+func TestFirstInturnBlock(t *testing.T) {
+	f := genFields(100)
+	s := &Snapshot{config: f.config, Number: f.Number, Validators: f.Validators, Recents: f.Recents}
+	validators := s.validators()
+	continuousInturn := f.config.ChaosContinuousInturn(big.NewInt(100))
+
+	tests := []struct {
+		name      string
+		validator common.Address
+		from      uint64
+		want      uint64
+	}{
+		{"case1", validatorAddress(4), 100, 100},
+		{"case2", validatorAddress(4), 102, 102},
+		{"case3", validatorAddress(4), 104, 184},
+		{"case4", validatorAddress(5), 100, 104},
+		{"case5", validatorAddress(0), 4, 84},
+		{"case6", validatorAddress(99), 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := firstInturnBlock(validators, tt.validator, tt.from, continuousInturn)
+			if got != tt.want {
+				t.Errorf("firstInturnBlock() = %v, want %v", got, tt.want)
+			}
+			if got != 0 && !s.inturn(got, tt.validator) {
+				t.Errorf("validator is not inturn at block %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the Cube library.
+//
+// The Cube library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The Cube library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the Cube library. If not, see <http://www.gnu.org/licenses/>.
+
+package chaos
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/chaos/systemcontract"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// errStateUnavailable is returned if the engine has no access to the chain state.
+var errStateUnavailable = errors.New("state is unavailable")
+
+// StakingAPI is a user facing RPC API to query the validators, delegations and
+// rewards info stored in the system contracts.
+type StakingAPI struct {
+	chain consensus.ChainHeaderReader
+	chaos *Chaos
+}
+
+// RPCValidator is the validator info returned by the staking API.
+type RPCValidator struct {
+	Validator        common.Address `json:"validator"`
+	Contract         common.Address `json:"contract"`
+	Manager          common.Address `json:"manager"`
+	State            string         `json:"state"`
+	Jailed           bool           `json:"jailed"`
+	Active           bool           `json:"active"`
+	SelfStakeGWei    *hexutil.Big   `json:"selfStakeGWei"`
+	TotalStakeGWei   *hexutil.Big   `json:"totalStakeGWei"`
+	TotalUnWithdrawn *hexutil.Big   `json:"totalUnWithdrawn"`
+	CommissionRate   *hexutil.Big   `json:"commissionRate"`
+	AcceptDelegation bool           `json:"acceptDelegation"`
+	MissedBlocks     *hexutil.Big   `json:"missedBlocks"`
+	PunishBlock      *hexutil.Big   `json:"punishBlock"`
+}
+
+// RPCDelegation is the delegation info of an account returned by the staking API.
+type RPCDelegation struct {
+	Validator   common.Address `json:"validator"`
+	StakeGWei   *hexutil.Big   `json:"stakeGWei"`
+	Settled     *hexutil.Big   `json:"settled"`
+	Debt        *hexutil.Big   `json:"debt"`
+	UnWithdrawn *hexutil.Big   `json:"unWithdrawn"`
+}
+
+// RPCPendingRewards is the pending rewards of an account on a validator.
+type RPCPendingRewards struct {
+	Validator common.Address `json:"validator"`
+	Rewards   *hexutil.Big   `json:"rewards"`
+	Claimable *hexutil.Big   `json:"claimable"`
+}
+
+// RPCRewardsSchedule is the rewards schedule of the chain.
+type RPCRewardsSchedule struct {
+	Rule            uint64         `json:"rule"`
+	BlocksPerMonth  hexutil.Uint64 `json:"blocksPerMonth"`
+	CurrentMonth    hexutil.Uint64 `json:"currentMonth"`
+	RewardsPerBlock *hexutil.Big   `json:"rewardsPerBlock"`
+	RewardsByMonth  []*hexutil.Big `json:"rewardsByMonth"`
+}
+
+// RPCGenesisLock is the genesis lock release status of an account.
+type RPCGenesisLock struct {
+	Account          common.Address `json:"account"`
+	TypeId           *hexutil.Big   `json:"typeId"`
+	LockedAmount     *hexutil.Big   `json:"lockedAmount"`
+	LockedTime       *hexutil.Big   `json:"lockedTime"`
+	PeriodAmount     *hexutil.Big   `json:"periodAmount"`
+	PeriodTime       *hexutil.Big   `json:"periodTime"`
+	ClaimedPeriods   *hexutil.Big   `json:"claimedPeriods"`
+	Claimable        *hexutil.Big   `json:"claimable"`
+	ClaimablePeriods *hexutil.Big   `json:"claimablePeriods"`
+}
+
+// callContext returns a call context of the system contracts at the given block.
+func (api *StakingAPI) callContext(number *rpc.BlockNumber) (*systemcontract.CallContext, error) {
+	// Retrieve the requested block number (or current if none requested)
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	if api.chaos.stateFn == nil {
+		return nil, errStateUnavailable
+	}
+	statedb, err := api.chaos.stateFn(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	return &systemcontract.CallContext{
+		Statedb:      statedb,
+		Header:       header,
+		ChainContext: newChainContext(api.chain, api.chaos),
+		ChainConfig:  api.chaos.chainConfig,
+	}, nil
+}
+
+// GetValidator retrieves the staking info of a validator at the specified block.
+func (api *StakingAPI) GetValidator(validator common.Address, number *rpc.BlockNumber) (*RPCValidator, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	actives, err := systemcontract.GetActiveValidators(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return api.validator(ctx, validator, actives)
+}
+
+// GetValidators retrieves the staking info of all the registered validators at the specified block.
+func (api *StakingAPI) GetValidators(number *rpc.BlockNumber) ([]*RPCValidator, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	actives, err := systemcontract.GetActiveValidators(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := systemcontract.GetAllValidators(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*RPCValidator, 0, len(vals))
+	for _, val := range vals {
+		info, err := api.validator(ctx, val, actives)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// GetActiveValidators retrieves the active validators recorded in Staking contract at the specified block.
+func (api *StakingAPI) GetActiveValidators(number *rpc.BlockNumber) ([]common.Address, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	return systemcontract.GetActiveValidators(ctx)
+}
+
+// GetTotalStake retrieves the total stakes in GWei at the specified block.
+func (api *StakingAPI) GetTotalStake(number *rpc.BlockNumber) (*hexutil.Big, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	total, err := systemcontract.GetTotalStakeGWei(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(total), nil
+}
+
+// GetDelegations retrieves all the delegations of an account at the specified block.
+func (api *StakingAPI) GetDelegations(account common.Address, number *rpc.BlockNumber) ([]*RPCDelegation, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := systemcontract.GetAllValidators(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*RPCDelegation, 0)
+	for _, val := range vals {
+		contract, err := systemcontract.GetValidatorContract(ctx, val)
+		if err != nil {
+			return nil, err
+		}
+		if contract == (common.Address{}) {
+			continue
+		}
+		d, err := systemcontract.GetDelegation(ctx, contract, account)
+		if err != nil {
+			return nil, err
+		}
+		if d.StakeGWei.Sign() == 0 && d.UnWithdrawn.Sign() == 0 {
+			continue
+		}
+		results = append(results, &RPCDelegation{
+			Validator:   val,
+			StakeGWei:   (*hexutil.Big)(d.StakeGWei),
+			Settled:     (*hexutil.Big)(d.Settled),
+			Debt:        (*hexutil.Big)(d.Debt),
+			UnWithdrawn: (*hexutil.Big)(d.UnWithdrawn),
+		})
+	}
+	return results, nil
+}
+
+// GetPendingRewards retrieves the pending rewards of an account, as a validator manager
+// or a delegator, on every validator at the specified block.
+func (api *StakingAPI) GetPendingRewards(account common.Address, number *rpc.BlockNumber) ([]*RPCPendingRewards, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := systemcontract.GetAllValidators(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*RPCPendingRewards, 0)
+	for _, val := range vals {
+		rewards, err := systemcontract.GetClaimableRewards(ctx, val, account)
+		if err != nil {
+			return nil, err
+		}
+		claimable, err := systemcontract.GetAnyClaimable(ctx, val, account)
+		if err != nil {
+			return nil, err
+		}
+		if rewards.Sign() == 0 && claimable.Sign() == 0 {
+			continue
+		}
+		results = append(results, &RPCPendingRewards{
+			Validator: val,
+			Rewards:   (*hexutil.Big)(rewards),
+			Claimable: (*hexutil.Big)(claimable),
+		})
+	}
+	return results, nil
+}
+
+// GetRewardsSchedule retrieves the monthly rewards schedule and the rewards per block
+// at the specified block.
+func (api *StakingAPI) GetRewardsSchedule(number *rpc.BlockNumber) (*RPCRewardsSchedule, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	perBlock, err := systemcontract.GetCurrRewardsPerBlock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rule := api.chaos.chainConfig.Chaos.Rule
+	byMonth := core.RewardsByMonth(rule)
+	schedule := &RPCRewardsSchedule{
+		Rule:            rule,
+		BlocksPerMonth:  hexutil.Uint64(systemcontract.BlocksPerMonth()),
+		CurrentMonth:    hexutil.Uint64(ctx.Header.Number.Uint64() / systemcontract.BlocksPerMonth()),
+		RewardsPerBlock: (*hexutil.Big)(perBlock),
+		RewardsByMonth:  make([]*hexutil.Big, len(byMonth)),
+	}
+	for i, rewards := range byMonth {
+		schedule.RewardsByMonth[i] = (*hexutil.Big)(new(big.Int).Set(rewards))
+	}
+	return schedule, nil
+}
+
+// GetGenesisLock retrieves the genesis lock release status of an account at the specified block.
+func (api *StakingAPI) GetGenesisLock(account common.Address, number *rpc.BlockNumber) (*RPCGenesisLock, error) {
+	ctx, err := api.callContext(number)
+	if err != nil {
+		return nil, err
+	}
+	info, err := systemcontract.GetGenesisLockInfo(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return &RPCGenesisLock{
+		Account:          account,
+		TypeId:           (*hexutil.Big)(info.TypeId),
+		LockedAmount:     (*hexutil.Big)(info.LockedAmount),
+		LockedTime:       (*hexutil.Big)(info.LockedTime),
+		PeriodAmount:     (*hexutil.Big)(info.PeriodAmount),
+		PeriodTime:       (*hexutil.Big)(info.PeriodTime),
+		ClaimedPeriods:   (*hexutil.Big)(info.ClaimedPeriods),
+		Claimable:        (*hexutil.Big)(info.Claimable),
+		ClaimablePeriods: (*hexutil.Big)(info.ClaimablePeriods),
+	}, nil
+}
+
+// validator converts the validator info from system contracts to RPC format.
+func (api *StakingAPI) validator(ctx *systemcontract.CallContext, val common.Address, actives []common.Address) (*RPCValidator, error) {
+	info, err := systemcontract.GetValidatorInfo(ctx, val)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	active := false
+	for _, a := range actives {
+		if a == val {
+			active = true
+			break
+		}
+	}
+	return &RPCValidator{
+		Validator:        info.Validator,
+		Contract:         info.Contract,
+		Manager:          info.Manager,
+		State:            validatorStateName(info.State),
+		Jailed:           info.State == systemcontract.ValidatorStateJail,
+		Active:           active,
+		SelfStakeGWei:    (*hexutil.Big)(info.SelfStakeGWei),
+		TotalStakeGWei:   (*hexutil.Big)(info.TotalStake),
+		TotalUnWithdrawn: (*hexutil.Big)(info.TotalUnWithdrawn),
+		CommissionRate:   (*hexutil.Big)(info.CommissionRate),
+		AcceptDelegation: info.AcceptDelegation,
+		MissedBlocks:     (*hexutil.Big)(info.MissedBlocks),
+		PunishBlock:      (*hexutil.Big)(info.PunishBlock),
+	}, nil
+}
+
+func validatorStateName(state uint8) string {
+	switch state {
+	case systemcontract.ValidatorStateIdle:
+		return "idle"
+	case systemcontract.ValidatorStateReady:
+		return "ready"
+	case systemcontract.ValidatorStateJail:
+		return "jail"
+	case systemcontract.ValidatorStateExit:
+		return "exit"
+	default:
+		return "unknown"
+	}
+}
@@ -22,6 +22,11 @@ var (
 	blocksPerMonth = big.NewInt(60 * 60 * 24 / 3 * 30)
 )
 
+// BlocksPerMonth returns the number of blocks per month used by the rewards schedule
+func BlocksPerMonth() uint64 {
+	return blocksPerMonth.Uint64()
+}
+
 // AddrAscend implements the sort interface to allow sorting a list of addresses
 type AddrAscend []common.Address
 
@@ -21,6 +21,7 @@ type CallContext struct {
 	Header       *types.Header
 	ChainContext core.ChainContext
 	ChainConfig  *params.ChainConfig
+	Tracer       vm.EVMLogger // EVM logger to trace the call with, nil for none
 }
 
 // CallContract executes transaction sent to system contracts.
@@ -33,7 +34,7 @@ func CallContractWithValue(ctx *CallContext, from common.Address, to *common.Add
 	evm := vm.NewEVM(core.NewEVMBlockContext(ctx.Header, ctx.ChainContext, nil), vm.TxContext{
 		Origin:   from,
 		GasPrice: big.NewInt(0),
-	}, ctx.Statedb, ctx.ChainConfig, vm.Config{})
+	}, ctx.Statedb, ctx.ChainConfig, vm.Config{Debug: ctx.Tracer != nil, Tracer: ctx.Tracer})
 
 	ret, _, err = evm.Call(vm.AccountRef(from), *to, data, math.MaxUint64, value)
 	// Finalise the statedb so any changes can take effect,
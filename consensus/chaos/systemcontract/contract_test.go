@@ -841,3 +841,64 @@ func (c *MockConsensusEngine) Close() error {
 func (c *MockConsensusEngine) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 	return []rpc.API{}
 }
+
+func TestGetAllValidators(t *testing.T) {
+	ctx, err := initCallContext()
+	assert.NoError(t, err, "Init call context error")
+
+	vals, err := GetAllValidators(ctx)
+	if assert.NoError(t, err) {
+		assert.ElementsMatch(t, GenesisValidators, vals)
+	}
+
+	assert.NoError(t, UpdateActiveValidatorSet(ctx, GenesisValidators))
+	actives, err := GetActiveValidators(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, GenesisValidators, actives)
+	}
+}
+
+func TestGetValidatorInfo(t *testing.T) {
+	ctx, err := initCallContext()
+	assert.NoError(t, err, "Init call context error")
+
+	info, err := GetValidatorInfo(ctx, GenesisValidators[0])
+	if assert.NoError(t, err) && assert.NotNil(t, info) {
+		assert.Equal(t, GenesisValidators[0], info.Validator)
+		assert.Equal(t, ValidatorStateReady, info.State)
+	}
+
+	assert.NoError(t, LazyPunish(ctx, GenesisValidators[0]))
+	info, err = GetValidatorInfo(ctx, GenesisValidators[0])
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, int(info.MissedBlocks.Int64()))
+	}
+
+	// unregistered validator
+	info, err = GetValidatorInfo(ctx, common.BigToAddress(big.NewInt(111)))
+	assert.NoError(t, err)
+	assert.Nil(t, info)
+
+	d, err := GetDelegation(ctx, mustValidatorContract(t, ctx, GenesisValidators[0]), common.BigToAddress(big.NewInt(111)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, d.StakeGWei.Sign())
+	}
+}
+
+func TestGetGenesisLockInfo(t *testing.T) {
+	ctx, err := initCallContext()
+	assert.NoError(t, err, "Init call context error")
+
+	info, err := GetGenesisLockInfo(ctx, common.BigToAddress(big.NewInt(111)))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, info.LockedAmount.Sign())
+		assert.Equal(t, 0, info.Claimable.Sign())
+		assert.Equal(t, 2592000, int(info.PeriodTime.Int64()))
+	}
+}
+
+func mustValidatorContract(t *testing.T, ctx *CallContext, val common.Address) common.Address {
+	contract, err := GetValidatorContract(ctx, val)
+	assert.NoError(t, err)
+	return contract
+}
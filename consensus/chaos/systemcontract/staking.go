@@ -0,0 +1,312 @@
+package systemcontract
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/system"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Validator states, same as the enum `State` defined in Validator contract
+const (
+	ValidatorStateIdle uint8 = iota
+	ValidatorStateReady
+	ValidatorStateJail
+	ValidatorStateExit
+)
+
+// ValidatorInfo contains the staking info of a validator
+type ValidatorInfo struct {
+	Validator        common.Address
+	Contract         common.Address // address of the Validator contract
+	Manager          common.Address
+	State            uint8
+	SelfStakeGWei    *big.Int
+	TotalStake       *big.Int // total stakes in GWei, including delegations
+	TotalUnWithdrawn *big.Int
+	CommissionRate   *big.Int
+	AcceptDelegation bool
+	MissedBlocks     *big.Int // missed blocks counter in Staking contract
+	PunishBlock      *big.Int
+}
+
+// Delegation contains the staking info of a delegator on a validator
+type Delegation struct {
+	StakeGWei   *big.Int
+	Settled     *big.Int
+	Debt        *big.Int
+	UnWithdrawn *big.Int
+	PunishFree  *big.Int
+}
+
+// GenesisLockInfo contains the genesis lock info of an account
+type GenesisLockInfo struct {
+	TypeId           *big.Int
+	LockedAmount     *big.Int
+	LockedTime       *big.Int
+	PeriodAmount     *big.Int
+	ClaimedPeriods   *big.Int
+	PeriodTime       *big.Int
+	ClaimablePeriods *big.Int
+	Claimable        *big.Int
+}
+
+// GetActiveValidators return the result of calling method `getActiveValidators` in Staking contract
+func GetActiveValidators(ctx *CallContext) ([]common.Address, error) {
+	const method = "getActiveValidators"
+	result, err := contractRead(ctx, system.StakingContract, method)
+	if err != nil {
+		log.Error("GetActiveValidators contractRead failed", "err", err)
+		return []common.Address{}, err
+	}
+	validators, ok := result.([]common.Address)
+	if !ok {
+		return []common.Address{}, errors.New("GetActiveValidators: invalid validator format")
+	}
+	return validators, nil
+}
+
+// GetAllValidators return all the validators ever registered in Staking contract
+func GetAllValidators(ctx *CallContext) ([]common.Address, error) {
+	result, err := contractRead(ctx, system.StakingContract, "getAllValidatorsLength")
+	if err != nil {
+		log.Error("GetAllValidators contractRead failed", "err", err)
+		return []common.Address{}, err
+	}
+	length, ok := result.(*big.Int)
+	if !ok {
+		return []common.Address{}, errors.New("GetAllValidators: invalid length format")
+	}
+	validators := make([]common.Address, 0, length.Uint64())
+	for i := uint64(0); i < length.Uint64(); i++ {
+		result, err := contractRead(ctx, system.StakingContract, "allValidatorAddrs", new(big.Int).SetUint64(i))
+		if err != nil {
+			log.Error("GetAllValidators contractRead failed", "index", i, "err", err)
+			return []common.Address{}, err
+		}
+		val, ok := result.(common.Address)
+		if !ok {
+			return []common.Address{}, errors.New("GetAllValidators: invalid validator format")
+		}
+		validators = append(validators, val)
+	}
+	return validators, nil
+}
+
+// GetValidatorContract return the address of the Validator contract of the given validator,
+// a zero address will be returned if the validator is not registered.
+func GetValidatorContract(ctx *CallContext, val common.Address) (common.Address, error) {
+	const method = "valMaps"
+	result, err := contractRead(ctx, system.StakingContract, method, val)
+	if err != nil {
+		log.Error("GetValidatorContract contractRead failed", "validator", val, "err", err)
+		return common.Address{}, err
+	}
+	contract, ok := result.(common.Address)
+	if !ok {
+		return common.Address{}, errors.New("GetValidatorContract: invalid result format")
+	}
+	return contract, nil
+}
+
+// GetPunishRecord return the missed blocks counter of the given validator
+func GetPunishRecord(ctx *CallContext, val common.Address) (*big.Int, error) {
+	return readBigInt(ctx, system.StakingContract, "getPunishRecord", val)
+}
+
+// GetTotalStakeGWei return the total stakes in Staking contract
+func GetTotalStakeGWei(ctx *CallContext) (*big.Int, error) {
+	return readBigInt(ctx, system.StakingContract, "totalStakeGWei")
+}
+
+// GetCurrRewardsPerBlock return the current rewards per block in Staking contract
+func GetCurrRewardsPerBlock(ctx *CallContext) (*big.Int, error) {
+	return readBigInt(ctx, system.StakingContract, "currRewardsPerBlock")
+}
+
+// GetClaimableRewards return the claimable rewards of a stake owner on the given validator
+func GetClaimableRewards(ctx *CallContext, val common.Address, owner common.Address) (*big.Int, error) {
+	return readBigInt(ctx, system.StakingContract, "claimableRewards", val, owner)
+}
+
+// GetAnyClaimable return all the claimable amount, including rewards and unbound stakes,
+// of a stake owner on the given validator
+func GetAnyClaimable(ctx *CallContext, val common.Address, owner common.Address) (*big.Int, error) {
+	return readBigInt(ctx, system.StakingContract, "anyClaimable", val, owner)
+}
+
+// GetValidatorInfo return the staking info of the given validator,
+// a nil info will be returned if the validator is not registered.
+func GetValidatorInfo(ctx *CallContext, val common.Address) (*ValidatorInfo, error) {
+	contract, err := GetValidatorContract(ctx, val)
+	if err != nil || contract == (common.Address{}) {
+		return nil, err
+	}
+	info := &ValidatorInfo{
+		Validator: val,
+		Contract:  contract,
+	}
+	if info.Manager, err = readValidatorAddress(ctx, contract, "manager"); err != nil {
+		return nil, err
+	}
+	if info.SelfStakeGWei, err = readValidatorBigInt(ctx, contract, "selfStakeGWei"); err != nil {
+		return nil, err
+	}
+	if info.TotalStake, err = readValidatorBigInt(ctx, contract, "totalStake"); err != nil {
+		return nil, err
+	}
+	if info.TotalUnWithdrawn, err = readValidatorBigInt(ctx, contract, "totalUnWithdrawn"); err != nil {
+		return nil, err
+	}
+	if info.CommissionRate, err = readValidatorBigInt(ctx, contract, "commissionRate"); err != nil {
+		return nil, err
+	}
+	if info.PunishBlock, err = readValidatorBigInt(ctx, contract, "punishBlk"); err != nil {
+		return nil, err
+	}
+	result, err := validatorContractRead(ctx, contract, "state")
+	if err != nil {
+		return nil, err
+	}
+	if info.State, err = toUint8(result); err != nil {
+		return nil, err
+	}
+	if result, err = validatorContractRead(ctx, contract, "acceptDelegation"); err != nil {
+		return nil, err
+	}
+	accept, ok := result.(bool)
+	if !ok {
+		return nil, errors.New("GetValidatorInfo: invalid acceptDelegation format")
+	}
+	info.AcceptDelegation = accept
+	if info.MissedBlocks, err = GetPunishRecord(ctx, val); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetDelegation return the delegation of a delegator on the given Validator contract
+func GetDelegation(ctx *CallContext, contract common.Address, delegator common.Address) (*Delegation, error) {
+	const method = "delegators"
+	abi := system.ValidatorContractABI()
+	result, err := contractReadBytes(ctx, contract, &abi, method, delegator)
+	if err != nil {
+		log.Error("GetDelegation contractReadBytes failed", "contract", contract, "delegator", delegator, "err", err)
+		return nil, err
+	}
+	delegation := &Delegation{}
+	if err = abi.UnpackIntoInterface(delegation, method, result); err != nil {
+		log.Error("GetDelegation UnpackIntoInterface failed", "contract", contract, "delegator", delegator, "err", err)
+		return nil, err
+	}
+	return delegation, nil
+}
+
+// GetGenesisLockInfo return the lock info of the given account in GenesisLock contract
+func GetGenesisLockInfo(ctx *CallContext, account common.Address) (*GenesisLockInfo, error) {
+	const method = "getUserInfo"
+	abi := system.ABI(system.GenesisLockContract)
+	result, err := contractReadBytes(ctx, system.GenesisLockContract, &abi, method, account)
+	if err != nil {
+		log.Error("GetGenesisLockInfo contractReadBytes failed", "account", account, "err", err)
+		return nil, err
+	}
+	var userInfo struct {
+		TypId          *big.Int
+		LockedAmount   *big.Int
+		LockedTime     *big.Int
+		PeriodAmount   *big.Int
+		ClaimedPeriods *big.Int
+	}
+	if err = abi.UnpackIntoInterface(&userInfo, method, result); err != nil {
+		log.Error("GetGenesisLockInfo UnpackIntoInterface failed", "account", account, "err", err)
+		return nil, err
+	}
+	info := &GenesisLockInfo{
+		TypeId:         userInfo.TypId,
+		LockedAmount:   userInfo.LockedAmount,
+		LockedTime:     userInfo.LockedTime,
+		PeriodAmount:   userInfo.PeriodAmount,
+		ClaimedPeriods: userInfo.ClaimedPeriods,
+	}
+	if info.PeriodTime, err = readBigInt(ctx, system.GenesisLockContract, "periodTime"); err != nil {
+		return nil, err
+	}
+	info.ClaimablePeriods, info.Claimable = new(big.Int), new(big.Int)
+	// Nothing to release for accounts without any lock
+	if info.LockedAmount.Sign() == 0 {
+		return info, nil
+	}
+	if info.ClaimablePeriods, err = readBigInt(ctx, system.GenesisLockContract, "getClaimablePeriod", account); err != nil {
+		return nil, err
+	}
+	info.Claimable.Mul(info.ClaimablePeriods, info.PeriodAmount)
+	return info, nil
+}
+
+// readBigInt reads a single uint256 value from system contract
+func readBigInt(ctx *CallContext, contract common.Address, method string, args ...interface{}) (*big.Int, error) {
+	result, err := contractRead(ctx, contract, method, args...)
+	if err != nil {
+		log.Error("contractRead failed", "method", method, "err", err)
+		return nil, err
+	}
+	value, ok := result.(*big.Int)
+	if !ok {
+		return nil, errors.New(method + ": invalid result format")
+	}
+	return value, nil
+}
+
+// validatorContractRead perform read on a Validator contract
+func validatorContractRead(ctx *CallContext, contract common.Address, method string, args ...interface{}) (interface{}, error) {
+	abi := system.ValidatorContractABI()
+	result, err := contractReadBytes(ctx, contract, &abi, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := abi.Unpack(method, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) != 1 {
+		return nil, errors.New(method + ": invalid result length")
+	}
+	return ret[0], nil
+}
+
+// readValidatorBigInt reads a single uint256 value from a Validator contract
+func readValidatorBigInt(ctx *CallContext, contract common.Address, method string) (*big.Int, error) {
+	result, err := validatorContractRead(ctx, contract, method)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := result.(*big.Int)
+	if !ok {
+		return nil, errors.New(method + ": invalid result format")
+	}
+	return value, nil
+}
+
+// readValidatorAddress reads a single address value from a Validator contract
+func readValidatorAddress(ctx *CallContext, contract common.Address, method string) (common.Address, error) {
+	result, err := validatorContractRead(ctx, contract, method)
+	if err != nil {
+		return common.Address{}, err
+	}
+	value, ok := result.(common.Address)
+	if !ok {
+		return common.Address{}, errors.New(method + ": invalid result format")
+	}
+	return value, nil
+}
+
+func toUint8(v interface{}) (uint8, error) {
+	value, ok := v.(uint8)
+	if !ok {
+		return 0, errors.New("invalid uint8 format")
+	}
+	return value, nil
+}
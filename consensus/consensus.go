@@ -152,6 +152,9 @@ type ChaosEngine interface {
 
 	// CurrentValidator Get the verifier address in the current consensus
 	CurrentValidator() common.Address
+	// SignData signs the data with the key of the current validator, the signer
+	// can be recovered from the signature of keccak256(data).
+	SignData(data []byte) ([]byte, error)
 	MaxValidators() uint8
 
 	// Attest trys to give an attestation on current chain when a ChainHeadEvent is fired.
@@ -171,6 +174,11 @@ type ChaosEngine interface {
 
 	// CalculateGasPool calculate the expected max gas used for a block
 	CalculateGasPool(header *types.Header) uint64
+	// GasBudget calculate the expected max gas used for a block with the given number and gas limit
+	GasBudget(number *big.Int, gasLimit uint64) uint64
+	// MaxGasBudget returns the largest gas budget of the blocks around the given number,
+	// a transaction needs more gas than this will never be packed.
+	MaxGasBudget(number *big.Int, gasLimit uint64) uint64
 
 	GetDb() ethdb.Database
 
@@ -181,11 +189,18 @@ type ChaosEngine interface {
 	// ExtraValidateOfTx do some consensus related validation to a given transaction.
 	ExtraValidateOfTx(sender common.Address, tx *types.Transaction, header *types.Header) error
 
+	// IsPriorityTx checks whether a transaction is a validator operation that
+	// should be packed before the normal transactions.
+	IsPriorityTx(sender common.Address, tx *types.Transaction, header *types.Header) bool
+
 	ApplyDoubleSignPunishTx(evm *vm.EVM, sender common.Address, tx *types.Transaction) (ret []byte, vmerr error, err error)
 
 	// IsSysTransaction checks whether a specific transaction is a system transaction.
 	IsSysTransaction(sender common.Address, tx *types.Transaction, header *types.Header) bool
 
+	// IsProposalTransaction checks whether a system transaction executes a passed proposal.
+	IsProposalTransaction(sender common.Address, tx *types.Transaction, header *types.Header) bool
+
 	// CanCreate determines where a given address can create a new contract.
 	CanCreate(state StateReader, addr common.Address, isContract bool, height *big.Int) bool
 
@@ -200,6 +215,12 @@ type ChaosEngine interface {
 	// ApplyProposalTx applies a system-transaction using a given evm,
 	// the main purpose of this method is for tracing a system-transaction.
 	ApplyProposalTx(evm *vm.EVM, state *state.StateDB, txIndex int, sender common.Address, tx *types.Transaction) (ret []byte, vmerr error, err error)
+
+	// TraceFinalize applies the state changes made while finalizing a block outside
+	// of its transactions, the system calls preceding the system transactions, the
+	// block having commonTxs non-system transactions. The trace function is called
+	// before each system call changes any state, for the EVM logger to trace it.
+	TraceFinalize(chain ChainHeaderReader, header *types.Header, state *state.StateDB, commonTxs int, trace func(call string) vm.EVMLogger) error
 }
 
 type StateReader interface {
@@ -239,6 +239,192 @@ const (
 			],
 			"stateMutability": "view",
 			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "getActiveValidators",
+			"outputs": [
+				{
+					"internalType": "address[]",
+					"name": "",
+					"type": "address[]"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "getAllValidatorsLength",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"name": "allValidatorAddrs",
+			"outputs": [
+				{
+					"internalType": "address",
+					"name": "",
+					"type": "address"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "",
+					"type": "address"
+				}
+			],
+			"name": "valMaps",
+			"outputs": [
+				{
+					"internalType": "contract IValidator",
+					"name": "",
+					"type": "address"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "",
+					"type": "address"
+				}
+			],
+			"name": "valInfos",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "stakeGWei",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "debt",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "unWithdrawn",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "_val",
+					"type": "address"
+				}
+			],
+			"name": "getPunishRecord",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "_val",
+					"type": "address"
+				},
+				{
+					"internalType": "address",
+					"name": "_stakeOwner",
+					"type": "address"
+				}
+			],
+			"name": "anyClaimable",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "_val",
+					"type": "address"
+				},
+				{
+					"internalType": "address",
+					"name": "_stakeOwner",
+					"type": "address"
+				}
+			],
+			"name": "claimableRewards",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "totalStakeGWei",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "currRewardsPerBlock",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
 		}
 	]`
 
@@ -323,6 +509,77 @@ const (
 			"outputs": [],
 			"stateMutability": "nonpayable",
 			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "periodTime",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "_userAddress",
+					"type": "address"
+				}
+			],
+			"name": "getUserInfo",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "typId",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "lockedAmount",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "lockedTime",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "periodAmount",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "claimedPeriods",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "_userAddress",
+					"type": "address"
+				}
+			],
+			"name": "getClaimablePeriod",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
 		}
 	]`
 	// AddressListABI contains methods to interactive with AddressList contract.
@@ -496,6 +753,166 @@ const (
 			"type": "function"
 		}
 	]`
+
+	// ValidatorABI contains methods to interactive with the Validator contracts created by Staking contract.
+	ValidatorABI = `[
+		{
+			"inputs": [],
+			"name": "validator",
+			"outputs": [
+				{
+					"internalType": "address",
+					"name": "",
+					"type": "address"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "manager",
+			"outputs": [
+				{
+					"internalType": "address",
+					"name": "",
+					"type": "address"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "selfStakeGWei",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "totalStake",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "totalUnWithdrawn",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "commissionRate",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "acceptDelegation",
+			"outputs": [
+				{
+					"internalType": "bool",
+					"name": "",
+					"type": "bool"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "state",
+			"outputs": [
+				{
+					"internalType": "enum Validator.State",
+					"name": "",
+					"type": "uint8"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [],
+			"name": "punishBlk",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		},
+		{
+			"inputs": [
+				{
+					"internalType": "address",
+					"name": "",
+					"type": "address"
+				}
+			],
+			"name": "delegators",
+			"outputs": [
+				{
+					"internalType": "uint256",
+					"name": "stakeGWei",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "settled",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "debt",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "unWithdrawn",
+					"type": "uint256"
+				},
+				{
+					"internalType": "uint256",
+					"name": "punishFree",
+					"type": "uint256"
+				}
+			],
+			"stateMutability": "view",
+			"type": "function"
+		}
+	]`
 )
 
 // DevMappingPosition is the position of the state variable `devs`.
@@ -538,7 +955,8 @@ var (
 	AddressListContract   = common.HexToAddress("0x000000000000000000000000000000000000F004")
 	OnChainDaoContract    = common.HexToAddress("0x000000000000000000000000000000000000F005")
 
-	abiMap map[common.Address]abi.ABI
+	abiMap       map[common.Address]abi.ABI
+	validatorABI abi.ABI
 )
 
 // init the abiMap
@@ -559,6 +977,11 @@ func init() {
 			abiMap[addr] = abi
 		}
 	}
+
+	var err error
+	if validatorABI, err = abi.JSON(strings.NewReader(ValidatorABI)); err != nil {
+		panic(err)
+	}
 }
 
 // ABI return abi for given contract calling
@@ -570,6 +993,12 @@ func ABI(contract common.Address) abi.ABI {
 	return contractABI
 }
 
+// ValidatorContractABI return abi for the Validator contracts, which are
+// created dynamically by the Staking contract for each registered validator.
+func ValidatorContractABI() abi.ABI {
+	return validatorABI
+}
+
 // ABIPack generates the data field for given contract calling
 func ABIPack(contract common.Address, method string, args ...interface{}) ([]byte, error) {
 	return ABI(contract).Pack(method, args...)
@@ -183,6 +183,7 @@ type BlockChain struct {
 	blockProcFeed                    event.Feed
 	newAttestationFeed               event.Feed
 	newJustifiedOrFinalizedBlockFeed event.Feed
+	validatorSetChangeFeed           event.Feed
 	scope                            event.SubscriptionScope
 	genesisBlock                     *types.Block
 
@@ -276,6 +277,9 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
 	bc.processor = NewStateProcessor(chainConfig, bc, engine)
 
+	// Nothing is finalized without the Chaos engine
+	bc.lastFinalizedBlockNumber.Store(new(big.Int))
+
 	bc.ChaosEngine, bc.isChaosEngine = engine.(consensus.ChaosEngine)
 	if bc.isChaosEngine {
 		// load stored last attested number
@@ -384,7 +388,8 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		}
 		if needRewind {
 			log.Error("Truncating ancient chain", "from", bc.CurrentHeader().Number.Uint64(), "to", low)
-			if err := bc.SetHead(low); err != nil {
+			// The chain reparation is not refused by the finality
+			if _, err := bc.setHeadBeyondRoot(low, common.Hash{}, false); err != nil {
 				return nil, err
 			}
 		}
@@ -533,7 +538,13 @@ func (bc *BlockChain) loadLastState() error {
 // SetHead rewinds the local chain to a new head. Depending on whether the node
 // was fast synced or full synced and in which state, the method will try to
 // delete minimal data from disk whilst retaining chain consistency.
+//
+// Rewinding below the last finalized block is refused, use SetHeadForce for it.
 func (bc *BlockChain) SetHead(head uint64) error {
+	if number, hash := bc.finalizedBlock(); head < number {
+		log.Error("Refusing to rewind beyond finality", "target", head, "finalized", number, "hash", hash)
+		return fmt.Errorf("%w: target %d, finalized %d", ErrFinalizedRewind, head, number)
+	}
 	_, err := bc.setHeadBeyondRoot(head, common.Hash{}, false)
 	return err
 }
@@ -672,6 +683,10 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, root common.Hash, repair bo
 		log.Warn("Rewinding blockchain", "target", head)
 		bc.hc.SetHead(head, updateFn, delFn)
 	}
+	// The finalized blocks may be gone with the header chain, by a forced rewind
+	// or by a repair, drop their markers so they can be re-imported
+	bc.resetFinality(bc.CurrentHeader().Number.Uint64())
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -1431,6 +1446,9 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		if emitHeadEvent {
 			bc.chainHeadFeed.Send(ChainHeadEvent{Block: block})
 		}
+		if bc.isChaosEngine {
+			bc.checkValidatorSetChange(block.Header())
+		}
 	} else {
 		bc.chainSideFeed.Send(ChainSideEvent{Block: block})
 	}
@@ -1515,6 +1533,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		return 0, nil
 	}
 
+	// Reject the chain right away if it would replace a finalized block
+	if index, err := bc.checkFinality(chain); err != nil {
+		return index, err
+	}
 	// Start a parallel signature recovery (signer will fluke on fork transition, minimal perf loss)
 	signer := types.MakeSigner(bc.chainConfig, chain[0].Number())
 	go senderCacher.recoverFromBlocks(signer, chain)
@@ -2049,6 +2071,13 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 			return fmt.Errorf("invalid new chain")
 		}
 	}
+	// Never drop a finalized block, whatever the branch selection says
+	if len(oldChain) > 0 {
+		if number, hash := bc.finalizedBlock(); oldChain[len(oldChain)-1].NumberU64() <= number {
+			log.Error("Refusing reorg beyond finality", "number", commonBlock.Number(), "hash", commonBlock.Hash(), "finalized", number, "finalizedhash", hash)
+			return fmt.Errorf("%w: reorg from block %d [%x]", ErrFinalizedConflict, commonBlock.NumberU64(), commonBlock.Hash().Bytes()[:4])
+		}
+	}
 	// Ensure the user sees large reorgs
 	if len(oldChain) > 0 && len(newChain) > 0 {
 		logFn := log.Info
@@ -2071,6 +2100,11 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		// Insert the block in the canonical way, re-writing history
 		bc.writeHeadBlock(newChain[i])
 
+		// Checkpoints made canonical by the reorg may change the validator set
+		if bc.isChaosEngine {
+			bc.checkValidatorSetChange(newChain[i].Header())
+		}
+
 		// Collect reborn logs due to chain reorg
 		collectLogs(newChain[i].Hash(), false)
 
@@ -14,6 +14,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
 const (
@@ -31,6 +32,27 @@ const (
 	catchUpSafetyMultiple = 2
 )
 
+var (
+	attestationReceivedMeter = metrics.NewRegisteredMeter("chaos/attestation/received", nil)
+	attestationValidMeter    = metrics.NewRegisteredMeter("chaos/attestation/valid", nil)
+	attestationHandleTimer   = metrics.NewRegisteredTimer("chaos/attestation/handle", nil)
+
+	// Attestations rejected, per reason
+	attestationInvalidMeter      = metrics.NewRegisteredMeter("chaos/attestation/invalid/malformed", nil)
+	attestationOutOfRangeMeter   = metrics.NewRegisteredMeter("chaos/attestation/invalid/outofrange", nil)
+	attestationSignatureMeter    = metrics.NewRegisteredMeter("chaos/attestation/invalid/signature", nil)
+	attestationMismatchMeter     = metrics.NewRegisteredMeter("chaos/attestation/invalid/mismatch", nil)
+	attestationNonValidatorMeter = metrics.NewRegisteredMeter("chaos/attestation/invalid/nonvalidator", nil)
+	attestationRejectedMeter     = metrics.NewRegisteredMeter("chaos/attestation/invalid/rejected", nil)
+
+	futureAttessGauge = metrics.NewRegisteredGauge("chaos/attestation/future", nil)
+	recentAttessGauge = metrics.NewRegisteredGauge("chaos/attestation/recent", nil)
+
+	// Distance of the head to the last justified and finalized blocks
+	justifiedDistanceGauge = metrics.NewRegisteredGauge("chaos/finality/justified/distance", nil)
+	finalizedDistanceGauge = metrics.NewRegisteredGauge("chaos/finality/finalized/distance", nil)
+)
+
 const (
 	diffUpperLimitWarning        = 63
 	unableSureBlockStateInterval = 100
@@ -40,17 +62,45 @@ const (
 // The certificates that meet the inspection will be stored according to the height of the current chain plot.
 // If they are higher than the local height, they will be stored in the future cache.
 func (bc *BlockChain) HandleAttestation(a *types.Attestation) error {
+	start := time.Now()
+	attestationReceivedMeter.Mark(1)
+
+	err := bc.handleAttestation(a)
+	attestationHandleTimer.UpdateSince(start)
+	switch {
+	case err == nil:
+		attestationValidMeter.Mark(1)
+	case errors.Is(err, ErrAttestationSignature):
+		attestationSignatureMeter.Mark(1)
+	case errors.Is(err, ErrAttestationNonValidator):
+		attestationNonValidatorMeter.Mark(1)
+	case errors.Is(err, ErrAttestationOutOfRange):
+		attestationOutOfRangeMeter.Mark(1)
+	case errors.Is(err, ErrAttestationMismatch):
+		attestationMismatchMeter.Mark(1)
+	case errors.Is(err, ErrAttestationInvalid):
+		attestationInvalidMeter.Mark(1)
+	default:
+		attestationRejectedMeter.Mark(1)
+	}
+	bc.updateAttestationGauges()
+	return err
+}
+
+// handleAttestation checks an attestation and stores it into the recent or the
+// future cache.
+func (bc *BlockChain) handleAttestation(a *types.Attestation) error {
 	currentBlockNumber := bc.CurrentBlock().NumberU64()
 	if err := a.SanityCheck(); err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrAttestationInvalid, err)
 	}
 	sourceNumber := a.SourceRangeEdge.Number.Uint64()
 	targetNumber := a.TargetRangeEdge.Number.Uint64()
 	if targetNumber-sourceNumber > unableSureBlockStateInterval {
-		return errors.New("inspection interval not conforming to attestation")
+		return fmt.Errorf("%w: inspection interval not conforming to attestation", ErrAttestationOutOfRange)
 	}
 	if !bc.VerifyValidLimit(targetNumber, currentBlockNumber) {
-		return nil
+		return fmt.Errorf("%w: target %d, current %d", ErrAttestationOutOfRange, targetNumber, currentBlockNumber)
 	}
 
 	if targetNumber <= currentBlockNumber {
@@ -66,13 +116,13 @@ func (bc *BlockChain) HandleAttestation(a *types.Attestation) error {
 	signer, err := a.RecoverSigner()
 	if err != nil {
 		log.Warn("RecoverSigner error:", "err", err.Error())
-		return err
+		return fmt.Errorf("%w: %v", ErrAttestationSignature, err)
 	}
 	if !bc.VerifyLocalDataCheck(a, currentBlockNumber) {
-		return errors.New("the block information in the current proof does not match the local data")
+		return ErrAttestationMismatch
 	}
 	if !bc.VerifySignerInEpochValidBP(targetNumber, signer) {
-		return errors.New("the signer of the current attestation is not a valid verifier in the current epoch")
+		return ErrAttestationNonValidator
 	}
 	if targetNumber <= currentBlockNumber {
 		return bc.AddOneAttestationToRecentCache(a, signer, false)
@@ -90,6 +140,8 @@ func (bc *BlockChain) attestationHandleLoop() {
 		select {
 		case ev := <-chainHeadCh:
 			bc.processAttestationOnHead(ev.Block.Header())
+			bc.updateFinalityGauges(ev.Block.NumberU64())
+			bc.updateAttestationGauges()
 		case <-bc.quit:
 			return
 		}
@@ -218,6 +270,24 @@ func (bc *BlockChain) processAttestationOnHead(head *types.Header) {
 	}
 }
 
+// updateFinalityGauges meters the distance of the head to the last justified
+// and finalized blocks, which keeps growing when the finality stalls.
+func (bc *BlockChain) updateFinalityGauges(head uint64) {
+	justified, finalized := bc.FinalityEdges()
+	if number := justified.Number.Uint64(); head >= number {
+		justifiedDistanceGauge.Update(int64(head - number))
+	}
+	if number := finalized.Number.Uint64(); head >= number {
+		finalizedDistanceGauge.Update(int64(head - number))
+	}
+}
+
+// updateAttestationGauges meters the sizes of the attestation caches.
+func (bc *BlockChain) updateAttestationGauges() {
+	futureAttessGauge.Update(int64(bc.FutureAttessCache.Len()))
+	recentAttessGauge.Update(int64(bc.RecentAttessCache.Len()))
+}
+
 // LastValidJustifiedOrFinalized Get the last valid block status information after the specified block
 func (bc *BlockChain) LastValidJustifiedOrFinalized() *types.RangeEdge {
 	last := bc.currentBlockStatusNumber.Load().(*big.Int)
@@ -599,6 +669,68 @@ func (bc *BlockChain) UpdateCurrentEpochBPList(hash common.Hash, number uint64)
 	return nil
 }
 
+// checkValidatorSetChange fires a ValidatorSetChangeEvent if the given canonical
+// header is a checkpoint carrying a validator list different from the previous one.
+func (bc *BlockChain) checkValidatorSetChange(header *types.Header) {
+	epoch := bc.chainConfig.Chaos.Epoch
+	number := header.Number.Uint64()
+	if epoch == 0 || number == 0 || number%epoch != 0 {
+		return
+	}
+	prev := bc.GetHeaderByNumber(number - epoch)
+	if prev == nil {
+		return
+	}
+	oldVals, newVals := CheckpointValidators(prev), CheckpointValidators(header)
+	joined, left := DiffValidators(newVals, oldVals), DiffValidators(oldVals, newVals)
+	if len(joined) == 0 && len(left) == 0 {
+		return
+	}
+	log.Info("Validator set changed", "number", number, "hash", header.Hash(), "effective", number+epoch+1, "joined", joined, "left", left)
+	for _, val := range joined {
+		if val == bc.ChaosEngine.CurrentValidator() {
+			log.Warn("Local validator joins the validator set, make sure it keeps online", "validator", val, "effective", number+epoch+1)
+		}
+	}
+	bc.validatorSetChangeFeed.Send(ValidatorSetChangeEvent{
+		Number:    number,
+		Hash:      header.Hash(),
+		Effective: number + epoch + 1,
+		Old:       oldVals,
+		New:       newVals,
+		Joined:    joined,
+		Left:      left,
+	})
+}
+
+// CheckpointValidators retrieves the validators list in the extra-data of a Chaos
+// checkpoint header, nil if the extra-data is too short to carry any.
+func CheckpointValidators(header *types.Header) []common.Address {
+	if len(header.Extra) < extraVanity+extraSeal {
+		return nil
+	}
+	validators := make([]common.Address, (len(header.Extra)-extraVanity-extraSeal)/common.AddressLength)
+	for i := 0; i < len(validators); i++ {
+		copy(validators[i][:], header.Extra[extraVanity+i*common.AddressLength:])
+	}
+	return validators
+}
+
+// DiffValidators returns the validators in a but not in b.
+func DiffValidators(a, b []common.Address) []common.Address {
+	exists := make(map[common.Address]struct{}, len(b))
+	for _, val := range b {
+		exists[val] = struct{}{}
+	}
+	diff := make([]common.Address, 0)
+	for _, val := range a {
+		if _, ok := exists[val]; !ok {
+			diff = append(diff, val)
+		}
+	}
+	return diff
+}
+
 // VerifySignerInEpochValidBP Verify whether the current BP address is a valid BP.
 // The BP address in the future block will be verified in two cycles
 func (bc *BlockChain) VerifySignerInEpochValidBP(number uint64, signer common.Address) bool {
@@ -679,6 +811,55 @@ func (bc *BlockChain) GetHistoryOneAttestation(num *big.Int, hash common.Hash, a
 	return nil, errors.New("not found")
 }
 
+// InsertFinalityAttestations imports the attestations of a block retrieved during
+// sync. Unlike the gossiped ones, they target a block way behind the head, so they
+// are verified against the validators at the block directly, and the block is
+// justified once they reach the threshold. It returns the block status after the import.
+func (bc *BlockChain) InsertFinalityAttestations(number uint64, hash common.Hash, as []*types.Attestation) (uint8, error) {
+	if !bc.isChaosEngine {
+		return types.BasUnknown, errors.New("finality requires the chaos engine")
+	}
+	if status := bc.GetBlockStatus(number, hash); status != types.BasUnknown {
+		return status, nil
+	}
+	if !bc.HasHeader(hash, number) {
+		return types.BasUnknown, fmt.Errorf("%w: unknown block %d [%x]", ErrAttestationMismatch, number, hash)
+	}
+	var (
+		signers   = make(map[common.Address]struct{})
+		threshold int
+	)
+	for _, a := range as {
+		if err := a.SanityCheck(); err != nil {
+			return types.BasUnknown, fmt.Errorf("%w: %v", ErrAttestationInvalid, err)
+		}
+		if a.TargetRangeEdge.Number.Uint64() != number || a.TargetRangeEdge.Hash != hash {
+			return types.BasUnknown, fmt.Errorf("%w: target %d [%x], want %d [%x]", ErrAttestationMismatch,
+				a.TargetRangeEdge.Number, a.TargetRangeEdge.Hash, number, hash)
+		}
+		if _, err := a.RecoverSigner(); err != nil {
+			return types.BasUnknown, fmt.Errorf("%w: %v", ErrAttestationSignature, err)
+		}
+		signer, t, err := bc.ChaosEngine.VerifyAttestation(bc, a)
+		if err != nil {
+			return types.BasUnknown, fmt.Errorf("%w: %v", ErrAttestationNonValidator, err)
+		}
+		if _, ok := signers[signer]; ok {
+			continue
+		}
+		signers[signer], threshold = struct{}{}, t
+
+		// Keep it around for the other nodes syncing from us
+		if _, err := bc.GetHistoryOneAttestation(a.TargetRangeEdge.Number, hash, a.Hash()); err != nil {
+			bc.addOneValidAttestationToHistoryCache(a)
+		}
+	}
+	if threshold == 0 || len(signers) < threshold {
+		return types.BasUnknown, nil
+	}
+	return bc.AddBlockBasJustified(new(big.Int).SetUint64(number), hash)
+}
+
 // IsFiliation Judge whether there is a parent-child relationship between the two blocks
 func (bc *BlockChain) IsFiliation(parent, child *types.RangeEdge) (bool, error) {
 	if parent.Number.Uint64() == 0 { // Genesis block is a valid parent of any other block
@@ -0,0 +1,59 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that the validators are parsed from the checkpoint extra-data, and that
+// malformed extra-data yields none.
+func TestCheckpointValidators(t *testing.T) {
+	var (
+		val1 = common.HexToAddress("0x01")
+		val2 = common.HexToAddress("0x02")
+	)
+	extra := make([]byte, extraVanity)
+	extra = append(extra, val1.Bytes()...)
+	extra = append(extra, val2.Bytes()...)
+	extra = append(extra, make([]byte, extraSeal)...)
+
+	tests := []struct {
+		extra []byte
+		want  []common.Address
+	}{
+		{extra, []common.Address{val1, val2}},
+		{make([]byte, extraVanity+extraSeal), []common.Address{}},
+		{make([]byte, extraVanity), nil},
+		{nil, nil},
+	}
+	for i, tt := range tests {
+		if have := CheckpointValidators(&types.Header{Extra: tt.extra}); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: validators mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	if have := DiffValidators([]common.Address{val1, val2}, []common.Address{val2}); !reflect.DeepEqual(have, []common.Address{val1}) {
+		t.Errorf("diff mismatch: have %v, want %v", have, []common.Address{val1})
+	}
+	if have := DiffValidators([]common.Address{val1}, []common.Address{val1}); have == nil || len(have) != 0 {
+		t.Errorf("empty diff mismatch: have %v", have)
+	}
+}
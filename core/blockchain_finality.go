@@ -0,0 +1,119 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// finalizedBlock returns the number and the hash of the last finalized block,
+// the number is 0 if nothing is finalized yet.
+func (bc *BlockChain) finalizedBlock() (uint64, common.Hash) {
+	_, finalized := bc.FinalityEdges()
+	return finalized.Number.Uint64(), finalized.Hash
+}
+
+// conflictsWithFinality reports whether the block is not a descendant of the
+// last finalized block, nor a canonical block below it. Blocks of unknown
+// ancestry are not judged here, they are rejected by the header verification.
+func (bc *BlockChain) conflictsWithFinality(header *types.Header, number uint64, finalized common.Hash) bool {
+	if header.Number.Uint64() <= number {
+		return bc.GetCanonicalHash(header.Number.Uint64()) != header.Hash()
+	}
+	hash, height := header.ParentHash, header.Number.Uint64()-1
+	for height > number {
+		// The canonical chain always contains the finalized block
+		if bc.GetCanonicalHash(height) == hash {
+			return false
+		}
+		parent := bc.GetHeader(hash, height)
+		if parent == nil {
+			return false
+		}
+		hash, height = parent.ParentHash, height-1
+	}
+	return hash != finalized
+}
+
+// checkFinality ensures none of the blocks to import conflicts with the last
+// finalized block. It returns the index of the first conflicting block.
+func (bc *BlockChain) checkFinality(chain types.Blocks) (int, error) {
+	number, hash := bc.finalizedBlock()
+	if number == 0 {
+		return 0, nil
+	}
+	for i, block := range chain {
+		// Blocks above the finality are linked to the first one, which is enough
+		// to be checked for the ancestry
+		if i > 0 && block.NumberU64() > number {
+			break
+		}
+		if bc.conflictsWithFinality(block.Header(), number, hash) {
+			log.Warn("Rejected block conflicting with finality", "number", block.Number(), "hash", block.Hash(), "finalized", number, "finalizedhash", hash)
+			return i, fmt.Errorf("%w: block %d [%x], finalized %d [%x]", ErrFinalizedConflict, block.NumberU64(), block.Hash().Bytes()[:4], number, hash.Bytes()[:4])
+		}
+	}
+	return 0, nil
+}
+
+// SetHeadForce rewinds the local chain to a new head like SetHead, but it's also
+// allowed to rewind below the last finalized block. The finality markers above
+// the new head are dropped, so a different chain may become finalized later.
+func (bc *BlockChain) SetHeadForce(head uint64) error {
+	if number, hash := bc.finalizedBlock(); head < number {
+		log.Warn("################################################################")
+		log.Warn("Forcing the chain head below the last finalized block!")
+		log.Warn("Finalized blocks will be dropped, the node may end up on a chain")
+		log.Warn("different from the rest of the network.")
+		log.Warn("################################################################")
+		log.Warn("Rewinding beyond finality", "target", head, "finalized", number, "hash", hash)
+	}
+	_, err := bc.setHeadBeyondRoot(head, common.Hash{}, false)
+	return err
+}
+
+// resetFinality forgets the justified and finalized markers above the given block.
+// It's done for the rewinds below the finalized block, including the repairs of
+// the missing head state which may wipe the finalized blocks frozen already. The
+// markers are rewritten regardless of the engine, as the freezer, the downloader
+// and the pruner read them from the database.
+func (bc *BlockChain) resetFinality(head uint64) {
+	number := new(big.Int).SetUint64(head)
+	if last := rawdb.LastBlockStatusNumber(bc.db); last.Cmp(number) > 0 {
+		rawdb.WriteLastBlockStatusNumber(bc.db, number)
+	}
+	if last := rawdb.LastFinalizedBlockNumber(bc.db); last.Cmp(number) > 0 {
+		log.Warn("Dropping finality beyond the rewound chain", "finalized", last, "head", head)
+		rawdb.WriteLastFinalizedBlockNumber(bc.db, number)
+	}
+	if !bc.isChaosEngine {
+		return
+	}
+	if last := bc.currentBlockStatusNumber.Load().(*big.Int); last.Cmp(number) > 0 {
+		bc.currentBlockStatusNumber.Store(new(big.Int).Set(number))
+	}
+	if last := bc.lastFinalizedBlockNumber.Load().(*big.Int); last.Cmp(number) > 0 {
+		bc.lastFinalizedBlockNumber.Store(new(big.Int).Set(number))
+	}
+	bc.BlockStatusCache.Purge()
+}
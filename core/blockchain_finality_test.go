@@ -0,0 +1,227 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Tests that blocks are checked against the finalized block correctly.
+func TestConflictsWithFinality(t *testing.T) {
+	db, chain, err := newCanonical(ethash.NewFaker(), 10, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Create a side chain forking below the finalized block, and one above it
+	below := makeBlockChain(chain.GetBlockByNumber(3), 5, ethash.NewFaker(), db, forkSeed)
+	if _, err := chain.InsertChain(below); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	above := makeBlockChain(chain.GetBlockByNumber(6), 3, ethash.NewFaker(), db, forkSeed)
+
+	finalized := chain.GetBlockByNumber(5)
+	tests := []struct {
+		name     string
+		number   uint64
+		conflict bool
+	}{
+		{"canonical below finality", 3, false},
+		{"canonical finalized", 5, false},
+		{"canonical above finality", 9, false},
+	}
+	for _, tt := range tests {
+		header := chain.GetHeaderByNumber(tt.number)
+		if have := chain.conflictsWithFinality(header, finalized.NumberU64(), finalized.Hash()); have != tt.conflict {
+			t.Errorf("%s: conflict mismatch: have %v, want %v", tt.name, have, tt.conflict)
+		}
+	}
+	for i, block := range below {
+		if !chain.conflictsWithFinality(block.Header(), finalized.NumberU64(), finalized.Hash()) {
+			t.Errorf("side block %d forking below finality not rejected", i)
+		}
+	}
+	for i, block := range above {
+		if chain.conflictsWithFinality(block.Header(), finalized.NumberU64(), finalized.Hash()) {
+			t.Errorf("side block %d forking above finality rejected", i)
+		}
+	}
+}
+
+// Tests that the repair of a missing head state below the finalized block, whose
+// ancestors are frozen by the finality, truncates the frozen blocks and drops the
+// finality markers along with them, and the blocks can be imported again.
+func TestRepairBelowFinality(t *testing.T) {
+	datadir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	db, err := rawdb.NewLevelDBDatabaseWithFreezer(datadir, 0, 0, datadir, "", false)
+	if err != nil {
+		t.Fatalf("Failed to create persistent database: %v", err)
+	}
+	defer db.Close() // Might double close, should be fine
+
+	var (
+		genesis = (&Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+		engine  = ethash.NewFullFaker()
+		config  = &CacheConfig{
+			TrieCleanLimit: 256,
+			TrieDirtyLimit: 256,
+			TrieTimeLimit:  5 * time.Minute,
+		}
+		finalized = uint64(180)
+		committed = 100
+	)
+	chain, err := NewBlockChain(db, config, params.AllEthashProtocolChanges, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create chain: %v", err)
+	}
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, rawdb.NewMemoryDatabase(), 200, nil)
+	if _, err := chain.InsertChain(blocks[:committed]); err != nil {
+		t.Fatalf("Failed to import canonical chain start: %v", err)
+	}
+	// The states are committed asynchronously, wait for the last one
+	chain.stateCache.TrieDB().WaitAsyncCommit()
+	chain.stateCache.TrieDB().Commit(blocks[committed-1].Root(), true, nil)
+	if _, err := chain.InsertChain(blocks[committed:]); err != nil {
+		t.Fatalf("Failed to import canonical chain tail: %v", err)
+	}
+	// Finalize a block and freeze the chain up to the margin below it
+	rawdb.WriteBlockStatus(db, new(big.Int).SetUint64(finalized), blocks[finalized-1].Hash(), types.BasFinalized)
+	rawdb.WriteLastBlockStatusNumber(db, new(big.Int).SetUint64(finalized))
+	rawdb.WriteLastFinalizedBlockNumber(db, new(big.Int).SetUint64(finalized))
+
+	type freezer interface {
+		Freeze(threshold uint64) error
+		Ancients() (uint64, error)
+	}
+	db.(freezer).Freeze(params.FullImmutabilityThreshold)
+	if frozen, _ := db.(freezer).Ancients(); frozen != finalized-params.FinalityImmutabilityMargin+1 {
+		t.Fatalf("Frozen block count mismatch: have %d, want %d", frozen, finalized-params.FinalityImmutabilityMargin+1)
+	}
+	// Pull the plug on the database, the head state is gone, and the repair has
+	// to rewind below the frozen blocks
+	db.Close()
+
+	db, err = rawdb.NewLevelDBDatabaseWithFreezer(datadir, 0, 0, datadir, "", false)
+	if err != nil {
+		t.Fatalf("Failed to reopen persistent database: %v", err)
+	}
+	defer db.Close()
+
+	chain, err = NewBlockChain(db, nil, params.AllEthashProtocolChanges, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to recreate chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if head := chain.CurrentHeader().Number.Uint64(); head != uint64(committed) {
+		t.Fatalf("Head header mismatch: have %d, want %d", head, committed)
+	}
+	if frozen, _ := db.(freezer).Ancients(); frozen != uint64(committed)+1 {
+		t.Errorf("Frozen block count mismatch: have %d, want %d", frozen, committed+1)
+	}
+	if number := rawdb.LastFinalizedBlockNumber(db).Uint64(); number > uint64(committed) {
+		t.Errorf("Finalized marker beyond the head: have %d, head %d", number, committed)
+	}
+	if number, ok := rawdb.ReadFinalizedImmutableNumber(db, params.FinalityImmutabilityMargin); ok {
+		t.Errorf("Immutable number found after the repair: %d", number)
+	}
+	// The wiped blocks can be imported again
+	if _, err := chain.InsertChain(blocks[committed:]); err != nil {
+		t.Fatalf("Failed to import wiped blocks: %v", err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != uint64(len(blocks)) {
+		t.Errorf("Head block mismatch: have %d, want %d", head, len(blocks))
+	}
+}
+
+// fakeFinality makes the chain track the finality like with the Chaos engine,
+// and finalizes the canonical block of the given number.
+func fakeFinality(chain *BlockChain, number uint64) {
+	chain.isChaosEngine = true
+	chain.FutureAttessCache, _ = lru.New(maxFutureAttestations)
+	chain.RecentAttessCache, _ = lru.New(attestationsCacheLimit)
+	chain.HistoryAttessCache, _ = lru.New(historyAttessCacheLimit)
+	chain.CasperFFGHistoryCache, _ = lru.New(casperFFGHistoryCacheLimit)
+	chain.BlockStatusCache, _ = lru.New(blockStatusCacheLimit)
+
+	finalized := new(big.Int).SetUint64(number)
+	rawdb.WriteBlockStatus(chain.db, finalized, chain.GetCanonicalHash(number), types.BasFinalized)
+	rawdb.WriteLastBlockStatusNumber(chain.db, finalized)
+	rawdb.WriteLastFinalizedBlockNumber(chain.db, finalized)
+	chain.currentBlockStatusNumber.Store(new(big.Int).Set(finalized))
+	chain.lastFinalizedBlockNumber.Store(new(big.Int).Set(finalized))
+}
+
+// Tests that rewinding below the finalized block is refused, unless it's forced,
+// in which case the finality markers above the new head are dropped.
+func TestSetHeadBelowFinality(t *testing.T) {
+	_, chain, err := newCanonical(ethash.NewFaker(), 10, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer chain.Stop()
+
+	fakeFinality(chain, 6)
+
+	// Rewinding down to the finalized block is allowed
+	if err := chain.SetHead(8); err != nil {
+		t.Fatalf("failed to rewind above finality: %v", err)
+	}
+	if err := chain.SetHead(3); !errors.Is(err, ErrFinalizedRewind) {
+		t.Fatalf("rewind below finality error mismatch: have %v, want %v", err, ErrFinalizedRewind)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 8 {
+		t.Fatalf("head block mismatch after refused rewind: have %d, want %d", head, 8)
+	}
+	if number, _ := chain.finalizedBlock(); number != 6 {
+		t.Fatalf("finalized block mismatch after refused rewind: have %d, want %d", number, 6)
+	}
+	// Forcing the rewind drops the finality above the new head
+	if err := chain.SetHeadForce(3); err != nil {
+		t.Fatalf("failed to force rewind below finality: %v", err)
+	}
+	if head := chain.CurrentBlock().NumberU64(); head != 3 {
+		t.Fatalf("head block mismatch after forced rewind: have %d, want %d", head, 3)
+	}
+	if number, _ := chain.finalizedBlock(); number != 0 {
+		t.Errorf("finalized block mismatch after forced rewind: have %d, want %d", number, 0)
+	}
+	if number := rawdb.LastFinalizedBlockNumber(chain.db).Uint64(); number != 3 {
+		t.Errorf("finalized marker mismatch: have %d, want %d", number, 3)
+	}
+	if number := rawdb.LastBlockStatusNumber(chain.db).Uint64(); number != 3 {
+		t.Errorf("block status marker mismatch: have %d, want %d", number, 3)
+	}
+}
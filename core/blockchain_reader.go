@@ -150,6 +150,10 @@ func (bc *BlockChain) GetBlock(hash common.Hash, number uint64) *types.Block {
 }
 
 func (bc *BlockChain) GetBlockPredictStatus(hash common.Hash, number uint64) uint8 {
+	// Blocks are only attested with the Chaos engine
+	if !bc.isChaosEngine {
+		return types.BasUnknown
+	}
 	currentBlockNumber := bc.CurrentBlock().NumberU64()
 	if currentBlockNumber > unableSureBlockStateInterval {
 		if number < currentBlockNumber-unableSureBlockStateInterval {
@@ -176,6 +180,27 @@ func (bc *BlockChain) GetLastFinalizedBlockNumber() uint64 {
 	return number
 }
 
+// FinalityEdges returns the latest justified (or finalized) block and the latest
+// finalized block known locally, both are the genesis if there is none yet.
+func (bc *BlockChain) FinalityEdges() (justified *types.RangeEdge, finalized *types.RangeEdge) {
+	justified = &types.RangeEdge{Number: new(big.Int)}
+	finalized = &types.RangeEdge{Number: new(big.Int)}
+	if !bc.isChaosEngine {
+		return justified, finalized
+	}
+	if last := bc.currentBlockStatusNumber.Load().(*big.Int); last.Sign() > 0 {
+		if status, hash := bc.GetBlockStatusByNum(last.Uint64()); status != types.BasUnknown {
+			justified = &types.RangeEdge{Number: new(big.Int).Set(last), Hash: hash}
+		}
+	}
+	if last := bc.lastFinalizedBlockNumber.Load().(*big.Int); last.Sign() > 0 {
+		if status, hash := bc.GetBlockStatusByNum(last.Uint64()); status == types.BasFinalized {
+			finalized = &types.RangeEdge{Number: new(big.Int).Set(last), Hash: hash}
+		}
+	}
+	return justified, finalized
+}
+
 // GetBlockByHash retrieves a block from the database by hash, caching it if found.
 func (bc *BlockChain) GetBlockByHash(hash common.Hash) *types.Block {
 	number := bc.hc.GetBlockNumber(hash)
@@ -421,6 +446,11 @@ func (bc *BlockChain) SubscribeNewJustifiedOrFinalizedBlockEvent(ch chan<- NewJu
 	return bc.scope.Track(bc.newJustifiedOrFinalizedBlockFeed.Subscribe(ch))
 }
 
+// SubscribeValidatorSetChangeEvent registers a subscription of ValidatorSetChangeEvent.
+func (bc *BlockChain) SubscribeValidatorSetChangeEvent(ch chan<- ValidatorSetChangeEvent) event.Subscription {
+	return bc.scope.Track(bc.validatorSetChangeFeed.Subscribe(ch))
+}
+
 func (bc *BlockChain) GetBlockStatus(number uint64, hash common.Hash) uint8 {
 	// Short circuit if the status's already in the cache, retrieve otherwise
 	status, oldHash := bc.GetBlockStatusByNum(number)
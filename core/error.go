@@ -32,6 +32,14 @@ var (
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
 
+	// ErrFinalizedConflict is returned if a block to import, or a reorg, would
+	// replace a finalized block of the canonical chain.
+	ErrFinalizedConflict = errors.New("block conflicts with finalized chain")
+
+	// ErrFinalizedRewind is returned if the chain head is set below the last
+	// finalized block without forcing it.
+	ErrFinalizedRewind = errors.New("rewind beyond finalized block")
+
 	errSideChainReceipts = errors.New("side blocks can't be accepted as ancient chain data")
 )
 
@@ -106,4 +114,27 @@ var (
 
 	// ErrUnauthorizedDeveloper is returned if from address of a contract creation transaction is unauthorized
 	ErrUnauthorizedDeveloper = errors.New("unauthorized developer")
+
+	// ErrMetaTxExpired is returned if a meta transaction is past its block number
+	// limit by the next block.
+	ErrMetaTxExpired = errors.New("expired meta transaction")
+)
+
+// List of attestation handling errors, peers sending such attestations are penalized.
+var (
+	// ErrAttestationInvalid is returned if an attestation is malformed.
+	ErrAttestationInvalid = errors.New("invalid attestation")
+
+	// ErrAttestationOutOfRange is returned if the source and target of an attestation
+	// are too far away, or the target is too far away from the current block.
+	ErrAttestationOutOfRange = errors.New("attestation out of range")
+
+	// ErrAttestationSignature is returned if the signer of an attestation can't be recovered.
+	ErrAttestationSignature = errors.New("invalid attestation signature")
+
+	// ErrAttestationMismatch is returned if the blocks of an attestation don't match the local chain.
+	ErrAttestationMismatch = errors.New("the block information in the current proof does not match the local data")
+
+	// ErrAttestationNonValidator is returned if the signer of an attestation is not a validator.
+	ErrAttestationNonValidator = errors.New("the signer of the current attestation is not a valid verifier in the current epoch")
 )
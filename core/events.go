@@ -47,3 +47,15 @@ type NewAttestationEvent struct{ A *types.Attestation }
 type NewJustifiedOrFinalizedBlockEvent struct {
 	JF *types.BlockStatus
 }
+
+// ValidatorSetChangeEvent is posted when a canonical checkpoint block carries a
+// validator list different from the one in the previous checkpoint block.
+type ValidatorSetChangeEvent struct {
+	Number    uint64           // Number of the checkpoint block
+	Hash      common.Hash      // Hash of the checkpoint block
+	Effective uint64           // First block sealed by the new validators
+	Old       []common.Address // Validators in the previous checkpoint block
+	New       []common.Address // Validators in this checkpoint block
+	Joined    []common.Address // Validators in the new list but not in the old one
+	Left      []common.Address // Validators in the old list but not in the new one
+}
@@ -0,0 +1,282 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// log index sections, to prevent disk overload while catching up.
+	logIndexThrottling = 100 * time.Millisecond
+
+	// logIndexStallTimeout is the time after which building the log index offline
+	// is given up without any progress.
+	logIndexStallTimeout = time.Minute
+)
+
+// LogIndexer implements a core.ChainIndexer, building up an index of the blocks
+// having logs of each address and each first topic, so that the logs of an address
+// or an event are looked up in time proportional to the number of matches.
+type LogIndexer struct {
+	db        ethdb.Database              // database instance to write index data and metadata into
+	section   uint64                      // Section is the section number being processed currently
+	head      common.Hash                 // Head is the hash of the last header processed
+	addresses map[common.Address][]uint64 // Blocks having logs of each address in the section
+	topics    map[common.Hash][]uint64    // Blocks having logs of each first topic in the section
+}
+
+// NewLogIndexer returns a chain indexer that generates the log index of the
+// canonical chain.
+func NewLogIndexer(db ethdb.Database, size, confirms uint64) *ChainIndexer {
+	return newLogIndexer(db, size, confirms, logIndexThrottling)
+}
+
+func newLogIndexer(db ethdb.Database, size, confirms uint64, throttling time.Duration) *ChainIndexer {
+	table := rawdb.NewTable(db, string(rawdb.LogIndexPrefix))
+	return NewChainIndexer(db, table, &LogIndexer{db: db}, size, confirms, throttling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section
+// and dropping any previous version of it, indexed before a reorg.
+func (b *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	rawdb.DeleteLogIndexSection(b.db, section)
+
+	b.section, b.head = section, common.Hash{}
+	b.addresses = make(map[common.Address][]uint64)
+	b.topics = make(map[common.Hash][]uint64)
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the addresses and first
+// topics of a new block's logs into the index.
+func (b *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	b.head = header.Hash()
+	if header.Bloom == (types.Bloom{}) {
+		return nil
+	}
+	number := header.Number.Uint64()
+	receipts := rawdb.ReadRawReceipts(b.db, b.head, number)
+	if receipts == nil {
+		return errors.New("missing receipts")
+	}
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			if list := b.addresses[l.Address]; len(list) == 0 || list[len(list)-1] != number {
+				b.addresses[l.Address] = append(list, number)
+			}
+			if len(l.Topics) == 0 {
+				continue
+			}
+			if list := b.topics[l.Topics[0]]; len(list) == 0 || list[len(list)-1] != number {
+				b.topics[l.Topics[0]] = append(list, number)
+			}
+		}
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing out the log index section
+// into the database.
+func (b *LogIndexer) Commit() error {
+	var (
+		batch    = b.db.NewBatch()
+		contents = &rawdb.LogIndexSection{Head: b.head}
+	)
+	for address, numbers := range b.addresses {
+		rawdb.WriteLogIndex(batch, rawdb.LogIndexAddress, address.Bytes(), b.section, b.head, numbers)
+		contents.Addresses = append(contents.Addresses, address)
+	}
+	for topic, numbers := range b.topics {
+		rawdb.WriteLogIndex(batch, rawdb.LogIndexTopic, topic.Bytes(), b.section, b.head, numbers)
+		contents.Topics = append(contents.Topics, topic)
+	}
+	rawdb.WriteLogIndexSection(batch, b.section, contents)
+	return batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (b *LogIndexer) Prune(threshold uint64) error {
+	return nil
+}
+
+// LookupLogIndex returns the numbers of the canonical blocks from begin to end
+// having logs of any of the given addresses and any of the given first topics,
+// in ascending order. Empty addresses or topics match anything, but not both.
+// The range must be covered by the sections of the log index.
+func LookupLogIndex(db ethdb.Database, size, begin, end uint64, addresses []common.Address, topics []common.Hash) ([]uint64, error) {
+	if len(addresses) == 0 && len(topics) == 0 {
+		return nil, errors.New("nothing to look up")
+	}
+	var (
+		heads = make(map[uint64]common.Hash) // Canonical heads of the sections met
+		err   error
+	)
+	lookup := func(kind byte, value []byte) ([]uint64, error) {
+		var numbers []uint64
+		err := rawdb.IterateLogIndex(db, kind, value, begin/size, func(section uint64, head common.Hash, blocks []uint64) bool {
+			if section > end/size {
+				return false
+			}
+			canonical, ok := heads[section]
+			if !ok {
+				canonical = rawdb.ReadCanonicalHash(db, (section+1)*size-1)
+				heads[section] = canonical
+			}
+			if head != canonical {
+				return true // Left over by a reorg
+			}
+			for _, number := range blocks {
+				if number >= begin && number <= end {
+					numbers = append(numbers, number)
+				}
+			}
+			return true
+		})
+		return numbers, err
+	}
+	union := func(kind byte, values [][]byte) ([]uint64, error) {
+		var numbers []uint64
+		for _, value := range values {
+			found, err := lookup(kind, value)
+			if err != nil {
+				return nil, err
+			}
+			numbers = append(numbers, found...)
+		}
+		return sortUnique(numbers), nil
+	}
+	var byAddress, byTopic []uint64
+	if len(addresses) > 0 {
+		values := make([][]byte, len(addresses))
+		for i, address := range addresses {
+			values[i] = address.Bytes()
+		}
+		if byAddress, err = union(rawdb.LogIndexAddress, values); err != nil {
+			return nil, err
+		}
+		if len(topics) == 0 {
+			return byAddress, nil
+		}
+	}
+	values := make([][]byte, len(topics))
+	for i, topic := range topics {
+		values[i] = topic.Bytes()
+	}
+	if byTopic, err = union(rawdb.LogIndexTopic, values); err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return byTopic, nil
+	}
+	// Both given, keep the blocks having both
+	var numbers []uint64
+	for i, j := 0, 0; i < len(byAddress) && j < len(byTopic); {
+		switch {
+		case byAddress[i] < byTopic[j]:
+			i++
+		case byAddress[i] > byTopic[j]:
+			j++
+		default:
+			numbers = append(numbers, byAddress[i])
+			i, j = i+1, j+1
+		}
+	}
+	return numbers, nil
+}
+
+// sortUnique sorts the numbers in ascending order, dropping the duplicates.
+func sortUnique(numbers []uint64) []uint64 {
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	unique := numbers[:0]
+	for i, number := range numbers {
+		if i == 0 || number != numbers[i-1] {
+			unique = append(unique, number)
+		}
+	}
+	return unique
+}
+
+// logIndexChain is the chain of a database the log index is built offline for.
+type logIndexChain struct {
+	db   ethdb.Database
+	feed event.Feed
+}
+
+func (c *logIndexChain) CurrentHeader() *types.Header {
+	return rawdb.ReadHeadHeader(c.db)
+}
+
+func (c *logIndexChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// BuildLogIndex indexes the logs of the chain in the database, resuming from
+// the last indexed section unless rebuild is set, in which case the log index is
+// deleted first. It returns once all the confirmed sections are indexed.
+func BuildLogIndex(db ethdb.Database, size, confirms uint64, rebuild bool) error {
+	head := rawdb.ReadHeadHeader(db)
+	if head == nil {
+		return errors.New("no chain head")
+	}
+	if rebuild {
+		log.Info("Deleting log index")
+		rawdb.DeleteLogIndex(db)
+	}
+	var target uint64
+	if number := head.Number.Uint64() + 1; number > confirms {
+		target = (number - confirms) / size
+	}
+	indexer := newLogIndexer(db, size, confirms, 0)
+	defer indexer.Close()
+	indexer.Start(&logIndexChain{db: db})
+
+	var (
+		last     uint64
+		progress = time.Now()
+		logged   = time.Now()
+	)
+	for {
+		sections, _, _ := indexer.Sections()
+		if sections >= target {
+			log.Info("Log index built", "sections", sections, "blocks", sections*size)
+			return nil
+		}
+		if sections > last {
+			last, progress = sections, time.Now()
+		}
+		if time.Since(progress) > logIndexStallTimeout {
+			return errors.New("log indexing stalled")
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Building log index", "sections", sections, "target", target)
+			logged = time.Now()
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
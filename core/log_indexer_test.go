@@ -0,0 +1,134 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// writeLogIndexChain writes a canonical chain of headers from the given parent,
+// each block having the logs of the given addresses, all under the same topic.
+func writeLogIndexChain(db ethdb.Database, parent *types.Header, addresses []common.Address, topic common.Hash) []*types.Header {
+	var headers []*types.Header
+	for i, address := range addresses {
+		header := &types.Header{Number: big.NewInt(int64(i)), Extra: topic.Bytes()}
+		if parent != nil {
+			header.Number = new(big.Int).Add(parent.Number, big.NewInt(1))
+			header.ParentHash = parent.Hash()
+		}
+		var receipts types.Receipts
+		if address != (common.Address{}) {
+			receipts = types.Receipts{{Logs: []*types.Log{{Address: address, Topics: []common.Hash{topic}}}}}
+			header.Bloom = types.CreateBloom(receipts)
+		}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), header.Number.Uint64())
+		rawdb.WriteReceipts(db, header.Hash(), header.Number.Uint64(), receipts)
+
+		headers = append(headers, header)
+		parent = header
+	}
+	return headers
+}
+
+// indexLogSection indexes a section of the log index.
+func indexLogSection(t *testing.T, indexer *LogIndexer, section uint64, headers []*types.Header) {
+	if err := indexer.Reset(context.Background(), section, common.Hash{}); err != nil {
+		t.Fatalf("failed to reset section %d: %v", section, err)
+	}
+	for _, header := range headers {
+		if err := indexer.Process(context.Background(), header); err != nil {
+			t.Fatalf("failed to process block %d: %v", header.Number, err)
+		}
+	}
+	if err := indexer.Commit(); err != nil {
+		t.Fatalf("failed to commit section %d: %v", section, err)
+	}
+}
+
+// Tests that the log index looks up the blocks by address and first topic, and
+// that the reorged sections are dropped when reindexed.
+func TestLogIndexer(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		indexer = &LogIndexer{db: db}
+		none    = common.Address{}
+		addr1   = common.HexToAddress("0x01")
+		addr2   = common.HexToAddress("0x02")
+		topic1  = common.HexToHash("0x01")
+		topic2  = common.HexToHash("0x02")
+	)
+	headers := writeLogIndexChain(db, nil, []common.Address{none, none, addr1, none, none, addr2, none, none}, topic1)
+	indexLogSection(t, indexer, 0, headers[:4])
+	indexLogSection(t, indexer, 1, headers[4:])
+
+	lookup := func(begin, end uint64, addresses []common.Address, topics []common.Hash) []uint64 {
+		numbers, err := LookupLogIndex(db, 4, begin, end, addresses, topics)
+		if err != nil {
+			t.Fatalf("failed to look up log index: %v", err)
+		}
+		return numbers
+	}
+	tests := []struct {
+		begin, end uint64
+		addresses  []common.Address
+		topics     []common.Hash
+		want       []uint64
+	}{
+		{0, 7, []common.Address{addr1}, nil, []uint64{2}},
+		{0, 7, []common.Address{addr1, addr2}, nil, []uint64{2, 5}},
+		{0, 7, nil, []common.Hash{topic1}, []uint64{2, 5}},
+		{0, 7, []common.Address{addr2}, []common.Hash{topic1}, []uint64{5}},
+		{0, 7, []common.Address{addr2}, []common.Hash{topic2}, nil},
+		{3, 7, []common.Address{addr1, addr2}, nil, []uint64{5}},
+		{0, 4, []common.Address{addr1, addr2}, nil, []uint64{2}},
+	}
+	for i, tt := range tests {
+		if have := lookup(tt.begin, tt.end, tt.addresses, tt.topics); !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: blocks mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+	// Reorg the second section, its stale entries must be ignored until reindexed
+	reorged := writeLogIndexChain(db, headers[3], []common.Address{none, none, addr1, none}, topic2)
+	if have := lookup(0, 7, []common.Address{addr1, addr2}, nil); !reflect.DeepEqual(have, []uint64{2}) {
+		t.Errorf("stale blocks mismatch: have %v, want %v", have, []uint64{2})
+	}
+	indexLogSection(t, indexer, 1, reorged)
+	if have := lookup(0, 7, []common.Address{addr1, addr2}, nil); !reflect.DeepEqual(have, []uint64{2, 6}) {
+		t.Errorf("reorged blocks mismatch: have %v, want %v", have, []uint64{2, 6})
+	}
+	if have := lookup(0, 7, nil, []common.Hash{topic2}); !reflect.DeepEqual(have, []uint64{6}) {
+		t.Errorf("reorged topic blocks mismatch: have %v, want %v", have, []uint64{6})
+	}
+	// The entries of the reorged section must be gone
+	stale := 0
+	rawdb.IterateLogIndex(db, rawdb.LogIndexAddress, addr2.Bytes(), 0, func(section uint64, head common.Hash, numbers []uint64) bool {
+		stale++
+		return true
+	})
+	if stale != 0 {
+		t.Errorf("reorged entries left: %d", stale)
+	}
+}
@@ -242,6 +242,51 @@ func WriteLastPivotNumber(db ethdb.KeyValueWriter, pivot uint64) {
 	}
 }
 
+// SyncCheckpoint is the finalized checkpoint a chain was synced from instead of
+// the genesis, the headers below it are backfilled down to Tail, then the bodies
+// and receipts up from the genesis to Filled. It's deleted once the chain below
+// the checkpoint is complete.
+type SyncCheckpoint struct {
+	Number uint64      // Number of the checkpoint block
+	Hash   common.Hash // Hash of the checkpoint block
+	Tail   uint64      // Lowest block whose header is available
+	Filled uint64      `rlp:"optional"` // Highest block whose body and receipts are available from the genesis
+}
+
+// ReadSyncCheckpoint retrieves the checkpoint the chain was synced from, or nil
+// if it was synced from the genesis.
+func ReadSyncCheckpoint(db ethdb.KeyValueReader) *SyncCheckpoint {
+	data, _ := db.Get(syncCheckpointKey)
+	if len(data) == 0 {
+		return nil
+	}
+	checkpoint := new(SyncCheckpoint)
+	if err := rlp.DecodeBytes(data, checkpoint); err != nil {
+		log.Error("Invalid sync checkpoint in database", "err", err)
+		return nil
+	}
+	return checkpoint
+}
+
+// WriteSyncCheckpoint stores the checkpoint the chain was synced from.
+func WriteSyncCheckpoint(db ethdb.KeyValueWriter, checkpoint *SyncCheckpoint) {
+	enc, err := rlp.EncodeToBytes(checkpoint)
+	if err != nil {
+		log.Crit("Failed to encode sync checkpoint", "err", err)
+	}
+	if err := db.Put(syncCheckpointKey, enc); err != nil {
+		log.Crit("Failed to store sync checkpoint", "err", err)
+	}
+}
+
+// DeleteSyncCheckpoint deletes the sync checkpoint, once the chain below it is
+// complete.
+func DeleteSyncCheckpoint(db ethdb.KeyValueWriter) {
+	if err := db.Delete(syncCheckpointKey); err != nil {
+		log.Crit("Failed to delete sync checkpoint", "err", err)
+	}
+}
+
 // ReadFastTrieProgress retrieves the number of tries nodes fast synced to allow
 // reporting correct numbers across restarts.
 func ReadFastTrieProgress(db ethdb.KeyValueReader) uint64 {
@@ -994,6 +1039,21 @@ func LastFinalizedBlockNumber(db ethdb.Reader) *big.Int {
 	return new(big.Int).SetBytes(data)
 }
 
+// ReadFinalizedImmutableNumber returns the number of the highest block which is
+// immutable by the finality, that is the last finalized block minus the margin.
+// The second value is false if no canonical block is finalized beyond the margin.
+func ReadFinalizedImmutableNumber(db ethdb.Reader, margin uint64) (uint64, bool) {
+	finalized := LastFinalizedBlockNumber(db)
+	if !finalized.IsUint64() || finalized.Uint64() <= margin {
+		return 0, false
+	}
+	status, hash := ReadBlockStatusByNum(db, finalized)
+	if status != types.BasFinalized || hash != ReadCanonicalHash(db, finalized.Uint64()) {
+		return 0, false
+	}
+	return finalized.Uint64() - margin, true
+}
+
 func IsReadyReadBlockStatus(db ethdb.Reader) (bool, error) {
 	return db.Has(blockStatusKey)
 }
@@ -1028,6 +1088,52 @@ func WriteBlockStatus(db ethdb.KeyValueStore, num *big.Int, hash common.Hash, st
 	return nil
 }
 
+// DeleteBlockStatus removes the Casper FFG status of a block number.
+func DeleteBlockStatus(db ethdb.KeyValueWriter, num *big.Int) {
+	key := append(blockStatusKey, num.Bytes()...)
+	if err := db.Delete(key); err != nil {
+		log.Crit("Failed to delete block status", "err", err)
+	}
+}
+
+// ReadAllBlockStatuses retrieves all the stored block statuses, ordered by block
+// number. The keys aren't ordered by number, so the whole range is iterated.
+func ReadAllBlockStatuses(db ethdb.Iteratee) types.BlockStatusList {
+	it := db.NewIterator(blockStatusKey, nil)
+	defer it.Release()
+
+	var statuses types.BlockStatusList
+	for it.Next() {
+		var bs types.BlockStatus
+		if err := rlp.DecodeBytes(it.Value(), &bs); err != nil {
+			log.Warn("Failed to decode block status", "key", common.Bytes2Hex(it.Key()), "err", err)
+			continue
+		}
+		if !bytes.Equal(it.Key()[len(blockStatusKey):], bs.BlockNumber.Bytes()) {
+			continue
+		}
+		statuses = append(statuses, &bs)
+	}
+	sort.Sort(statuses)
+	return statuses
+}
+
+// ReadAllLastAttestNumbers retrieves the last attested numbers of all the local
+// validators which ever attested.
+func ReadAllLastAttestNumbers(db ethdb.Iteratee) map[common.Address]*big.Int {
+	it := db.NewIterator(lastAttestPrefix, nil)
+	defer it.Release()
+
+	numbers := make(map[common.Address]*big.Int)
+	for it.Next() {
+		if len(it.Key()) != len(lastAttestPrefix)+common.AddressLength {
+			continue
+		}
+		numbers[common.BytesToAddress(it.Key()[len(lastAttestPrefix):])] = new(big.Int).SetBytes(it.Value())
+	}
+	return numbers
+}
+
 func ReadAllViolateCasperFFGPunish(db ethdb.Reader) []*types.ViolateCasperFFGPunish {
 	blob, err := db.Get(violateCasperFFGPunishKey)
 	if err != nil {
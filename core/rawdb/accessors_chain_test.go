@@ -955,3 +955,56 @@ func TestWriteAndReadAndDeleteAndClearViolateCasperFFGPunish(t *testing.T) {
 	pushList = ReadAllViolateCasperFFGPunish(db)
 	require.True(t, len(pushList) == 0)
 }
+
+func TestReadFinalizedImmutableNumber(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	// Nothing finalized yet
+	if _, ok := ReadFinalizedImmutableNumber(db, 10); ok {
+		t.Fatalf("immutable number found without finality")
+	}
+	hash := common.Hash{0x01}
+	WriteCanonicalHash(db, hash, 100)
+	WriteLastFinalizedBlockNumber(db, big.NewInt(100))
+
+	// The finalized block must have the finalized status
+	WriteBlockStatus(db, big.NewInt(100), hash, types.BasJustified)
+	if _, ok := ReadFinalizedImmutableNumber(db, 10); ok {
+		t.Fatalf("immutable number found for a justified block")
+	}
+	WriteBlockStatus(db, big.NewInt(100), hash, types.BasFinalized)
+	if number, ok := ReadFinalizedImmutableNumber(db, 10); !ok || number != 90 {
+		t.Fatalf("immutable number mismatch: have %d/%v, want 90/true", number, ok)
+	}
+	// The margin must not underflow
+	if _, ok := ReadFinalizedImmutableNumber(db, 100); ok {
+		t.Fatalf("immutable number found below the margin")
+	}
+	// The finalized block must be canonical
+	WriteCanonicalHash(db, common.Hash{0x02}, 100)
+	if _, ok := ReadFinalizedImmutableNumber(db, 10); ok {
+		t.Fatalf("immutable number found for a non-canonical block")
+	}
+}
+
+func TestReadAllBlockStatusesAndAttestNumbers(t *testing.T) {
+	db := NewMemoryDatabase()
+	for _, n := range []uint64{300, 0, 2, 256, 1} {
+		WriteBlockStatus(db, new(big.Int).SetUint64(n), common.Hash{byte(n)}, types.BasJustified)
+	}
+	DeleteBlockStatus(db, big.NewInt(2))
+
+	statuses := ReadAllBlockStatuses(db)
+	require.Equal(t, 4, len(statuses))
+	for i, n := range []uint64{0, 1, 256, 300} {
+		require.Equal(t, n, statuses[i].BlockNumber.Uint64())
+		require.Equal(t, common.Hash{byte(n)}, statuses[i].Hash)
+	}
+	WriteLastAttestNumber(db, common.Address{0x01}, big.NewInt(10))
+	WriteLastAttestNumber(db, common.Address{0x02}, big.NewInt(20))
+
+	numbers := ReadAllLastAttestNumbers(db)
+	require.Equal(t, 2, len(numbers))
+	require.Equal(t, uint64(10), numbers[common.Address{0x01}].Uint64())
+	require.Equal(t, uint64(20), numbers[common.Address{0x02}].Uint64())
+}
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -175,3 +176,126 @@ func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
 		log.Crit("Failed to delete bloom bits", "err", it.Error())
 	}
 }
+
+// Kinds of the log index entries.
+const (
+	LogIndexAddress byte = 'a' // Blocks with logs emitted by an address
+	LogIndexTopic   byte = 't' // Blocks with logs of a first topic
+)
+
+// LogIndexSection lists the addresses and topics indexed in a section of the log
+// index, so that the section can be deleted when it's reindexed after a reorg.
+type LogIndexSection struct {
+	Head      common.Hash
+	Addresses []common.Address
+	Topics    []common.Hash
+}
+
+// ReadLogIndexSection retrieves the contents of a log index section.
+func ReadLogIndexSection(db ethdb.KeyValueReader, section uint64) *LogIndexSection {
+	data, _ := db.Get(logIndexSectionKey(section))
+	if len(data) == 0 {
+		return nil
+	}
+	var contents LogIndexSection
+	if err := rlp.DecodeBytes(data, &contents); err != nil {
+		log.Error("Invalid log index section RLP", "section", section, "err", err)
+		return nil
+	}
+	return &contents
+}
+
+// WriteLogIndexSection stores the contents of a log index section.
+func WriteLogIndexSection(db ethdb.KeyValueWriter, section uint64, contents *LogIndexSection) {
+	data, err := rlp.EncodeToBytes(contents)
+	if err != nil {
+		log.Crit("Failed to RLP encode log index section", "err", err)
+	}
+	if err := db.Put(logIndexSectionKey(section), data); err != nil {
+		log.Crit("Failed to store log index section", "err", err)
+	}
+}
+
+// WriteLogIndex stores the numbers of the blocks of a section having logs of the
+// given address or first topic.
+func WriteLogIndex(db ethdb.KeyValueWriter, kind byte, value []byte, section uint64, head common.Hash, numbers []uint64) {
+	enc := make([]byte, 0, 8*len(numbers))
+	for _, number := range numbers {
+		enc = append(enc, encodeBlockNumber(number)...)
+	}
+	if err := db.Put(logIndexKey(kind, value, section, head), enc); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
+
+// IterateLogIndex calls fn in ascending order for every section from the given one
+// having logs of the given address or first topic, with the numbers of the blocks
+// having them, until fn returns false. The head of each section is passed along,
+// the sections of reorged chains may still be there.
+func IterateLogIndex(db ethdb.Iteratee, kind byte, value []byte, from uint64, fn func(section uint64, head common.Hash, numbers []uint64) bool) error {
+	prefix := append(append(append([]byte{}, logIndexPrefix...), kind), value...)
+	it := db.NewIterator(prefix, encodeBlockNumber(from))
+	defer it.Release()
+
+	for it.Next() {
+		key, blob := it.Key(), it.Value()
+		if len(key) != len(prefix)+8+common.HashLength || len(blob)%8 != 0 {
+			continue
+		}
+		section := binary.BigEndian.Uint64(key[len(prefix):])
+		head := common.BytesToHash(key[len(prefix)+8:])
+
+		numbers := make([]uint64, len(blob)/8)
+		for i := range numbers {
+			numbers[i] = binary.BigEndian.Uint64(blob[8*i:])
+		}
+		if !fn(section, head, numbers) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// DeleteLogIndexSection removes a log index section with all its entries.
+func DeleteLogIndexSection(db ethdb.KeyValueStore, section uint64) {
+	contents := ReadLogIndexSection(db, section)
+	if contents == nil {
+		return
+	}
+	batch := db.NewBatch()
+	for _, address := range contents.Addresses {
+		batch.Delete(logIndexKey(LogIndexAddress, address.Bytes(), section, contents.Head))
+	}
+	for _, topic := range contents.Topics {
+		batch.Delete(logIndexKey(LogIndexTopic, topic.Bytes(), section, contents.Head))
+	}
+	batch.Delete(logIndexSectionKey(section))
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete log index section", "err", err)
+	}
+}
+
+// DeleteLogIndex removes the whole log index, along with the progress of its
+// indexer.
+func DeleteLogIndex(db ethdb.KeyValueStore) {
+	batch := db.NewBatch()
+	for _, prefix := range [][]byte{logIndexPrefix, logIndexSectionPrefix, LogIndexPrefix} {
+		it := db.NewIterator(prefix, nil)
+		for it.Next() {
+			batch.Delete(it.Key())
+			if batch.ValueSize() > ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Crit("Failed to delete log index", "err", err)
+				}
+				batch.Reset()
+			}
+		}
+		if it.Error() != nil {
+			log.Crit("Failed to iterate log index", "err", it.Error())
+		}
+		it.Release()
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete log index", "err", err)
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
@@ -266,6 +267,84 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer
 	return frdb, nil
 }
 
+// Supported key-value storage engines of the persistent databases.
+const (
+	DBLeveldb = "leveldb"
+	DBPebble  = "pebble"
+)
+
+// PreexistingDatabase checks the given data directory whether a database is
+// already instantiated at that location, and if so, returns the type of database
+// (or the empty string).
+func PreexistingDatabase(path string) string {
+	// Both engines keep a CURRENT file, pebble records its OPTIONS too
+	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
+		return ""
+	}
+	if matches, err := filepath.Glob(filepath.Join(path, "OPTIONS*")); err == nil && len(matches) > 0 {
+		return DBPebble
+	}
+	return DBLeveldb
+}
+
+// OpenOptions contains the options to apply when opening a database.
+type OpenOptions struct {
+	Type      string // "leveldb" | "pebble", the pre-existing one if empty
+	Directory string // The datadir of the key-value store
+	Namespace string // The namespace for the database metrics
+	Cache     int    // The capacity (in megabytes) of the data caching
+	Handles   int    // The number of open files to allow
+	ReadOnly  bool
+}
+
+// openKeyValueDatabase opens a disk-based key-value database, checking the
+// requested engine against the pre-existing database of the directory:
+//
+//	type == null          type != null
+//	   +----------------------------------------
+//	db is non-existent |  leveldb default  |  specified type
+//	db is existent     |  from db          |  specified type (if compatible)
+func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
+	existing := PreexistingDatabase(o.Directory)
+	if o.Type != "" && existing != "" && o.Type != existing {
+		return nil, fmt.Errorf("db.engine choice was %s but found pre-existing %s database in specified data directory", o.Type, existing)
+	}
+	engine := o.Type
+	if engine == "" {
+		engine = existing
+	}
+	switch engine {
+	case DBPebble:
+		log.Info("Using pebble as the backing database")
+		return NewPebbleDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+	case DBLeveldb, "":
+		log.Info("Using leveldb as the backing database")
+		return NewLevelDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
+	default:
+		return nil, fmt.Errorf("unknown db.engine %q", engine)
+	}
+}
+
+// Open opens a key-value database of the requested engine without a freezer.
+func Open(o OpenOptions) (ethdb.Database, error) {
+	return openKeyValueDatabase(o)
+}
+
+// OpenWithFreezer opens a key-value database of the requested engine, with a
+// freezer moving immutable chain segments into cold storage.
+func OpenWithFreezer(o OpenOptions, freezer string) (ethdb.Database, error) {
+	kvdb, err := openKeyValueDatabase(o)
+	if err != nil {
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithFreezer(kvdb, freezer, o.Namespace, o.ReadOnly)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -321,6 +400,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		storageSnaps    stat
 		preimages       stat
 		bloomBits       stat
+		logIndex        stat
 		cliqueSnaps     stat
 		chaosSnaps      stat
 
@@ -380,6 +460,13 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, logIndexPrefix) && (len(key) == len(logIndexPrefix)+1+common.AddressLength+8+common.HashLength ||
+			len(key) == len(logIndexPrefix)+1+common.HashLength+8+common.HashLength):
+			logIndex.Add(size)
+		case bytes.HasPrefix(key, logIndexSectionPrefix) && len(key) == len(logIndexSectionPrefix)+8:
+			logIndex.Add(size)
+		case bytes.HasPrefix(key, LogIndexPrefix):
+			logIndex.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
 		case bytes.HasPrefix(key, []byte("chaos-")) && len(key) == 6+common.HashLength:
@@ -439,6 +526,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
+		{"Key-Value store", "Log index", logIndex.Size(), logIndex.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
@@ -0,0 +1,71 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build arm64 || amd64
+// +build arm64 amd64
+
+package rawdb
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Tests that the engine of a pre-existing database is detected, and that it
+// can't be reopened with another engine.
+func TestOpenEngineMismatch(t *testing.T) {
+	for _, engine := range []string{DBLeveldb, DBPebble} {
+		dir, err := ioutil.TempDir("", "rawdb-engine-")
+		if err != nil {
+			t.Fatalf("failed to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		if have := PreexistingDatabase(dir); have != "" {
+			t.Fatalf("%s: found pre-existing database %q in an empty directory", engine, have)
+		}
+		db, err := Open(OpenOptions{Type: engine, Directory: dir, Cache: 16, Handles: 16})
+		if err != nil {
+			t.Fatalf("%s: failed to create database: %v", engine, err)
+		}
+		if err := db.Put([]byte("key"), []byte("value")); err != nil {
+			t.Fatalf("%s: failed to write: %v", engine, err)
+		}
+		db.Close()
+
+		if have := PreexistingDatabase(dir); have != engine {
+			t.Fatalf("pre-existing database mismatch: have %q, want %q", have, engine)
+		}
+		other := DBPebble
+		if engine == DBPebble {
+			other = DBLeveldb
+		}
+		if _, err := Open(OpenOptions{Type: other, Directory: dir, Cache: 16, Handles: 16}); err == nil || !strings.Contains(err.Error(), "pre-existing "+engine) {
+			t.Fatalf("%s: reopened as %s, error %v", engine, other, err)
+		}
+		// Without an explicit engine, the pre-existing one is used
+		db, err = Open(OpenOptions{Directory: dir, Cache: 16, Handles: 16})
+		if err != nil {
+			t.Fatalf("%s: failed to reopen database: %v", engine, err)
+		}
+		if val, err := db.Get([]byte("key")); err != nil || string(val) != "value" {
+			t.Fatalf("%s: value mismatch after reopening: %q, %v", engine, val, err)
+		}
+		db.Close()
+	}
+}
@@ -368,20 +368,28 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 			continue
 		}
 		number := ReadHeaderNumber(nfdb, hash)
-		threshold := atomic.LoadUint64(&f.threshold)
+		if number == nil {
+			log.Error("Current full block number unavailable", "hash", hash)
+			backoff = true
+			continue
+		}
+		immutable, ok := f.immutableNumber(nfdb, *number)
 
 		switch {
-		case number == nil:
-			log.Error("Current full block number unavailable", "hash", hash)
+		case ReadSyncCheckpoint(nfdb) != nil:
+			// The ancients are appended from the genesis, but there are no bodies
+			// nor receipts below the checkpoint the chain was synced from, until
+			// the backfill completes and deletes the checkpoint
+			log.Debug("Chain below sync checkpoint incomplete, not freezing")
 			backoff = true
 			continue
 
-		case *number < threshold:
-			log.Debug("Current full block not old enough", "number", *number, "hash", hash, "delay", threshold)
+		case !ok:
+			log.Debug("Current full block not old enough", "number", *number, "hash", hash, "delay", atomic.LoadUint64(&f.threshold))
 			backoff = true
 			continue
 
-		case *number-threshold <= f.frozen:
+		case immutable <= f.frozen:
 			log.Debug("Ancient blocks frozen already", "number", *number, "hash", hash, "frozen", f.frozen)
 			backoff = true
 			continue
@@ -397,7 +405,7 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 		var (
 			start    = time.Now()
 			first, _ = f.Ancients()
-			limit    = *number - threshold
+			limit    = immutable
 		)
 		if limit-first > freezerBatchLimit {
 			limit = first + freezerBatchLimit
@@ -495,6 +503,26 @@ func (f *freezer) freeze(db ethdb.KeyValueStore) {
 	}
 }
 
+// immutableNumber returns the number of the highest block which can be frozen
+// with the given head. Blocks are immutable once they're deeper than the threshold,
+// or below the last finalized block (with a safety margin) on chains with finality.
+// The second value is false if there's no such block.
+func (f *freezer) immutableNumber(nfdb *nofreezedb, head uint64) (uint64, bool) {
+	var (
+		immutable uint64
+		ok        bool
+	)
+	if threshold := atomic.LoadUint64(&f.threshold); head >= threshold {
+		immutable, ok = head-threshold, true
+	}
+	if finalized, found := ReadFinalizedImmutableNumber(nfdb, params.FinalityImmutabilityMargin); found && finalized <= head {
+		if !ok || finalized > immutable {
+			immutable, ok = finalized, true
+		}
+	}
+	return immutable, ok
+}
+
 func (f *freezer) freezeRange(nfdb *nofreezedb, number, limit uint64) (hashes []common.Hash, err error) {
 	hashes = make([]common.Hash, 0, limit-number)
 
@@ -69,6 +69,10 @@ var (
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
+	// syncCheckpointKey tracks the finalized checkpoint the chain was synced from,
+	// and the progress of backfilling the headers below it.
+	syncCheckpointKey = []byte("SyncCheckpoint")
+
 	// badBlockKey tracks the list of bad blocks seen by local
 	badBlockKey = []byte("InvalidBlock")
 
@@ -86,6 +90,8 @@ var (
 
 	txLookupPrefix        = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix       = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	logIndexPrefix        = []byte("x") // logIndexPrefix + kind + address or topic + section (uint64 big endian) + hash -> block numbers
+	logIndexSectionPrefix = []byte("X") // logIndexSectionPrefix + section (uint64 big endian) -> log index section
 	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
@@ -104,6 +110,7 @@ var (
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	LogIndexPrefix       = []byte("iL") // LogIndexPrefix is the data table of the log indexer to track its progress
 
 	preimageCounter    = metrics.NewRegisteredCounter("db/preimage/total", nil)
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
@@ -216,6 +223,17 @@ func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	return key
 }
 
+// logIndexKey = logIndexPrefix + kind + value + section (uint64 big endian) + hash
+func logIndexKey(kind byte, value []byte, section uint64, hash common.Hash) []byte {
+	key := append(append(append(logIndexPrefix, kind), value...), encodeBlockNumber(section)...)
+	return append(key, hash.Bytes()...)
+}
+
+// logIndexSectionKey = logIndexSectionPrefix + section (uint64 big endian)
+func logIndexSectionKey(section uint64) []byte {
+	return append(logIndexSectionPrefix, encodeBlockNumber(section)...)
+}
+
 // preimageKey = PreimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(PreimagePrefix, hash.Bytes()...)
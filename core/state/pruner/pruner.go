@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 )
@@ -247,20 +248,31 @@ func (p *Pruner) Prune(root common.Hash) error {
 	// target. The reason for picking it is:
 	// - in most of the normal cases, the related state is available
 	// - the probability of this layer being reorg is very low
-	var layers []snapshot.Snapshot
+	//
+	// On chains with finality, the state of the last finalized block (with a
+	// safety margin) can't be reorged at all, so it's picked instead as soon as
+	// the snapshot layers reach it.
+	var (
+		layers    []snapshot.Snapshot
+		finalized bool
+	)
 	if root == (common.Hash{}) {
 		// Retrieve all snapshot layers from the current HEAD.
 		// In theory there are 128 difflayers + 1 disk layer present,
 		// so 128 diff layers are expected to be returned.
 		layers = p.snaptree.Snapshots(p.headHeader.Root, 128, true)
-		if len(layers) != 128 {
+		if target := p.finalizedLayer(layers); target > 0 {
+			layers, finalized = layers[:target+1], true
+			root = layers[target].Root()
+		} else if len(layers) != 128 {
 			// Reject if the accumulated diff layers are less than 128. It
 			// means in most of normal cases, there is no associated state
 			// with bottom-most diff layer.
 			return fmt.Errorf("snapshot not old enough yet: need %d more blocks", 128-len(layers))
+		} else {
+			// Use the bottom-most diff layer as the target
+			root = layers[len(layers)-1].Root()
 		}
-		// Use the bottom-most diff layer as the target
-		root = layers[len(layers)-1].Root()
 	}
 	// Ensure the root is really present. The weak assumption
 	// is the presence of root can indicate the presence of the
@@ -293,7 +305,9 @@ func (p *Pruner) Prune(root common.Hash) error {
 			return fmt.Errorf("associated state[%x] is not present", root)
 		}
 	} else {
-		if len(layers) > 0 {
+		if finalized {
+			log.Info("Selecting finalized difflayer as the pruning target", "root", root, "depth", len(layers)-1)
+		} else if len(layers) > 0 {
 			log.Info("Selecting bottom-most difflayer as the pruning target", "root", root, "height", p.headHeader.Number.Uint64()-127)
 		} else {
 			log.Info("Selecting user-specified state as the pruning target", "root", root)
@@ -335,6 +349,29 @@ func (p *Pruner) Prune(root common.Hash) error {
 	return prune(p.snaptree, root, p.db, p.stateBloom, filterName, middleRoots, start)
 }
 
+// finalizedLayer returns the index of the snapshot layer paired with the state of
+// the last finalized block minus the safety margin, or 0 if it's not found. The
+// HEAD and HEAD-1 layers are never picked.
+func (p *Pruner) finalizedLayer(layers []snapshot.Snapshot) int {
+	number, ok := rawdb.ReadFinalizedImmutableNumber(p.db, params.FinalityImmutabilityMargin)
+	if !ok || number > p.headHeader.Number.Uint64() {
+		return 0
+	}
+	header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, number), number)
+	if header == nil {
+		return 0
+	}
+	for i := len(layers) - 1; i >= 2; i-- {
+		if layers[i].Root() == header.Root {
+			if blob := rawdb.ReadTrieNode(p.db, header.Root); len(blob) != 0 {
+				return i
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
 // RecoverPruning will resume the pruning procedure during the system restart.
 // This function is used in this case: user tries to prune state data, but the
 // system was interrupted midway because of crash or manual-kill. In this case
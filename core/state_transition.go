@@ -65,6 +65,7 @@ type StateTransition struct {
 	feeAddress  common.Address
 	feePercent  uint64 //meta transaction fee percent
 	realPayload []byte //the real transaction fee percent
+	txTraced    bool   // Whether the tracer was notified of the transaction start
 }
 
 // Message represents a message sent to a contract.
@@ -288,6 +289,22 @@ func (st *StateTransition) preCheck() error {
 	if err := st.metaTransactionCheck(); err != nil {
 		return err
 	}
+	// The gas is bought from the sender and the fee payer and the tip paid to
+	// the fee receiver outside of the EVM, the recipient is credited before the
+	// EVM tracing starts
+	accounts := []common.Address{st.msg.From(), st.evm.Context.Coinbase}
+	if st.evm.ChainConfig().Chaos != nil {
+		accounts[1] = consensus.FeeRecoder
+	}
+	if st.isMeta {
+		accounts = append(accounts, st.feeAddress)
+	}
+	if to := st.msg.To(); to != nil {
+		accounts = append(accounts, *to)
+	}
+	st.evm.CaptureTxStart(accounts...)
+	st.txTraced = true
+
 	if st.isMeta {
 		return st.buyGasMeta()
 	}
@@ -340,6 +357,13 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	// 5. there is no overflow when calculating intrinsic gas
 	// 6. caller has enough balance to cover asset transfer for **topmost** call
 
+	// The tracer is notified of the transaction end on every exit once notified
+	// of its start, even if the gas purchase or a later check fails
+	defer func() {
+		if st.txTraced {
+			st.evm.CaptureTxEnd()
+		}
+	}()
 	// Check clauses 1-3, buy gas if everything is correct
 	if err := st.preCheck(); err != nil {
 		return nil, err
@@ -397,17 +421,20 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		// After EIP-3529: refunds are capped to gasUsed / 5
 		st.refundGas(params.RefundQuotientEIP3529)
 	}
-	effectiveTip := st.gasPrice
-	if london {
-		effectiveTip = cmath.BigMin(st.gasTipCap, new(big.Int).Sub(st.gasFeeCap, st.evm.Context.BaseFee))
-	}
-	tip := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), effectiveTip)
-	if st.evm.ChainConfig().Chaos != nil {
-		st.state.AddBalance(consensus.FeeRecoder, tip)
-	} else {
-		st.state.AddBalance(st.evm.Context.Coinbase, tip)
+	// Skip the fee payment of the calls without gas price when the base fee is
+	// disabled (eth_call), the tip would be negative
+	if !st.evm.Config.NoBaseFee || st.gasFeeCap.Sign() != 0 || st.gasTipCap.Sign() != 0 {
+		effectiveTip := st.gasPrice
+		if london {
+			effectiveTip = cmath.BigMin(st.gasTipCap, new(big.Int).Sub(st.gasFeeCap, st.evm.Context.BaseFee))
+		}
+		tip := new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), effectiveTip)
+		if st.evm.ChainConfig().Chaos != nil {
+			st.state.AddBalance(consensus.FeeRecoder, tip)
+		} else {
+			st.state.AddBalance(st.evm.Context.Coinbase, tip)
+		}
 	}
-
 	return &ExecutionResult{
 		UsedGas:    st.gasUsed(),
 		Err:        vmerr,
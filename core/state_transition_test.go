@@ -0,0 +1,133 @@
+// Copyright 2020 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// txBoundsTracer counts the transaction boundaries it's notified of.
+type txBoundsTracer struct {
+	vm.EVMLogger
+	starts, ends int
+}
+
+func (t *txBoundsTracer) CaptureTxStart(env *vm.EVM, accounts []common.Address) { t.starts++ }
+func (t *txBoundsTracer) CaptureTxEnd(env *vm.EVM)                              { t.ends++ }
+
+// Tests that the tracers notified of a transaction start are notified of its end
+// too, also if the transaction fails after the start.
+func TestStateTransitionTxBounds(t *testing.T) {
+	var (
+		sender = common.HexToAddress("0x1000")
+		to     = common.HexToAddress("0x2000")
+		config = params.TestChainConfig
+	)
+	tests := []struct {
+		name    string
+		balance *big.Int
+		gas     uint64
+		starts  int
+		fails   bool
+	}{
+		{"success", big.NewInt(params.Ether), params.TxGas, 1, false},
+		{"gas purchase", common.Big0, params.TxGas, 1, true},
+		{"intrinsic gas", big.NewInt(params.Ether), params.TxGas - 1, 1, true},
+	}
+	for _, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetBalance(sender, tt.balance)
+
+		tracer := &txBoundsTracer{EVMLogger: vm.NewStructLogger(nil)}
+		context := vm.BlockContext{
+			CanTransfer: CanTransfer,
+			Transfer:    Transfer,
+			BlockNumber: big.NewInt(1),
+			Time:        big.NewInt(1),
+			Difficulty:  big.NewInt(1),
+			GasLimit:    params.TxGas,
+			BaseFee:     big.NewInt(params.InitialBaseFee),
+		}
+		gasPrice := big.NewInt(params.InitialBaseFee)
+		msg := types.NewMessage(sender, &to, 0, common.Big1, tt.gas, gasPrice, gasPrice, common.Big0, nil, nil, false)
+		evm := vm.NewEVM(context, NewEVMTxContext(msg), statedb, config, vm.Config{Debug: true, Tracer: tracer})
+
+		_, err := ApplyMessage(evm, msg, new(GasPool).AddGas(params.TxGas))
+		if fails := err != nil; fails != tt.fails {
+			t.Errorf("%s: failure mismatch: have %v, want failure %v", tt.name, err, tt.fails)
+		}
+		if tracer.starts != tt.starts || tracer.ends != tt.starts {
+			t.Errorf("%s: boundaries mismatch: have %d starts %d ends, want %d", tt.name, tracer.starts, tracer.ends, tt.starts)
+		}
+	}
+}
+
+// Tests that the calls without a gas price run with the base fee disabled, as by
+// eth_call, don't credit the coinbase with a negative tip, while the priced ones
+// still pay theirs.
+func TestStateTransitionNoBaseFeeTip(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x1000")
+		to       = common.HexToAddress("0x2000")
+		coinbase = common.HexToAddress("0x3000")
+		config   = params.TestChainConfig
+		baseFee  = big.NewInt(params.InitialBaseFee)
+	)
+	tests := []struct {
+		name      string
+		feeCap    *big.Int
+		tipCap    *big.Int
+		noBaseFee bool
+		reward    *big.Int
+	}{
+		{"zero fee call", common.Big0, common.Big0, true, common.Big0},
+		{"priced call", new(big.Int).Add(baseFee, common.Big2), common.Big1, true, new(big.Int).SetUint64(params.TxGas)},
+		{"transaction", new(big.Int).Add(baseFee, common.Big2), common.Big1, false, new(big.Int).SetUint64(params.TxGas)},
+	}
+	for _, tt := range tests {
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetBalance(sender, big.NewInt(params.Ether))
+
+		context := vm.BlockContext{
+			CanTransfer: CanTransfer,
+			Transfer:    Transfer,
+			Coinbase:    coinbase,
+			BlockNumber: big.NewInt(1),
+			Time:        big.NewInt(1),
+			Difficulty:  big.NewInt(1),
+			GasLimit:    params.TxGas,
+			BaseFee:     baseFee,
+		}
+		msg := types.NewMessage(sender, &to, 0, common.Big1, params.TxGas, tt.feeCap, tt.feeCap, tt.tipCap, nil, nil, tt.noBaseFee)
+		evm := vm.NewEVM(context, NewEVMTxContext(msg), statedb, config, vm.Config{NoBaseFee: tt.noBaseFee})
+
+		if _, err := ApplyMessage(evm, msg, new(GasPool).AddGas(params.TxGas)); err != nil {
+			t.Fatalf("%s: failed to apply message: %v", tt.name, err)
+		}
+		if reward := statedb.GetBalance(coinbase); reward.Cmp(tt.reward) != 0 {
+			t.Errorf("%s: coinbase reward mismatch: have %v, want %v", tt.name, reward, tt.reward)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// txDropLogSize is the number of dropped transactions the pool remembers.
+const txDropLogSize = 16384
+
+// TxDropReason is the reason the pool dropped a transaction for.
+type TxDropReason string
+
+const (
+	TxDropUnderpriced  TxDropReason = "underpriced"   // Discarded for better paying transactions, the pool being full
+	TxDropReplaced     TxDropReason = "replaced"      // Replaced by a transaction of the same nonce paying more
+	TxDropAccessDenied TxDropReason = "access-denied" // Sender or recipient denied by the access filter
+	TxDropExpired      TxDropReason = "expired"       // Meta transaction past its block number limit
+	TxDropNonceTooLow  TxDropReason = "nonce-too-low" // Nonce used by another transaction
+	TxDropNoFunds      TxDropReason = "no-funds"      // Sender unable to pay for the transaction
+	TxDropEvicted      TxDropReason = "evicted"       // Evicted by the limits of the pool or for staying queued too long
+)
+
+// TxDrop records a transaction dropped by the pool.
+type TxDrop struct {
+	Reason     TxDropReason
+	ReplacedBy common.Hash // Transaction replacing the dropped one, if replaced
+	Time       time.Time
+}
+
+// txDropLog remembers the most recent transactions dropped by the pool, so the
+// users can find out what happened to their transactions.
+type txDropLog struct {
+	drops *lru.Cache
+}
+
+func newTxDropLog() *txDropLog {
+	drops, _ := lru.New(txDropLogSize)
+	return &txDropLog{drops: drops}
+}
+
+// add records a dropped transaction.
+func (l *txDropLog) add(hash common.Hash, reason TxDropReason) {
+	l.drops.Add(hash, &TxDrop{Reason: reason, Time: time.Now()})
+}
+
+// replaced records a transaction replaced by another one.
+func (l *txDropLog) replaced(hash common.Hash, by common.Hash) {
+	l.drops.Add(hash, &TxDrop{Reason: TxDropReplaced, ReplacedBy: by, Time: time.Now()})
+}
+
+// get returns the record of a dropped transaction, nil if it's not known.
+func (l *txDropLog) get(hash common.Hash) *TxDrop {
+	if drop, ok := l.drops.Get(hash); ok {
+		return drop.(*TxDrop)
+	}
+	return nil
+}
+
+// forget removes the record of a transaction added back to the pool.
+func (l *txDropLog) forget(hash common.Hash) {
+	l.drops.Remove(hash)
+}
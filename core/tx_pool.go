@@ -119,6 +119,9 @@ var (
 	// throttleTxMeter counts how many transactions are rejected due to too-many-changes between
 	// txpool reorgs.
 	throttleTxMeter = metrics.NewRegisteredMeter("txpool/throttle", nil)
+
+	// priorityTxMeter counts the transactions accepted through the priority lane
+	priorityTxMeter = metrics.NewRegisteredMeter("txpool/priority", nil)
 	// reorgDurationTimer measures how long time a txpool reorg takes.
 	reorgDurationTimer = metrics.NewRegisteredTimer("txpool/reorgtime", nil)
 	// dropBetweenReorgHistogram counts how many drops we experience between two reorg runs. It is expected
@@ -156,14 +159,17 @@ type blockChain interface {
 type txFilter interface {
 	FilterTx(sender common.Address, tx *types.Transaction, header *types.Header, parentState *state.StateDB) error
 	CanCreate(state consensus.StateReader, addr common.Address, isContract bool, height *big.Int) bool
+	MaxGasBudget(number *big.Int, gasLimit uint64) uint64
+	IsPriorityTx(sender common.Address, tx *types.Transaction, header *types.Header) bool
 }
 
 // TxPoolConfig are the configuration parameters of the transaction pool.
 type TxPoolConfig struct {
-	Locals    []common.Address // Addresses that should be treated by default as local
-	NoLocals  bool             // Whether local transaction handling should be disabled
-	Journal   string           // Journal of local transactions to survive node restarts
-	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
+	Locals     []common.Address // Addresses that should be treated by default as local
+	NoLocals   bool             // Whether local transaction handling should be disabled
+	NoPriority bool             // Whether the priority lane for validator operations should be disabled
+	Journal    string           // Journal of local transactions to survive node restarts
+	Rejournal  time.Duration    // Time interval to regenerate the local transaction journal
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
@@ -260,6 +266,7 @@ type TxPool struct {
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
+	pendingNumber uint64         // Number of the next block, meta transactions expiring before are rejected
 
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
@@ -271,6 +278,7 @@ type TxPool struct {
 	priced  *txPricedList                // All transactions sorted by price
 
 	jamIndexer *txJamIndexer // tx jam indexer
+	drops      *txDropLog    // Recently dropped transactions and the reasons
 
 	txFilter         txFilter      // A specific consensus can use this to do some extra validation to a transaction
 	nextFilterHeader *types.Header // A mock header of next block for transaction filter
@@ -322,6 +330,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		gasPrice:        new(big.Int).SetUint64(config.PriceLimit),
 	}
 	pool.jamIndexer = newTxJamIndexer(config.JamConfig, pool)
+	pool.drops = newTxDropLog()
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
 		log.Info("Setting new local account", "address", addr)
@@ -354,10 +363,24 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 	return pool
 }
 
+// MaxGas returns the max gas a transaction can use to be accepted by the pool,
+// which is the max gas budget of the pending block.
+func (pool *TxPool) MaxGas() uint64 {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.currentMaxGas
+}
+
 // InitTxFilter sets the extra validator
 func (pool *TxPool) InitTxFilter(v txFilter) {
-	pool.makeFilterHeader(pool.chain.CurrentBlock().Header())
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	head := pool.chain.CurrentBlock().Header()
+	pool.makeFilterHeader(head)
 	pool.txFilter = v
+	pool.currentMaxGas = v.MaxGasBudget(new(big.Int).Add(head.Number, common.Big1), head.GasLimit)
 }
 
 func (pool *TxPool) makeFilterHeader(currHead *types.Header) {
@@ -432,6 +455,7 @@ func (pool *TxPool) loop() {
 					list := pool.queue[addr].Flatten()
 					for _, tx := range list {
 						pool.removeTx(tx.Hash(), true)
+						pool.drops.add(tx.Hash(), TxDropEvicted)
 					}
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
@@ -687,6 +711,13 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
 		return ErrInsufficientFunds
 	}
+	// Reject the meta transactions expired by the next block, malformed metadata
+	// is left to fail on execution
+	if types.IsMetaTransaction(tx.Data()) {
+		if meta, err := types.DecodeMetaData(tx.Data(), common.Big0); err == nil && meta.BlockNumLimit < pool.pendingNumber {
+			return ErrMetaTxExpired
+		}
+	}
 	// Ensure the transaction has more gas than the basic tx fee.
 	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, pool.istanbul)
 	if err != nil {
@@ -740,12 +771,26 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	if err := pool.validateTx(tx, isLocal); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		invalidTxMeter.Mark(1)
+		switch err {
+		case types.ErrAddressDenied:
+			pool.drops.add(hash, TxDropAccessDenied)
+		case ErrMetaTxExpired:
+			pool.drops.add(hash, TxDropExpired)
+		}
 		return false, err
 	}
+	// Validator operations in the priority lane are protected from the pricing eviction
+	// like the local transactions, but they still have to pay the minimum gas price.
+	// The priority is tracked per transaction, their senders aren't made local.
+	priority := !isLocal && pool.isPriorityTx(tx)
+	if priority {
+		log.Trace("Pooling priority transaction", "hash", hash)
+		priorityTxMeter.Mark(1)
+	}
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Slots()+numSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
-		if !isLocal && pool.priced.Underpriced(tx) {
+		if !isLocal && !priority && pool.priced.Underpriced(tx) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
 			underpricedTxMeter.Mark(1)
 			pool.jamIndexer.UnderPricedInc()
@@ -763,10 +808,10 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		// New transaction is better than our worse ones, make room for it.
 		// If it's a local transaction, forcibly discard all available transactions.
 		// Otherwise if we can't make enough room for new one, abort the operation.
-		drop, success := pool.priced.Discard(pool.all.Slots()-int(pool.config.GlobalSlots+pool.config.GlobalQueue)+numSlots(tx), isLocal)
+		drop, success := pool.priced.Discard(pool.all.Slots()-int(pool.config.GlobalSlots+pool.config.GlobalQueue)+numSlots(tx), isLocal || priority)
 
 		// Special case, we still can't make the room for the new remote one.
-		if !isLocal && !success {
+		if !isLocal && !priority && !success {
 			log.Trace("Discarding overflown transaction", "hash", hash)
 			overflowedTxMeter.Mark(1)
 			return false, ErrTxPoolOverflow
@@ -779,6 +824,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			underpricedTxMeter.Mark(1)
 			pool.jamIndexer.UnderPricedInc()
 			pool.removeTx(tx.Hash(), false)
+			pool.drops.add(tx.Hash(), TxDropUnderpriced)
 		}
 	}
 	// Try to replace an existing transaction in the pending pool
@@ -794,10 +840,16 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		if old != nil {
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
+			pool.drops.replaced(old.Hash(), hash)
 			pendingReplaceMeter.Mark(1)
 		}
-		pool.all.Add(tx, isLocal)
-		pool.priced.Put(tx, isLocal)
+		pool.drops.forget(hash)
+		if priority {
+			pool.all.AddPriority(tx)
+		} else {
+			pool.all.Add(tx, isLocal)
+			pool.priced.Put(tx, isLocal)
+		}
 		pool.journalTx(from, tx)
 		pool.queueTxEvent(tx)
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
@@ -806,11 +858,19 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		pool.beats[from] = time.Now()
 		return old != nil, nil
 	}
-	// New transaction isn't replacing a pending one, push into queue
-	replaced, err = pool.enqueueTx(hash, tx, isLocal, true)
+	// New transaction isn't replacing a pending one, push into queue. The priority
+	// lane transactions are tracked apart from the remotes, off the price heaps.
+	if priority {
+		pool.all.AddPriority(tx)
+	}
+	replaced, err = pool.enqueueTx(hash, tx, isLocal, !priority)
 	if err != nil {
+		if priority {
+			pool.all.Remove(hash)
+		}
 		return false, err
 	}
+	pool.drops.forget(hash)
 	// Mark local addresses and journal local transactions
 	if local && !pool.locals.contains(from) {
 		log.Info("Setting new local account", "address", from)
@@ -826,6 +886,16 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	return replaced, nil
 }
 
+// isPriorityTx reports whether the transaction belongs to the priority lane
+// defined by the consensus engine.
+func (pool *TxPool) isPriorityTx(tx *types.Transaction) bool {
+	if pool.txFilter == nil || pool.config.NoPriority {
+		return false
+	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	return pool.txFilter.IsPriorityTx(from, tx, pool.nextFilterHeader)
+}
+
 // enqueueTx inserts a new transaction into the non-executable transaction queue.
 //
 // Note, this method assumes the pool lock is held!
@@ -845,6 +915,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	if old != nil {
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
+		pool.drops.replaced(old.Hash(), hash)
 		queuedReplaceMeter.Mark(1)
 	} else {
 		// Nothing was replaced, bump the queued counter
@@ -894,6 +965,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		// An older transaction was better, discard this
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
+		pool.drops.replaced(hash, list.txs.Get(tx.Nonce()).Hash())
 		pendingDiscardMeter.Mark(1)
 		return false
 	}
@@ -901,6 +973,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	if old != nil {
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
+		pool.drops.replaced(old.Hash(), hash)
 		pendingReplaceMeter.Mark(1)
 	} else {
 		// Nothing was replaced, bump the pending counter
@@ -1049,6 +1122,12 @@ func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
 	return status
 }
 
+// Dropped returns the reason the pool dropped a transaction for, or nil if the
+// transaction wasn't dropped recently.
+func (pool *TxPool) Dropped(hash common.Hash) *TxDrop {
+	return pool.drops.get(hash)
+}
+
 // Get returns a transaction if it is contained in the pool and nil otherwise.
 func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all.Get(hash)
@@ -1367,6 +1446,10 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.currentState = statedb
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
+	if pool.txFilter != nil {
+		// Transactions exceed the gas budget of any block would never be packed
+		pool.currentMaxGas = pool.txFilter.MaxGasBudget(new(big.Int).Add(newHead.Number, common.Big1), newHead.GasLimit)
+	}
 
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
@@ -1381,6 +1464,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 		pool.disableTxFilter = false
 	}
 
+	pool.pendingNumber = next.Uint64()
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
 	pool.eip1559 = pool.chainconfig.IsLondon(next)
@@ -1404,6 +1488,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		for _, tx := range forwards {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.drops.add(hash, TxDropNonceTooLow)
 		}
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
@@ -1411,6 +1496,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		for _, tx := range drops {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.drops.add(hash, TxDropNoFunds)
 		}
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
@@ -1433,6 +1519,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			for _, tx := range caps {
 				hash := tx.Hash()
 				pool.all.Remove(hash)
+				pool.drops.add(hash, TxDropEvicted)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
 			queuedRateLimitMeter.Mark(int64(len(caps)))
@@ -1495,6 +1582,7 @@ func (pool *TxPool) truncatePending() {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
 						pool.all.Remove(hash)
+						pool.drops.add(hash, TxDropEvicted)
 
 						// Update the account nonce to the dropped transaction
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
@@ -1522,6 +1610,7 @@ func (pool *TxPool) truncatePending() {
 					// Drop the transaction from the global pools too
 					hash := tx.Hash()
 					pool.all.Remove(hash)
+					pool.drops.add(hash, TxDropEvicted)
 
 					// Update the account nonce to the dropped transaction
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
@@ -1569,6 +1658,7 @@ func (pool *TxPool) truncateQueue() {
 		if size := uint64(list.Len()); size <= drop {
 			for _, tx := range list.Flatten() {
 				pool.removeTx(tx.Hash(), true)
+				pool.drops.add(tx.Hash(), TxDropEvicted)
 			}
 			drop -= size
 			queuedRateLimitMeter.Mark(int64(size))
@@ -1578,6 +1668,7 @@ func (pool *TxPool) truncateQueue() {
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 			pool.removeTx(txs[i].Hash(), true)
+			pool.drops.add(txs[i].Hash(), TxDropEvicted)
 			drop--
 			queuedRateLimitMeter.Mark(1)
 		}
@@ -1601,6 +1692,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range olds {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
+			pool.drops.add(hash, TxDropNonceTooLow)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
@@ -1609,6 +1701,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
+			pool.drops.add(hash, TxDropNoFunds)
 		}
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
@@ -1743,19 +1836,22 @@ func (as *accountSet) merge(other *accountSet) {
 // TxPool.mu mutex.
 //
 // This lookup set combines the notion of "local transactions", which is useful
-// to build upper-level structure.
+// to build upper-level structure. The remote transactions in the priority lane
+// are tracked apart, as they are kept off the price heaps.
 type txLookup struct {
-	slots   int
-	lock    sync.RWMutex
-	locals  map[common.Hash]*types.Transaction
-	remotes map[common.Hash]*types.Transaction
+	slots      int
+	lock       sync.RWMutex
+	locals     map[common.Hash]*types.Transaction
+	remotes    map[common.Hash]*types.Transaction
+	priorities map[common.Hash]*types.Transaction
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		locals:  make(map[common.Hash]*types.Transaction),
-		remotes: make(map[common.Hash]*types.Transaction),
+		locals:     make(map[common.Hash]*types.Transaction),
+		remotes:    make(map[common.Hash]*types.Transaction),
+		priorities: make(map[common.Hash]*types.Transaction),
 	}
 }
 
@@ -1790,6 +1886,9 @@ func (t *txLookup) Get(hash common.Hash) *types.Transaction {
 	if tx := t.locals[hash]; tx != nil {
 		return tx
 	}
+	if tx := t.priorities[hash]; tx != nil {
+		return tx
+	}
 	return t.remotes[hash]
 }
 
@@ -1814,7 +1913,7 @@ func (t *txLookup) Count() int {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
 
-	return len(t.locals) + len(t.remotes)
+	return len(t.locals) + len(t.remotes) + len(t.priorities)
 }
 
 // LocalCount returns the current number of local transactions in the lookup.
@@ -1833,6 +1932,14 @@ func (t *txLookup) RemoteCount() int {
 	return len(t.remotes)
 }
 
+// PriorityCount returns the current number of priority lane transactions in the lookup.
+func (t *txLookup) PriorityCount() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return len(t.priorities)
+}
+
 // Slots returns the current number of slots used in the lookup.
 func (t *txLookup) Slots() int {
 	t.lock.RLock()
@@ -1856,6 +1963,17 @@ func (t *txLookup) Add(tx *types.Transaction, local bool) {
 	}
 }
 
+// AddPriority adds a remote transaction in the priority lane to the lookup.
+func (t *txLookup) AddPriority(tx *types.Transaction) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.slots += numSlots(tx)
+	slotsGauge.Update(int64(t.slots))
+
+	t.priorities[tx.Hash()] = tx
+}
+
 // Remove removes a transaction from the lookup.
 func (t *txLookup) Remove(hash common.Hash) {
 	t.lock.Lock()
@@ -1865,6 +1983,9 @@ func (t *txLookup) Remove(hash common.Hash) {
 	if !ok {
 		tx, ok = t.remotes[hash]
 	}
+	if !ok {
+		tx, ok = t.priorities[hash]
+	}
 	if !ok {
 		log.Error("No transaction found to be deleted", "hash", hash)
 		return
@@ -1874,6 +1995,7 @@ func (t *txLookup) Remove(hash common.Hash) {
 
 	delete(t.locals, hash)
 	delete(t.remotes, hash)
+	delete(t.priorities, hash)
 }
 
 // RemoteToLocals migrates the transactions belongs to the given locals to locals
@@ -1890,10 +2012,18 @@ func (t *txLookup) RemoteToLocals(locals *accountSet) int {
 			migrated += 1
 		}
 	}
+	// The priority lane transactions are off the price heaps, not counted
+	for hash, tx := range t.priorities {
+		if locals.containsTx(tx) {
+			t.locals[hash] = tx
+			delete(t.priorities, hash)
+		}
+	}
 	return migrated
 }
 
-// RemotesBelowTip finds all remote transactions below the given tip threshold.
+// RemotesBelowTip finds all remote transactions below the given tip threshold,
+// including the ones in the priority lane.
 func (t *txLookup) RemotesBelowTip(threshold *big.Int) types.Transactions {
 	found := make(types.Transactions, 0, 128)
 	t.Range(func(hash common.Hash, tx *types.Transaction, local bool) bool {
@@ -1902,6 +2032,15 @@ func (t *txLookup) RemotesBelowTip(threshold *big.Int) types.Transactions {
 		}
 		return true
 	}, false, true) // Only iterate remotes
+
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	for _, tx := range t.priorities {
+		if tx.GasTipCapIntCmp(threshold) < 0 {
+			found = append(found, tx)
+		}
+	}
 	return found
 }
 
@@ -29,12 +29,14 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 )
 
@@ -1745,6 +1747,204 @@ func TestTransactionPoolUnderpricing(t *testing.T) {
 	}
 }
 
+// testTxFilter is a consensus transaction filter marking the transactions sent
+// by the validator as priority ones, and halving the gas budget.
+type testTxFilter struct {
+	validator common.Address
+}
+
+func (f *testTxFilter) FilterTx(sender common.Address, tx *types.Transaction, header *types.Header, parentState *state.StateDB) error {
+	return nil
+}
+
+func (f *testTxFilter) CanCreate(state consensus.StateReader, addr common.Address, isContract bool, height *big.Int) bool {
+	return true
+}
+
+func (f *testTxFilter) MaxGasBudget(number *big.Int, gasLimit uint64) uint64 {
+	return gasLimit / 2
+}
+
+func (f *testTxFilter) IsPriorityTx(sender common.Address, tx *types.Transaction, header *types.Header) bool {
+	return sender == f.validator
+}
+
+// Tests that the priority lane transactions are protected from the pricing
+// eviction, and the pool caps the transactions with the max gas budget.
+func TestTransactionPoolPriorityLane(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	pool.InitTxFilter(&testTxFilter{validator: crypto.PubkeyToAddress(keys[3].PublicKey)})
+
+	// Transactions exceeding the max gas budget are rejected
+	if err := pool.addRemoteSync(pricedTransaction(0, 600000, big.NewInt(2), keys[0])); err != ErrGasLimit {
+		t.Fatalf("adding transaction over gas budget error mismatch: have %v, want %v", err, ErrGasLimit)
+	}
+	// Fill up the pool with well priced transactions
+	txs := types.Transactions{
+		pricedTransaction(0, 100000, big.NewInt(2), keys[0]),
+		pricedTransaction(1, 100000, big.NewInt(2), keys[0]),
+		pricedTransaction(0, 100000, big.NewInt(2), keys[1]),
+		pricedTransaction(1, 100000, big.NewInt(2), keys[1]),
+	}
+	for i, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	// Underpriced transactions are rejected, unless they're in the priority lane
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(1), keys[2])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(1), keys[3])); err != nil {
+		t.Fatalf("failed to add underpriced priority transaction: %v", err)
+	}
+	pending, queued := pool.Stats()
+	if pending+queued != 4 {
+		t.Fatalf("pooled transactions mismatched: have %d, want %d", pending+queued, 4)
+	}
+	if list := pool.pending[crypto.PubkeyToAddress(keys[3].PublicKey)]; list == nil || list.Len() != 1 {
+		t.Fatalf("priority transaction not pending")
+	}
+	// The priority is tracked per transaction, the sender isn't made local
+	if pool.locals.contains(crypto.PubkeyToAddress(keys[3].PublicKey)) {
+		t.Fatalf("priority sender tracked as local")
+	}
+	if pool.all.LocalCount() != 0 {
+		t.Fatalf("local transactions mismatched: have %d, want %d", pool.all.LocalCount(), 0)
+	}
+	if pool.all.PriorityCount() != 1 {
+		t.Fatalf("priority transactions mismatched: have %d, want %d", pool.all.PriorityCount(), 1)
+	}
+	// Later transactions of the sender outside the priority lane are ordinary remotes
+	// paying the minimum gas price
+	pool.InitTxFilter(&testTxFilter{})
+	if err := pool.addRemoteSync(pricedTransaction(1, 100000, big.NewInt(0), keys[3])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced transaction of priority sender error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// denyTxFilter is a consensus transaction filter denying the transactions sent
+// by a single address.
+type denyTxFilter struct {
+	testTxFilter
+	denied common.Address
+}
+
+func (f *denyTxFilter) FilterTx(sender common.Address, tx *types.Transaction, header *types.Header, parentState *state.StateDB) error {
+	if sender == f.denied {
+		return types.ErrAddressDenied
+	}
+	return nil
+}
+
+// Tests that the pool remembers why it dropped the transactions.
+func TestTransactionPoolDropReasons(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	keys := make([]*ecdsa.PrivateKey, 4)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	filter := new(denyTxFilter)
+	pool.InitTxFilter(filter)
+
+	txs := types.Transactions{
+		pricedTransaction(0, 100000, big.NewInt(1), keys[0]),
+		pricedTransaction(0, 100000, big.NewInt(1), keys[1]),
+		pricedTransaction(0, 100000, big.NewInt(1), keys[2]),
+		pricedTransaction(0, 100000, big.NewInt(1), keys[3]),
+	}
+	for i, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	// Replace the first transaction with a better paying one
+	replacement := pricedTransaction(0, 100000, big.NewInt(2), keys[0])
+	if err := pool.addRemoteSync(replacement); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	// Drain the third sender and bump the nonce of the fourth
+	testAddBalance(pool, crypto.PubkeyToAddress(keys[2].PublicKey), big.NewInt(-1000000))
+	pool.mu.Lock()
+	pool.currentState.SetNonce(crypto.PubkeyToAddress(keys[3].PublicKey), 1)
+	pool.mu.Unlock()
+	<-pool.requestReset(nil, nil)
+
+	// Deny the second sender and send a meta transaction expired by the next block
+	filter.denied = crypto.PubkeyToAddress(keys[1].PublicKey)
+	denied := pricedTransaction(1, 100000, big.NewInt(1), keys[1])
+	if err := pool.addRemoteSync(denied); err != types.ErrAddressDenied {
+		t.Fatalf("denied transaction error mismatch: have %v, want %v", err, types.ErrAddressDenied)
+	}
+	meta, _ := rlp.EncodeToBytes(&types.MetaData{V: common.Big1, R: common.Big1, S: common.Big1})
+	expired, _ := types.SignTx(types.NewTransaction(1, common.Address{}, big.NewInt(100), 100000, big.NewInt(1), append(common.FromHex(types.MetaPrefix), meta...)), types.HomesteadSigner{}, keys[0])
+	if err := pool.addRemoteSync(expired); err != ErrMetaTxExpired {
+		t.Fatalf("expired transaction error mismatch: have %v, want %v", err, ErrMetaTxExpired)
+	}
+
+	tests := []struct {
+		hash   common.Hash
+		reason TxDropReason
+	}{
+		{txs[0].Hash(), TxDropReplaced},
+		{denied.Hash(), TxDropAccessDenied},
+		{expired.Hash(), TxDropExpired},
+		{txs[2].Hash(), TxDropNoFunds},
+		{txs[3].Hash(), TxDropNonceTooLow},
+	}
+	for i, tt := range tests {
+		if pool.Get(tt.hash) != nil {
+			t.Errorf("test %d: dropped transaction still pooled", i)
+		}
+		drop := pool.Dropped(tt.hash)
+		if drop == nil {
+			t.Errorf("test %d: dropped transaction not recorded", i)
+			continue
+		}
+		if drop.Reason != tt.reason {
+			t.Errorf("test %d: drop reason mismatch: have %s, want %s", i, drop.Reason, tt.reason)
+		}
+	}
+	if drop := pool.Dropped(txs[0].Hash()); drop != nil && drop.ReplacedBy != replacement.Hash() {
+		t.Errorf("replacing transaction mismatch: have %x, want %x", drop.ReplacedBy, replacement.Hash())
+	}
+	if pool.Dropped(replacement.Hash()) != nil {
+		t.Errorf("pooled transaction recorded as dropped")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that more expensive transactions push out cheap ones from the pool, but
 // without producing instability by creating gaps that start jumping transactions
 // back and forth between queued/pending.
@@ -169,6 +169,22 @@ func (evm *EVM) Cancelled() bool {
 	return atomic.LoadInt32(&evm.abort) == 1
 }
 
+// CaptureTxStart notifies the tracer of the EVM, if it's an EVMTxLogger, of the
+// start of a transaction changing the given accounts outside of the EVM.
+func (evm *EVM) CaptureTxStart(accounts ...common.Address) {
+	if tracer, ok := evm.Config.Tracer.(EVMTxLogger); ok && evm.Config.Debug {
+		tracer.CaptureTxStart(evm, accounts)
+	}
+}
+
+// CaptureTxEnd notifies the tracer of the EVM, if it's an EVMTxLogger, of the end
+// of a transaction.
+func (evm *EVM) CaptureTxEnd() {
+	if tracer, ok := evm.Config.Tracer.(EVMTxLogger); ok && evm.Config.Debug {
+		tracer.CaptureTxEnd(evm)
+	}
+}
+
 // Interpreter returns the current interpreter
 func (evm *EVM) Interpreter() *EVMInterpreter {
 	return evm.interpreter
@@ -112,6 +112,20 @@ type EVMLogger interface {
 	CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error)
 }
 
+// EVMTxLogger is an EVMLogger also notified of the transaction boundaries, to
+// capture the state changes a transaction makes outside of the EVM execution:
+// the gas purchase and refund, the fee payment or the nonce of the system
+// transactions.
+type EVMTxLogger interface {
+	EVMLogger
+	// CaptureTxStart is called before the transaction changes any state, with
+	// the accounts it changes outside of the EVM execution or before the EVM
+	// tracing starts.
+	CaptureTxStart(env *EVM, accounts []common.Address)
+	// CaptureTxEnd is called once the transaction made all its state changes.
+	CaptureTxEnd(env *EVM)
+}
+
 // StructLogger is an EVM state logger and implements EVMLogger.
 //
 // StructLogger can capture state based on the given Log configuration and also keeps
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"runtime"
@@ -206,6 +207,26 @@ func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64
 	return true, nil
 }
 
+// ExportCheckpoint exports the proof of a recent finalized epoch block into a
+// file, for fresh nodes to sync from it with --checkpoint.finalized.
+func (api *PrivateAdminAPI) ExportCheckpoint(file string, number uint64) (bool, error) {
+	if _, err := os.Stat(file); err == nil {
+		return false, errors.New("location would overwrite an existing file")
+	}
+	proof, err := exportCheckpoint(api.eth.BlockChain(), number)
+	if err != nil {
+		return false, err
+	}
+	blob, err := rlp.EncodeToBytes(proof)
+	if err != nil {
+		return false, err
+	}
+	if err := ioutil.WriteFile(file, blob, 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -74,6 +74,7 @@ type Ethereum struct {
 	ethDialCandidates  enode.Iterator
 	snapDialCandidates enode.Iterator
 	consDialCandidates enode.Iterator
+	meshDialCandidates enode.Iterator
 
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
@@ -87,6 +88,7 @@ type Ethereum struct {
 
 	bloomRequests     chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
+	logIndexer        *core.ChainIndexer             // Log indexer operating during block imports, if enabled
 	closeBloomHandler chan struct{}
 
 	APIBackend *EthAPIBackend
@@ -99,6 +101,7 @@ type Ethereum struct {
 	netRPCService *ethapi.PublicNetAPI
 
 	p2pServer *p2p.Server
+	mesh      *validatorMesh // Direct connections among the validators, nil if not running Chaos
 
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 }
@@ -195,6 +198,11 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			Preimages:           config.Preimages,
 		}
 	)
+	if config.FinalizedCheckpoint != (common.Hash{}) {
+		if err := applyCheckpoint(chainDb, eth.engine, config.FinalizedCheckpoint, config.FinalizedCheckpointProof); err != nil {
+			return nil, fmt.Errorf("failed to apply sync checkpoint: %v", err)
+		}
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
 	if err != nil {
 		return nil, err
@@ -202,10 +210,16 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
-		eth.blockchain.SetHead(compat.RewindTo)
+		if err := eth.blockchain.SetHead(compat.RewindTo); err != nil {
+			return nil, fmt.Errorf("failed to rewind chain for configuration upgrade: %v", err)
+		}
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if config.LogIndex {
+		eth.logIndexer = core.NewLogIndexer(chainDb, params.LogIndexBlocks, params.BloomConfirms)
+		eth.logIndexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
@@ -238,6 +252,9 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if checkpoint == nil {
 		checkpoint = params.TrustedCheckpoints[genesisHash]
 	}
+	if eth.isChaosEngine {
+		eth.mesh = newValidatorMesh(eth.blockchain, eth.p2pServer)
+	}
 	if eth.handler, err = newHandler(&handlerConfig{
 		Database:   chainDb,
 		Chain:      eth.blockchain,
@@ -248,6 +265,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		EventMux:   eth.eventMux,
 		Checkpoint: checkpoint,
 		Whitelist:  config.Whitelist,
+
+		PrivateTxFallback: config.PrivateTxFallback,
+		PrivateTxRelays:   config.PrivateTxRelays,
+		ValidatorMesh:     eth.mesh,
 	}); err != nil {
 		return nil, err
 	}
@@ -279,6 +300,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	eth.meshDialCandidates, err = dnsClient.NewIterator(eth.config.ConsDiscoveryURLs...)
+	if err != nil {
+		return nil, err
+	}
 
 	// Start the RPC service
 	eth.netRPCService = ethapi.NewPublicNetAPI(eth.p2pServer, config.NetworkId)
@@ -287,6 +312,7 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	stack.RegisterAPIs(eth.APIs())
 	stack.RegisterProtocols(eth.Protocols())
 	stack.RegisterLifecycle(eth)
+	eth.registerHealthChecks(stack)
 
 	// gas price prediction
 	gppCfg := checkPricePredictionConfig(&gpoParams)
@@ -619,6 +645,7 @@ func (s *Ethereum) Downloader() *downloader.Downloader { return s.handler.downlo
 func (s *Ethereum) Synced() bool                       { return atomic.LoadUint32(&s.handler.acceptTxs) == 1 }
 func (s *Ethereum) ArchiveMode() bool                  { return s.config.NoPruning }
 func (s *Ethereum) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
+func (s *Ethereum) LogIndexer() *core.ChainIndexer     { return s.logIndexer }
 
 // Protocols returns all the currently configured
 // network protocols to start.
@@ -649,9 +676,28 @@ func (s *Ethereum) Start() error {
 	}
 	// Start the networking layer and the light server if requested
 	s.handler.Start(maxPeers)
+
+	// Start keeping the validators connected to each other
+	if s.mesh != nil {
+		s.mesh.start(s.meshCandidates())
+	}
 	return nil
 }
 
+// meshCandidates creates the iterator of the nodes possibly carrying the
+// validator records, from the DNS lists and the discovery.
+func (s *Ethereum) meshCandidates() enode.Iterator {
+	mix := enode.NewFairMix(0)
+	mix.AddSource(s.meshDialCandidates)
+	if v4 := s.p2pServer.DiscoveryV4(); v4 != nil {
+		mix.AddSource(&resolveIterator{Iterator: v4.RandomNodes(), v4: v4})
+	}
+	if s.p2pServer.DiscV5 != nil {
+		mix.AddSource(s.p2pServer.DiscV5.RandomNodes())
+	}
+	return mix
+}
+
 // Stop implements node.Lifecycle, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
@@ -659,10 +705,17 @@ func (s *Ethereum) Stop() error {
 	s.ethDialCandidates.Close()
 	s.snapDialCandidates.Close()
 	s.consDialCandidates.Close()
+	s.meshDialCandidates.Close()
+	if s.mesh != nil {
+		s.mesh.stop()
+	}
 	s.handler.Stop()
 
 	// Then stop everything else.
 	s.bloomIndexer.Close()
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
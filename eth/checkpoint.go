@@ -0,0 +1,494 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/chaos"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// backfillRequestTimeout is the maximum time to wait for a batch of backfilled
+	// headers before asking another peer.
+	backfillRequestTimeout = 10 * time.Second
+
+	// backfillRetryInterval is the time to wait before retrying when there are no
+	// peers to backfill from.
+	backfillRetryInterval = 5 * time.Second
+)
+
+// applyCheckpoint seeds a fresh database with a verified finalized checkpoint,
+// so the node snap syncs from it instead of the genesis. The checkpoint block
+// becomes the head fast block, and the headers of its epoch are written to seed
+// the Chaos snapshot.
+func applyCheckpoint(db ethdb.Database, engine consensus.Engine, hash common.Hash, path string) error {
+	if rawdb.ReadSyncCheckpoint(db) != nil {
+		return nil
+	}
+	chaosEngine, ok := engine.(*chaos.Chaos)
+	if !ok {
+		return errors.New("checkpoint sync requires the Chaos engine")
+	}
+	if number := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db)); number == nil || *number != 0 {
+		log.Warn("Database not empty, ignoring sync checkpoint", "hash", hash)
+		return nil
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint proof: %v", err)
+	}
+	proof := new(chaos.CheckpointProof)
+	if err := rlp.DecodeBytes(blob, proof); err != nil {
+		return fmt.Errorf("failed to decode checkpoint proof: %v", err)
+	}
+	if err := chaosEngine.VerifyCheckpoint(hash, proof); err != nil {
+		return err
+	}
+	var (
+		checkpoint = proof.Checkpoint()
+		number     = checkpoint.Number.Uint64()
+		td         = new(big.Int).Set(proof.TD)
+		batch      = db.NewBatch()
+	)
+	for i := len(proof.Headers) - 1; i >= 0; i-- {
+		header := proof.Headers[i]
+		rawdb.WriteHeader(batch, header)
+		rawdb.WriteTd(batch, header.Hash(), header.Number.Uint64(), td)
+		rawdb.WriteCanonicalHash(batch, header.Hash(), header.Number.Uint64())
+		td = new(big.Int).Sub(td, header.Difficulty)
+	}
+	rawdb.WriteBody(batch, hash, number, proof.Body)
+	rawdb.WriteReceipts(batch, hash, number, proof.Receipts)
+	rawdb.WriteHeadHeaderHash(batch, hash)
+	rawdb.WriteHeadFastBlockHash(batch, hash)
+	rawdb.WriteSyncCheckpoint(batch, &rawdb.SyncCheckpoint{
+		Number: number,
+		Hash:   hash,
+		Tail:   proof.Headers[0].Number.Uint64(),
+	})
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	rawdb.WriteBlockStatus(db, checkpoint.Number, hash, types.BasFinalized)
+	rawdb.WriteLastBlockStatusNumber(db, checkpoint.Number)
+	rawdb.WriteLastFinalizedBlockNumber(db, checkpoint.Number)
+
+	if err := chaosEngine.SeedCheckpoint(proof); err != nil {
+		return err
+	}
+	log.Info("Starting from sync checkpoint", "number", number, "hash", hash, "td", proof.TD, "attestations", len(proof.Attestations))
+	return nil
+}
+
+// exportCheckpoint assembles the proof of a finalized epoch block. The attestations
+// are only kept for a while, so the checkpoint has to be a recent one.
+func exportCheckpoint(chain *core.BlockChain, number uint64) (*chaos.CheckpointProof, error) {
+	config := chain.Config().Chaos
+	if config == nil {
+		return nil, errors.New("checkpoint requires the Chaos engine")
+	}
+	if number == 0 || number%config.Epoch != 0 {
+		return nil, fmt.Errorf("block %d is not an epoch block", number)
+	}
+	block := chain.GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block %d not found", number)
+	}
+	if status := chain.GetBlockStatus(number, block.Hash()); status != types.BasFinalized {
+		return nil, fmt.Errorf("block %d not finalized", number)
+	}
+	attestations, err := chain.GetHistoryAttestations(block.Number(), block.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("attestations of block %d not available: %v", number, err)
+	}
+	headers := make([]*types.Header, 0, config.Epoch+1)
+	for n := number - config.Epoch; n <= number; n++ {
+		header := chain.GetHeaderByNumber(n)
+		if header == nil {
+			return nil, fmt.Errorf("header %d not found", n)
+		}
+		headers = append(headers, header)
+	}
+	return &chaos.CheckpointProof{
+		Headers:      headers,
+		Body:         block.Body(),
+		Receipts:     chain.GetReceiptsByHash(block.Hash()),
+		TD:           chain.GetTd(block.Hash(), number),
+		Attestations: attestations,
+	}, nil
+}
+
+// backfiller retrieves the chain below the sync checkpoint in the background.
+// The headers are retrieved first, down to the genesis. They are trusted by their
+// hashes chaining to the checkpoint, so no consensus verification is needed. The
+// bodies and the receipts are retrieved next, up from the genesis, verified by
+// the roots of the headers. The checkpoint marker is deleted once the chain is
+// complete, so the freezer can move the blocks to the ancient store.
+type backfiller struct {
+	db      ethdb.Database
+	peers   *peerSet
+	genesis common.Hash
+
+	pending *backfillRequest // Currently pending request, if any
+	lock    sync.Mutex
+
+	deliverCh chan *backfillDelivery
+	quit      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// backfillKind is the kind of data a backfill request retrieves.
+type backfillKind int
+
+const (
+	backfillHeaders backfillKind = iota
+	backfillBodies
+	backfillReceipts
+)
+
+// backfillRequest tracks a pending request.
+type backfillRequest struct {
+	peer    string          // Peer to which this request is assigned
+	kind    backfillKind    // Kind of the data requested
+	origin  common.Hash     // Hash of the first header requested
+	headers []*types.Header // Headers of the blocks whose bodies or receipts are requested
+}
+
+// backfillDelivery is a response to a backfill request.
+type backfillDelivery struct {
+	peer     string
+	kind     backfillKind
+	headers  []*types.Header
+	txs      [][]*types.Transaction
+	uncles   [][]*types.Header
+	receipts [][]*types.Receipt
+}
+
+func newBackfiller(db ethdb.Database, peers *peerSet, genesis common.Hash) *backfiller {
+	return &backfiller{
+		db:        db,
+		peers:     peers,
+		genesis:   genesis,
+		deliverCh: make(chan *backfillDelivery),
+		quit:      make(chan struct{}),
+	}
+}
+
+// start begins backfilling if the chain was synced from a checkpoint.
+func (b *backfiller) start() {
+	checkpoint := rawdb.ReadSyncCheckpoint(b.db)
+	if checkpoint == nil {
+		return
+	}
+	b.wg.Add(1)
+	go b.loop(checkpoint)
+}
+
+func (b *backfiller) stop() {
+	close(b.quit)
+	b.wg.Wait()
+}
+
+// deliverHeaders injects the headers received from a peer, it reports whether
+// they were requested by the backfiller.
+func (b *backfiller) deliverHeaders(peer string, headers []*types.Header) bool {
+	return b.deliver(&backfillDelivery{peer: peer, kind: backfillHeaders, headers: headers}, func(req *backfillRequest) bool {
+		return len(headers) > 0 && headers[0].Hash() == req.origin
+	})
+}
+
+// deliverBodies injects the bodies received from a peer, it reports whether they
+// were requested by the backfiller.
+func (b *backfiller) deliverBodies(peer string, txs [][]*types.Transaction, uncles [][]*types.Header) bool {
+	return b.deliver(&backfillDelivery{peer: peer, kind: backfillBodies, txs: txs, uncles: uncles}, func(req *backfillRequest) bool {
+		return len(txs) > 0 && len(txs) == len(uncles) && len(txs) <= len(req.headers) && verifyBody(req.headers[0], txs[0], uncles[0])
+	})
+}
+
+// deliverReceipts injects the receipts received from a peer, it reports whether
+// they were requested by the backfiller.
+func (b *backfiller) deliverReceipts(peer string, receipts [][]*types.Receipt) bool {
+	return b.deliver(&backfillDelivery{peer: peer, kind: backfillReceipts, receipts: receipts}, func(req *backfillRequest) bool {
+		return len(receipts) > 0 && len(receipts) <= len(req.headers) && verifyReceipts(req.headers[0], receipts[0])
+	})
+}
+
+// deliver hands the delivery to the loop if it answers the pending request, the
+// first item is checked so the responses to the other requests of the same peer
+// are left to the downloader and the fetchers.
+func (b *backfiller) deliver(delivery *backfillDelivery, match func(req *backfillRequest) bool) bool {
+	b.lock.Lock()
+	req := b.pending
+	b.lock.Unlock()
+
+	if req == nil || req.peer != delivery.peer || req.kind != delivery.kind || !match(req) {
+		return false
+	}
+	select {
+	case b.deliverCh <- delivery:
+	case <-b.quit:
+	}
+	return true
+}
+
+func (b *backfiller) loop(checkpoint *rawdb.SyncCheckpoint) {
+	defer b.wg.Done()
+
+	var (
+		tail   = rawdb.ReadHeader(b.db, rawdb.ReadCanonicalHash(b.db, checkpoint.Tail), checkpoint.Tail)
+		bodies = checkpoint.Filled // Highest block whose body is written, the receipts may be missing
+		failed = make(map[string]struct{})
+		timer  = time.NewTimer(0)
+	)
+	defer timer.Stop()
+
+	if tail == nil {
+		log.Error("Backfill tail header missing", "number", checkpoint.Tail)
+		return
+	}
+	if checkpoint.Tail > 1 {
+		log.Info("Backfilling headers below sync checkpoint", "checkpoint", checkpoint.Number, "tail", checkpoint.Tail)
+	} else {
+		log.Info("Backfilling blocks below sync checkpoint", "checkpoint", checkpoint.Number, "filled", checkpoint.Filled)
+	}
+	for {
+		if checkpoint.Tail <= 1 && checkpoint.Filled+1 >= checkpoint.Number {
+			rawdb.DeleteSyncCheckpoint(b.db)
+			log.Info("Backfilled chain below sync checkpoint", "checkpoint", checkpoint.Number)
+			return
+		}
+		select {
+		case <-timer.C:
+			// Either nothing was requested yet, or the request timed out
+			b.lock.Lock()
+			if b.pending != nil {
+				failed[b.pending.peer] = struct{}{}
+				b.pending = nil
+			}
+			b.lock.Unlock()
+
+			peer := b.peers.randomPeer(failed)
+			if peer == nil {
+				failed = make(map[string]struct{})
+				timer.Reset(backfillRetryInterval)
+				continue
+			}
+			req := &backfillRequest{peer: peer.ID()}
+			switch {
+			case checkpoint.Tail > 1:
+				req.kind, req.origin = backfillHeaders, tail.ParentHash
+			case bodies == checkpoint.Filled:
+				req.kind, req.headers = backfillBodies, b.headers(bodies+1, checkpoint.Number-1, downloader.MaxBlockFetch)
+			default:
+				req.kind, req.headers = backfillReceipts, b.headers(checkpoint.Filled+1, bodies, downloader.MaxReceiptFetch)
+			}
+			if req.kind != backfillHeaders && len(req.headers) == 0 {
+				log.Error("Backfill headers missing", "number", checkpoint.Filled+1)
+				return
+			}
+			b.lock.Lock()
+			b.pending = req
+			b.lock.Unlock()
+
+			var err error
+			switch req.kind {
+			case backfillHeaders:
+				err = peer.RequestHeadersByHash(req.origin, downloader.MaxHeaderFetch, 0, true)
+			case backfillBodies:
+				err = peer.RequestBodies(headerHashes(req.headers))
+			case backfillReceipts:
+				err = peer.RequestReceipts(headerHashes(req.headers))
+			}
+			if err != nil {
+				peer.Log().Debug("Failed to request backfill data", "err", err)
+			}
+			timer.Reset(backfillRequestTimeout)
+
+		case delivery := <-b.deliverCh:
+			b.lock.Lock()
+			req := b.pending
+			b.pending = nil
+			b.lock.Unlock()
+
+			// The request may have timed out meanwhile
+			if req == nil || req.peer != delivery.peer || req.kind != delivery.kind {
+				continue
+			}
+			var (
+				written int
+				err     error
+			)
+			switch delivery.kind {
+			case backfillHeaders:
+				tail, err = b.write(tail, delivery.headers)
+				checkpoint.Tail = tail.Number.Uint64()
+
+			case backfillBodies:
+				written, err = b.writeBodies(req.headers, delivery.txs, delivery.uncles)
+				bodies += uint64(written)
+
+			case backfillReceipts:
+				written, err = b.writeReceipts(req.headers, delivery.receipts)
+				checkpoint.Filled += uint64(written)
+			}
+			if err != nil {
+				log.Debug("Invalid backfill data", "peer", delivery.peer, "err", err)
+				failed[delivery.peer] = struct{}{}
+			}
+			rawdb.WriteSyncCheckpoint(b.db, checkpoint)
+
+			if delivery.kind == backfillHeaders && checkpoint.Tail <= 1 {
+				log.Info("Backfilled headers below sync checkpoint", "checkpoint", checkpoint.Number)
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(0)
+
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// headers returns the canonical headers from the first block to the last one,
+// at most the given count.
+func (b *backfiller) headers(first, last uint64, count int) []*types.Header {
+	var headers []*types.Header
+	for n := first; n <= last && len(headers) < count; n++ {
+		header := rawdb.ReadHeader(b.db, rawdb.ReadCanonicalHash(b.db, n), n)
+		if header == nil {
+			break
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// write stores the headers chaining down from the tail, and returns the new tail.
+// Headers are written until the first one not chaining, which is reported.
+func (b *backfiller) write(tail *types.Header, headers []*types.Header) (*types.Header, error) {
+	var (
+		batch = b.db.NewBatch()
+		td    = rawdb.ReadTd(b.db, tail.Hash(), tail.Number.Uint64())
+		err   error
+	)
+	if td == nil {
+		return tail, errors.New("tail total difficulty missing")
+	}
+	for _, header := range headers {
+		if header.Hash() != tail.ParentHash || header.Number.Uint64()+1 != tail.Number.Uint64() {
+			err = fmt.Errorf("header %d not chaining to %d", header.Number, tail.Number)
+			break
+		}
+		if header.Number.Uint64() == 1 && header.ParentHash != b.genesis {
+			err = errors.New("backfilled headers not chaining to the genesis")
+			break
+		}
+		td = new(big.Int).Sub(td, tail.Difficulty)
+		rawdb.WriteHeader(batch, header)
+		rawdb.WriteTd(batch, header.Hash(), header.Number.Uint64(), td)
+		rawdb.WriteCanonicalHash(batch, header.Hash(), header.Number.Uint64())
+		tail = header
+
+		if tail.Number.Uint64() == 1 {
+			break
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write backfilled headers", "err", err)
+	}
+	return tail, err
+}
+
+// writeBodies stores the bodies of the blocks, and returns the number written.
+// Bodies are written until the first one not matching its header, which is reported.
+func (b *backfiller) writeBodies(headers []*types.Header, txs [][]*types.Transaction, uncles [][]*types.Header) (int, error) {
+	var (
+		batch = b.db.NewBatch()
+		err   error
+		i     int
+	)
+	for ; i < len(txs) && i < len(headers); i++ {
+		if !verifyBody(headers[i], txs[i], uncles[i]) {
+			err = fmt.Errorf("body %d not matching the header", headers[i].Number)
+			break
+		}
+		rawdb.WriteBody(batch, headers[i].Hash(), headers[i].Number.Uint64(), &types.Body{Transactions: txs[i], Uncles: uncles[i]})
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write backfilled bodies", "err", err)
+	}
+	return i, err
+}
+
+// writeReceipts stores the receipts of the blocks, and returns the number written.
+// Receipts are written until the first ones not matching their header, which are
+// reported.
+func (b *backfiller) writeReceipts(headers []*types.Header, receipts [][]*types.Receipt) (int, error) {
+	var (
+		batch = b.db.NewBatch()
+		err   error
+		i     int
+	)
+	for ; i < len(receipts) && i < len(headers); i++ {
+		if !verifyReceipts(headers[i], receipts[i]) {
+			err = fmt.Errorf("receipts %d not matching the header", headers[i].Number)
+			break
+		}
+		rawdb.WriteReceipts(batch, headers[i].Hash(), headers[i].Number.Uint64(), receipts[i])
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write backfilled receipts", "err", err)
+	}
+	return i, err
+}
+
+// verifyBody returns whether the body matches the roots of the header.
+func verifyBody(header *types.Header, txs []*types.Transaction, uncles []*types.Header) bool {
+	return types.DeriveSha(types.Transactions(txs), trie.NewStackTrie(nil)) == header.TxHash && types.CalcUncleHash(uncles) == header.UncleHash
+}
+
+// verifyReceipts returns whether the receipts match the root of the header.
+func verifyReceipts(header *types.Header, receipts []*types.Receipt) bool {
+	return types.DeriveSha(types.Receipts(receipts), trie.NewStackTrie(nil)) == header.ReceiptHash
+}
+
+// headerHashes returns the hashes of the headers.
+func headerHashes(headers []*types.Header) []common.Hash {
+	hashes := make([]common.Hash, len(headers))
+	for i, header := range headers {
+		hashes[i] = header.Hash()
+	}
+	return hashes
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the bodies and receipts backfilled below the sync checkpoint are
+// verified against their headers, and the checkpoint is deleted once the chain
+// below it is complete.
+func TestBackfillBlocks(t *testing.T) {
+	var (
+		source  = rawdb.NewMemoryDatabase()
+		gspec   = &core.Genesis{Alloc: core.GenesisAlloc{testAddr: {Balance: big.NewInt(params.Ether)}}}
+		genesis = gspec.MustCommit(source)
+		signer  = types.HomesteadSigner{}
+	)
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), source, 10, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(testAddr), common.Address{0x01}, big.NewInt(1), params.TxGas, gen.BaseFee(), nil), signer, testKey)
+		gen.AddTx(tx)
+	})
+	// Seed a database with the headers below the checkpoint only
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	for _, block := range blocks[:9] {
+		rawdb.WriteHeader(db, block.Header())
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	}
+	rawdb.WriteSyncCheckpoint(db, &rawdb.SyncCheckpoint{Number: 10, Hash: blocks[9].Hash(), Tail: 1})
+
+	b := newBackfiller(db, newPeerSet(), genesis.Hash())
+	headers := b.headers(1, 9, 128)
+	if len(headers) != 9 {
+		t.Fatalf("backfill headers mismatch: have %d, want %d", len(headers), 9)
+	}
+	// Bodies and receipts are written until the first one not matching its header
+	var (
+		txs    = make([][]*types.Transaction, len(headers))
+		uncles = make([][]*types.Header, len(headers))
+	)
+	for i, block := range blocks[:9] {
+		txs[i], uncles[i] = block.Transactions(), block.Uncles()
+	}
+	txs[5] = nil
+	if n, err := b.writeBodies(headers, txs, uncles); n != 5 || err == nil {
+		t.Fatalf("written bodies mismatch: have %d (%v), want %d with error", n, err, 5)
+	}
+	if rawdb.ReadBody(db, blocks[4].Hash(), 5) == nil || rawdb.HasBody(db, blocks[5].Hash(), 6) {
+		t.Fatalf("written bodies mismatch around the invalid one")
+	}
+	forged := append(types.Receipts{}, receipts[2]...)
+	forged[0] = &types.Receipt{Status: types.ReceiptStatusFailed, CumulativeGasUsed: params.TxGas}
+	if n, err := b.writeReceipts(headers, [][]*types.Receipt{receipts[0], receipts[1], forged}); n != 2 || err == nil {
+		t.Fatalf("written receipts mismatch: have %d (%v), want %d with error", n, err, 2)
+	}
+	if !rawdb.HasReceipts(db, blocks[1].Hash(), 2) || rawdb.HasReceipts(db, blocks[2].Hash(), 3) {
+		t.Fatalf("written receipts mismatch around the invalid ones")
+	}
+	// The checkpoint is kept until the chain below it is complete
+	rawdb.WriteSyncCheckpoint(db, &rawdb.SyncCheckpoint{Number: 10, Hash: blocks[9].Hash(), Tail: 1, Filled: 9})
+	b.start()
+	b.stop()
+	if checkpoint := rawdb.ReadSyncCheckpoint(db); checkpoint != nil {
+		t.Fatalf("sync checkpoint not deleted: %+v", checkpoint)
+	}
+}
@@ -27,9 +27,11 @@ import (
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/cons"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -130,6 +132,7 @@ type Downloader struct {
 
 	snapSync       bool         // Whether to run state sync over the snap protocol
 	SnapSyncer     *snap.Syncer // TODO(karalabe): make private! hack for now
+	ConsSyncer     *cons.Syncer // Finality data syncer, nil if the chain has no finality
 	stateSyncStart chan *stateSync
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // Channel receiving inbound node state data
@@ -530,6 +533,10 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 		if origin >= frozen && frozen != 0 {
 			d.ancientLimit = 0
 			log.Info("Disabling direct-ancient mode", "origin", origin, "ancient", frozen-1)
+		} else if rawdb.ReadSyncCheckpoint(d.stateDB) != nil {
+			// The ancient store can't be started above the genesis
+			d.ancientLimit = 0
+			log.Info("Disabling direct-ancient mode for checkpoint sync", "origin", origin)
 		} else if d.ancientLimit > 0 {
 			log.Debug("Enabling direct-ancient mode", "ancient", d.ancientLimit)
 		}
@@ -560,6 +567,20 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	} else if mode == FullSync {
 		fetchers = append(fetchers, d.processFullSyncContent)
 	}
+	if d.ConsSyncer != nil {
+		d.cancelLock.RLock()
+		cancel := d.cancelCh
+		d.cancelLock.RUnlock()
+
+		// Finality data is retrieved on a best effort basis, don't hold the sync up
+		d.cancelWg.Add(1)
+		go func() {
+			defer d.cancelWg.Done()
+			if err := d.ConsSyncer.Sync(origin+1, height, cancel); err != nil && err != cons.ErrCancelled {
+				log.Debug("Finality sync failed", "err", err)
+			}
+		}()
+	}
 	return d.spawnSync(fetchers)
 }
 
@@ -783,6 +804,15 @@ func (d *Downloader) findAncestor(p *peerConnection, remoteHeader *types.Header)
 		// We're above the max reorg threshold, find the earliest fork point
 		floor = int64(localHeight - maxForkAncestry)
 	}
+	// On chains with finality, nothing below the last finalized block (with a
+	// safety margin) can be reorged, don't look for an ancestor below that.
+	if mode != LightSync {
+		if immutable, ok := rawdb.ReadFinalizedImmutableNumber(d.stateDB, params.FinalityImmutabilityMargin); ok && immutable <= localHeight {
+			if int64(immutable)-1 > floor {
+				floor = int64(immutable) - 1
+			}
+		}
+	}
 	// If we're doing a light sync, ensure the floor doesn't go below the CHT, as
 	// all headers before that point will be missing.
 	if mode == LightSync {
@@ -1524,7 +1554,11 @@ func (d *Downloader) processHeaders(origin uint64, td *big.Int) error {
 				lastFastBlock = d.blockchain.CurrentFastBlock().Number()
 				lastBlock = d.blockchain.CurrentBlock().Number()
 			}
-			if err := d.lightchain.SetHead(rollback - 1); err != nil { // -1 to target the parent of the first uncertain block
+			if err := d.lightchain.SetHead(rollback - 1); errors.Is(err, core.ErrFinalizedRewind) { // -1 to target the parent of the first uncertain block
+				// The finalized blocks are never rolled back, the chain is left intact
+				log.Warn("Refused to roll back finalized chain segment", "head", rollback-1, "reason", rollbackErr, "err", err)
+				return
+			} else if err != nil {
 				// We're already unwinding the stack, only print the error to make it more visible
 				log.Error("Failed to roll back chain segment", "head", rollback-1, "err", err)
 			}
@@ -1986,6 +2020,21 @@ func (d *Downloader) DeliverSnapPacket(peer *snap.Peer, packet snap.Packet) erro
 	}
 }
 
+// DeliverConsPacket is invoked from a peer's message handler when it transmits a
+// finality data packet for the local node to consume.
+func (d *Downloader) DeliverConsPacket(peer *cons.Peer, packet cons.Packet) error {
+	if d.ConsSyncer == nil {
+		return errors.New("finality sync disabled")
+	}
+	switch packet := packet.(type) {
+	case *cons.AttestationRangePacket:
+		return d.ConsSyncer.OnAttestationRange(peer, packet.ID, packet.Blocks)
+
+	default:
+		return fmt.Errorf("unexpected cons packet type: %T", packet)
+	}
+}
+
 // deliver injects a new batch of data received from a remote node.
 func (d *Downloader) deliver(destCh chan dataPack, packet dataPack, inMeter, dropMeter metrics.Meter) (err error) {
 	// Update the delivery metrics for both good and failed deliveries
@@ -104,12 +104,19 @@ var Defaults = Config{
 		GasCeil:  8000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
+
+		PriorityGas: 10,
 	},
-	TxPool:        core.DefaultTxPoolConfig,
-	RPCGasCap:     50000000,
-	RPCEVMTimeout: 5 * time.Second,
-	GPO:           FullNodeGPO,
-	RPCTxFeeCap:   1, // 1 ether
+	TxPool:            core.DefaultTxPoolConfig,
+	PrivateTxFallback: 25,
+	RPCGasCap:         50000000,
+	RPCEVMTimeout:     5 * time.Second,
+	GPO:               FullNodeGPO,
+	RPCTxFeeCap:       1, // 1 ether
+
+	HealthMinPeers:       1,
+	HealthMaxHeadAge:     time.Minute,
+	HealthMaxFinalityLag: 200,
 }
 
 func init() {
@@ -155,6 +162,7 @@ type Config struct {
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	LogIndex      bool   `toml:",omitempty"` // Whether to maintain the log index of addresses and topics
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
@@ -196,6 +204,12 @@ type Config struct {
 	// Transaction pool options
 	TxPool core.TxPoolConfig
 
+	// Number of blocks to wait before broadcasting a private transaction publicly
+	PrivateTxFallback uint64
+
+	// RPC endpoints of the validator nodes to offer the private transactions to
+	PrivateTxRelays map[common.Address]string
+
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
@@ -215,12 +229,22 @@ type Config struct {
 	// send-transction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// Thresholds of the health checks, zero disabling a check
+	HealthMinPeers       int           // Minimum number of eth peers
+	HealthMaxHeadAge     time.Duration // Maximum age of the head block
+	HealthMaxFinalityLag uint64        // Maximum distance of the head to the last finalized block
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
 	// CheckpointOracle is the configuration for checkpoint oracle.
 	CheckpointOracle *params.CheckpointOracleConfig `toml:",omitempty"`
 
+	// FinalizedCheckpoint is the hash of a finalized epoch block to sync a fresh
+	// Chaos node from, proven by the proof in the FinalizedCheckpointProof file.
+	FinalizedCheckpoint      common.Hash `toml:",omitempty"`
+	FinalizedCheckpointProof string      `toml:",omitempty"`
+
 	// Arrow Glacier block override (TODO: remove after the fork)
 	OverrideArrowGlacier *big.Int `toml:",omitempty"`
 }
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/prque"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -826,6 +827,10 @@ func (f *BlockFetcher) importBlocks(peer string, block *types.Block) {
 		// Run the actual import and log any issues
 		if _, err := f.insertChain(types.Blocks{block}); err != nil {
 			log.Debug("Propagated block import failed", "peer", peer, "number", block.Number(), "hash", hash, "err", err)
+			if errors.Is(err, core.ErrFinalizedConflict) {
+				// Blocks conflicting with the finality are never acceptable
+				f.dropPeer(peer)
+			}
 			return
 		}
 		// If import succeeded, broadcast the block
@@ -48,6 +48,10 @@ type Backend interface {
 
 	BloomStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
+
+	// LogIndexStatus returns the section size and the number of sections of the
+	// log index, no sections if it's not maintained.
+	LogIndexStatus() (uint64, uint64)
 }
 
 // Filter can be used to retrieve and filter logs.
@@ -146,7 +150,17 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		end = head
 	}
 
-	if (int64(end) - f.begin) > maxFilterBlockRange {
+	// The blocks covered by the log index don't count against the maximum range
+	var logIndexed uint64
+	if f.logIndexable() {
+		size, sections := f.backend.LogIndexStatus()
+		logIndexed = sections * size
+	}
+	rangeBegin := f.begin
+	if int64(logIndexed) > rangeBegin {
+		rangeBegin = int64(logIndexed)
+	}
+	if (int64(end) - rangeBegin) > maxFilterBlockRange {
 		return nil, fmt.Errorf("exceed maximum block range: %d", maxFilterBlockRange)
 	}
 
@@ -155,13 +169,23 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 		logs []*types.Log
 		err  error
 	)
+	if logIndexed > uint64(f.begin) {
+		if logIndexed > end {
+			return f.logIndexLogs(ctx, end)
+		}
+		if logs, err = f.logIndexLogs(ctx, logIndexed-1); err != nil {
+			return logs, err
+		}
+	}
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
+		var found []*types.Log
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
@@ -218,6 +242,43 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 	}
 }
 
+// logIndexable returns whether the filter criteria can be looked up in the log
+// index, which requires addresses or first topics.
+func (f *Filter) logIndexable() bool {
+	return len(f.addresses) > 0 || (len(f.topics) > 0 && len(f.topics[0]) > 0)
+}
+
+// logIndexLogs returns the logs matching the filter criteria based on the log
+// index, checking only the blocks having logs of the addresses and first topics.
+func (f *Filter) logIndexLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
+	var topics []common.Hash
+	if len(f.topics) > 0 {
+		topics = f.topics[0]
+	}
+	size, _ := f.backend.LogIndexStatus()
+	numbers, err := core.LookupLogIndex(f.db, size, uint64(f.begin), end, f.addresses, topics)
+	if err != nil {
+		return nil, err
+	}
+	var logs []*types.Log
+	for _, number := range numbers {
+		if err := ctx.Err(); err != nil {
+			return logs, err
+		}
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if header == nil || err != nil {
+			return logs, err
+		}
+		found, err := f.checkMatches(ctx, header)
+		if err != nil {
+			return logs, err
+		}
+		logs = append(logs, found...)
+	}
+	f.begin = int64(end) + 1
+	return logs, nil
+}
+
 // unindexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
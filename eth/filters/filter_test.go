@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"math/big"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -227,3 +228,80 @@ func TestFilters(t *testing.T) {
 		t.Error("expected 0 log, got", len(logs))
 	}
 }
+
+func TestLogIndexFilters(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key1.PublicKey)
+
+		hash1 = common.BytesToHash([]byte("topic1"))
+		hash2 = common.BytesToHash([]byte("topic2"))
+	)
+	genesis := core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 6000, func(i int, gen *core.BlockGen) {
+		var topic common.Hash
+		switch i {
+		case 10, 3000:
+			topic = hash1
+		case 1500, 5990:
+			topic = hash2
+		default:
+			return
+		}
+		receipt := types.NewReceipt(nil, false, 0)
+		receipt.Logs = []*types.Log{{Address: addr, Topics: []common.Hash{topic}}}
+		gen.AddUncheckedReceipt(receipt)
+		gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.HexToAddress("0x1"), big.NewInt(1), 1, gen.BaseFee(), nil))
+	})
+	for i, block := range chain {
+		rawdb.WriteBlock(db, block)
+		rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		rawdb.WriteHeadHeaderHash(db, block.Hash())
+		rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+	}
+	// Without the log index, the range is too wide
+	filter := NewRangeFilter(backend, 0, -1, []common.Address{addr}, nil)
+	if _, err := filter.Logs(context.Background()); err == nil {
+		t.Fatal("expected error for range too wide")
+	}
+	if err := core.BuildLogIndex(db, params.LogIndexBlocks, 0, false); err != nil {
+		t.Fatalf("failed to build log index: %v", err)
+	}
+	backend.logSections = uint64(len(chain)+1) / params.LogIndexBlocks
+
+	tests := []struct {
+		begin, end int64
+		addresses  []common.Address
+		topics     [][]common.Hash
+		want       []uint64
+	}{
+		{0, -1, []common.Address{addr}, nil, []uint64{11, 1501, 3001, 5991}},
+		{0, -1, nil, [][]common.Hash{{hash1}}, []uint64{11, 3001}},
+		{0, -1, []common.Address{addr}, [][]common.Hash{{hash2}}, []uint64{1501, 5991}},
+		{20, 3000, []common.Address{addr}, nil, []uint64{1501}},
+		{0, -1, nil, [][]common.Hash{nil, {hash1}}, nil},
+	}
+	for i, tt := range tests {
+		logs, err := NewRangeFilter(backend, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+		if i == len(tests)-1 {
+			// No address nor first topic to look up, the range is too wide
+			if err == nil {
+				t.Errorf("test %d: expected error for range too wide", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs: %v", i, err)
+		}
+		var have []uint64
+		for _, log := range logs {
+			have = append(have, log.BlockNumber)
+		}
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("test %d: log blocks mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
@@ -26,10 +26,9 @@ type Prediction struct {
 	chainHeadSub event.Subscription
 	pool         *core.TxPool
 
-	predis        []uint // gas price prediction in gwei, currently will be 3 items, from hight(fast) to low(slow)
-	lockPredis    sync.RWMutex
-	wg            sync.WaitGroup
-	blockGasLimit uint64
+	predis     []uint // gas price prediction in gwei, currently will be 3 items, from hight(fast) to low(slow)
+	lockPredis sync.RWMutex
+	wg         sync.WaitGroup
 }
 
 func NewPrediction(cfg Config, backend OracleBackend, pool *core.TxPool) *Prediction {
@@ -110,9 +109,6 @@ func (p *Prediction) initTxCnts() {
 		}
 	}
 	p.txCnts = NewStats(cnts)
-
-	//gas limit
-	p.blockGasLimit = head.GasLimit
 }
 
 func (p *Prediction) loop() {
@@ -131,7 +127,6 @@ func (p *Prediction) loop() {
 			head := ev.Block
 			txcnt := len(head.Transactions())
 			p.txCnts.Add(txcnt)
-			p.blockGasLimit = head.GasLimit()
 		case <-p.chainHeadSub.Err():
 			log.Warn("prediction loop quitting")
 			return
@@ -200,7 +195,8 @@ func (p *Prediction) update() {
 }
 
 func (p *Prediction) filteroutInvalid(txs TxByPrice) TxByPrice {
-	maxgas := (p.blockGasLimit / 10) * 6
+	// the pool knows the max gas budget of blocks, which may be shaped by the consensus engine
+	maxgas := (p.pool.MaxGas() / 10) * 6
 	maxlive := time.Duration(p.cfg.MaxValidPendingSecs) * time.Second
 	i, j := 0, len(txs)
 	for i < j {
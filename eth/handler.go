@@ -39,6 +39,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
+	lru "github.com/hashicorp/golang-lru"
 )
 
 const (
@@ -47,6 +48,14 @@ const (
 	txChanSize  = 4096
 	naChanSize  = 4096
 	njfChanSize = 4096
+
+	// pooledAttestationsLimit is the number of recently accepted attestations
+	// kept to serve the peers pulling the announced ones.
+	pooledAttestationsLimit = 4096
+
+	// attestationRequestTimeout is the time an announced attestation is not
+	// requested again from other peers.
+	attestationRequestTimeout = 5 * time.Second
 )
 
 var (
@@ -88,6 +97,10 @@ type handlerConfig struct {
 	EventMux   *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint *params.TrustedCheckpoint // Hard coded checkpoint for sync challenges
 	Whitelist  map[uint64]common.Hash    // Hard coded whitelist for sync challenged
+
+	PrivateTxFallback uint64                    // Number of blocks to wait before broadcasting a private transaction publicly
+	PrivateTxRelays   map[common.Address]string // RPC endpoints of the validator nodes to offer private transactions to
+	ValidatorMesh     *validatorMesh            // Mesh of the validator connections, nil if not running Chaos
 }
 
 type handler struct {
@@ -111,6 +124,12 @@ type handler struct {
 	blockFetcher *fetcher.BlockFetcher
 	txFetcher    *fetcher.TxFetcher
 	peers        *peerSet
+	privateTxs   *privateTxSet
+	mesh         *validatorMesh
+	backfill     *backfiller
+
+	pooledAttestations    *lru.Cache // Recently accepted attestations by hash
+	requestedAttestations *lru.Cache // Announced attestations requested recently, by hash
 
 	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
@@ -123,6 +142,9 @@ type handler struct {
 
 	whitelist map[uint64]common.Hash
 
+	bannedPeers map[string]time.Time // Misbehaving peers and the time until they're banned
+	bannedLock  sync.Mutex
+
 	// channels for fetcher, syncer, txsyncLoop
 	quitSync chan struct{}
 
@@ -145,9 +167,16 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		txpool:     config.TxPool,
 		chain:      config.Chain,
 		peers:      newPeerSet(),
+		privateTxs: newPrivateTxSet(config.PrivateTxFallback, config.PrivateTxRelays),
+		mesh:       config.ValidatorMesh,
 		whitelist:  config.Whitelist,
 		quitSync:   make(chan struct{}),
+
+		bannedPeers: make(map[string]time.Time),
 	}
+	h.backfill = newBackfiller(config.Database, h.peers, config.Chain.Genesis().Hash())
+	h.pooledAttestations, _ = lru.New(pooledAttestationsLimit)
+	h.requestedAttestations, _ = lru.New(pooledAttestationsLimit)
 	if config.Sync == downloader.FullSync {
 		// The database seems empty as the current block is the genesis. Yet the fast
 		// block is ahead, so fast sync was enabled for this node at a certain point.
@@ -190,6 +219,9 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		h.stateBloom = trie.NewSyncBloom(config.BloomCache, config.Database)
 	}
 	h.downloader = downloader.New(h.checkpointNumber, config.Database, h.stateBloom, h.eventMux, h.chain, nil, h.removePeer)
+	if h.chain.ChaosEngine != nil {
+		h.downloader.ConsSyncer = cons.NewSyncer(h.chain)
+	}
 
 	// Construct the fetcher (short sync)
 	validator := func(header *types.Header) error {
@@ -241,6 +273,10 @@ func newHandler(config *handlerConfig) (*handler, error) {
 // runEthPeer registers an eth peer into the joint eth/snap peerset, adds it to
 // various subsistems and starts handling messages.
 func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
+	if h.isBanned(peer.ID()) {
+		peer.Log().Debug("Rejecting banned peer")
+		return p2p.DiscUselessPeer
+	}
 	// If the peer has a `snap` extension, wait for it to connect so we can have
 	// a uniform initialization/teardown mechanism
 	snap, err := h.peers.waitSnapExtension(peer)
@@ -312,6 +348,12 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 			return err
 		}
 	}
+	if cons != nil && h.downloader.ConsSyncer != nil {
+		if err := h.downloader.ConsSyncer.Register(cons); err != nil {
+			peer.Log().Error("Failed to register peer in cons syncer", "err", err)
+			return err
+		}
+	}
 	h.chainSync.handlePeerEvent(peer)
 
 	// Propagate existing transactions. new transactions appearing
@@ -370,6 +412,10 @@ func (h *handler) runConsExtension(peer *cons.Peer, handler cons.Handler) error
 	h.peerWG.Add(1)
 	defer h.peerWG.Done()
 
+	if h.isBanned(peer.ID()) {
+		peer.Log().Debug("Rejecting banned peer")
+		return p2p.DiscUselessPeer
+	}
 	if err := h.peers.registerConsExtension(peer); err != nil {
 		peer.Log().Error("Cons extension registration failed", "err", err)
 		return err
@@ -385,6 +431,30 @@ func (h *handler) removePeer(id string) {
 	}
 }
 
+// banPeer disconnects a peer and rejects it until the ban expires.
+func (h *handler) banPeer(id string, duration time.Duration) {
+	h.bannedLock.Lock()
+	h.bannedPeers[id] = time.Now().Add(duration)
+	h.bannedLock.Unlock()
+
+	h.removePeer(id)
+}
+
+// isBanned reports whether a peer is banned, the expired bans are cleaned up.
+func (h *handler) isBanned(id string) bool {
+	h.bannedLock.Lock()
+	defer h.bannedLock.Unlock()
+
+	now := time.Now()
+	for peer, until := range h.bannedPeers {
+		if now.After(until) {
+			delete(h.bannedPeers, peer)
+		}
+	}
+	_, ok := h.bannedPeers[id]
+	return ok
+}
+
 // unregisterPeer removes a peer from the downloader, fetchers and main peer set.
 func (h *handler) unregisterPeer(id string) {
 	// Create a custom logger to avoid printing the entire id
@@ -408,6 +478,10 @@ func (h *handler) unregisterPeer(id string) {
 	if peer.snapExt != nil {
 		h.downloader.SnapSyncer.Unregister(id)
 	}
+	// Remove the `cons` extension if it exists
+	if peer.consExt != nil && h.downloader.ConsSyncer != nil {
+		h.downloader.ConsSyncer.Unregister(id)
+	}
 	h.downloader.UnregisterPeer(id)
 	h.txFetcher.Drop(id)
 
@@ -425,6 +499,10 @@ func (h *handler) Start(maxPeers int) {
 	h.txsSub = h.txpool.SubscribeNewTxsEvent(h.txsCh)
 	go h.txBroadcastLoop()
 
+	// offer private transactions
+	h.wg.Add(1)
+	go h.privateTxLoop()
+
 	// broadcast mined blocks
 	h.wg.Add(1)
 	h.minedBlockSub = h.eventMux.Subscribe(core.NewMinedBlockEvent{})
@@ -445,6 +523,9 @@ func (h *handler) Start(maxPeers int) {
 	// start sync handlers
 	h.wg.Add(1)
 	go h.chainSync.loop()
+
+	// backfill the headers below the sync checkpoint
+	h.backfill.start()
 }
 
 func (h *handler) Stop() {
@@ -457,6 +538,8 @@ func (h *handler) Stop() {
 	// After this is done, no new peers will be accepted.
 	close(h.quitSync)
 	h.wg.Wait()
+	h.backfill.stop()
+	h.privateTxs.close()
 
 	// Disconnect existing sessions.
 	// This also closes the gate for any new registrations on the peer set.
@@ -486,6 +569,9 @@ func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
 		}
 		// Send the block to a subset of our peers
 		transfer := peers[:int(math.Sqrt(float64(len(peers))))]
+
+		// The validators in the mesh always get the full block
+		transfer = h.appendMeshPeers(transfer, peers)
 		for _, peer := range transfer {
 			log.Info("metric", "method", "broadcastBlock", "peer", peer.ID(), "hash", block.Header().Hash().String(), "number", block.Header().Number.Uint64(), "fullBlock", true)
 			peer.AsyncSendNewBlock(block, td)
@@ -547,6 +633,8 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 }
 
 func (h *handler) BroadcastAttestationToOtherNodes(a *types.Attestation) {
+	h.pooledAttestations.Add(a.Hash(), a)
+
 	peers := h.peers.peersWithoutAttestation(a.Hash())
 	//log.Debug("BroadcastAttestationToOtherNodes", "peers", len(peers),
 	//"hash", a.TargetRangeEdge.Hash, "number", a.TargetRangeEdge.Number.Uint64())
@@ -586,7 +674,7 @@ func (h *handler) txBroadcastLoop() {
 	for {
 		select {
 		case event := <-h.txsCh:
-			h.BroadcastTransactions(event.Txs)
+			h.BroadcastTransactions(h.privateTxs.filter(event.Txs))
 		case <-h.txsSub.Err():
 			return
 		}
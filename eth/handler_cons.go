@@ -17,11 +17,14 @@
 package eth
 
 import (
-	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/cons"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"sync/atomic"
+	"time"
 )
 
 // consHandler implements the cons.Backend interface to handle the various network
@@ -48,8 +51,18 @@ func (h *consHandler) PeerInfo(id enode.ID) interface{} {
 // Handle is invoked from a peer's message handler when it receives a new remote
 // message that the handler couldn't consume and serve itself.
 func (h *consHandler) Handle(peer *cons.Peer, packet cons.Packet) error {
-	//TODO: deliver AttestationsPacket to downloader
-	return errors.New("unimplemented")
+	switch packet := packet.(type) {
+	case *cons.AttestationRangePacket:
+		return h.downloader.DeliverConsPacket(peer, packet)
+
+	default:
+		return fmt.Errorf("unexpected cons packet type: %T", packet)
+	}
+}
+
+// BanPeer disconnects a misbehaving peer and rejects it for the given duration.
+func (h *consHandler) BanPeer(id string, duration time.Duration) {
+	(*handler)(h).banPeer(id, duration)
 }
 
 // AcceptAttestation retrieves whether attestation processing is enabled on the node
@@ -59,3 +72,33 @@ func (h *consHandler) Handle(peer *cons.Peer, packet cons.Packet) error {
 func (h *consHandler) AcceptAttestation() bool {
 	return atomic.LoadUint32(&h.acceptTxs) == 1
 }
+
+// PooledAttestation retrieves a recently accepted attestation by hash, to serve
+// the peers pulling the announced ones.
+func (h *consHandler) PooledAttestation(hash common.Hash) *types.Attestation {
+	if a, ok := h.pooledAttestations.Get(hash); ok {
+		return a.(*types.Attestation)
+	}
+	return nil
+}
+
+// UnknownAttestations filters the announced attestation hashes down to the ones
+// neither known nor already requested from another peer recently, the returned
+// ones are marked as requested.
+func (h *consHandler) UnknownAttestations(hashes []common.Hash) []common.Hash {
+	var (
+		now     = time.Now()
+		unknown = make([]common.Hash, 0, len(hashes))
+	)
+	for _, hash := range hashes {
+		if h.pooledAttestations.Contains(hash) {
+			continue
+		}
+		if at, ok := h.requestedAttestations.Get(hash); ok && now.Sub(at.(time.Time)) < attestationRequestTimeout {
+			continue
+		}
+		h.requestedAttestations.Add(hash, now)
+		unknown = append(unknown, hash)
+	}
+	return unknown
+}
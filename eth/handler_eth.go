@@ -78,6 +78,10 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		return nil
 
 	case *eth.ReceiptsPacket:
+		// Receipts requested to backfill below the sync checkpoint are not for the downloader
+		if h.backfill.deliverReceipts(peer.ID(), *packet) {
+			return nil
+		}
 		if err := h.downloader.DeliverReceipts(peer.ID(), *packet); err != nil {
 			log.Debug("Failed to deliver receipts", "err", err)
 		}
@@ -111,6 +115,10 @@ func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) erro
 	if p == nil {
 		return errors.New("unregistered during callback")
 	}
+	// Headers requested to backfill below the sync checkpoint are not for the downloader
+	if h.backfill.deliverHeaders(peer.ID(), headers) {
+		return nil
+	}
 	// If no headers were received, but we're expencting a checkpoint header, consider it that
 	if len(headers) == 0 && p.syncDrop != nil {
 		// Stop the timer either way, decide later to drop or not
@@ -163,6 +171,10 @@ func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) erro
 // handleBodies is invoked from a peer's message handler when it transmits a batch
 // of block bodies for the local node to process.
 func (h *ethHandler) handleBodies(peer *eth.Peer, txs [][]*types.Transaction, uncles [][]*types.Header) error {
+	// Bodies requested to backfill below the sync checkpoint are not for the fetcher
+	if h.backfill.deliverBodies(peer.ID(), txs, uncles) {
+		return nil
+	}
 	// Filter out any explicitly requested bodies, deliver the rest to the downloader
 	filter := len(txs) > 0 || len(uncles) > 0
 	if filter {
@@ -0,0 +1,338 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// privateTxChanSize is the size of channel listening to ChainHeadEvent for private transactions.
+	privateTxChanSize = 10
+
+	// privateRelayTimeout is the max time spent offering private transactions to
+	// the relay of a validator.
+	privateRelayTimeout = 5 * time.Second
+)
+
+var (
+	errPrivateTxUnsupported = errors.New("private transactions require the chaos engine")
+	errPrivateTxKnown       = errors.New("private transaction already known")
+)
+
+// privateTx is a transaction submitted privately, it's kept away from the public
+// gossip and only offered to the in-turn validators until the fallback block.
+type privateTx struct {
+	tx         *types.Transaction
+	submitted  uint64           // Head block number when it's submitted
+	fallback   uint64           // Block number from which it's broadcast publicly
+	public     bool             // Whether it has fallen back to the public broadcast
+	validators []common.Address // Validators it has been offered to
+}
+
+// privateTxSet tracks the private transactions, and the relays of the validators
+// to offer them to.
+//
+// The relays are the RPC endpoints of the validator nodes configured by the node
+// operator, the gossip peers are never trusted with the private transactions. The
+// transactions are offered through `eth_sendPrivateTransaction`, so the validator
+// node keeps them away from the public gossip as well.
+type privateTxSet struct {
+	fallback uint64                           // Number of blocks to wait before the public broadcast
+	txs      map[common.Hash]*privateTx       // Private transactions not yet included
+	relays   map[common.Address]*privateRelay // RPC endpoints of the validator nodes
+	lock     sync.RWMutex
+}
+
+func newPrivateTxSet(fallback uint64, relays map[common.Address]string) *privateTxSet {
+	set := &privateTxSet{
+		fallback: fallback,
+		txs:      make(map[common.Hash]*privateTx),
+		relays:   make(map[common.Address]*privateRelay),
+	}
+	for validator, url := range relays {
+		set.relays[validator] = &privateRelay{url: url}
+	}
+	return set
+}
+
+// add starts tracking a private transaction submitted at the given head.
+func (s *privateTxSet) add(tx *types.Transaction, head uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.txs[tx.Hash()]; ok {
+		return errPrivateTxKnown
+	}
+	s.txs[tx.Hash()] = &privateTx{
+		tx:        tx,
+		submitted: head,
+		fallback:  head + s.fallback,
+	}
+	return nil
+}
+
+// remove stops tracking a private transaction.
+func (s *privateTxSet) remove(hash common.Hash) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.txs, hash)
+}
+
+// status returns the status of a private transaction, nil if it's unknown.
+func (s *privateTxSet) status(hash common.Hash) *ethapi.PrivateTxStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	ptx, ok := s.txs[hash]
+	if !ok {
+		return nil
+	}
+	status := &ethapi.PrivateTxStatus{
+		Status:     ethapi.PrivateTxPrivate,
+		Submitted:  hexutil.Uint64(ptx.submitted),
+		Fallback:   hexutil.Uint64(ptx.fallback),
+		Validators: append([]common.Address{}, ptx.validators...),
+	}
+	if ptx.public {
+		status.Status = ethapi.PrivateTxPublic
+	}
+	return status
+}
+
+// empty returns whether there are no private transactions tracked.
+func (s *privateTxSet) empty() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.txs) == 0
+}
+
+// filter returns the transactions allowed to be gossiped publicly.
+func (s *privateTxSet) filter(txs types.Transactions) types.Transactions {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.txs) == 0 {
+		return txs
+	}
+	public := make(types.Transactions, 0, len(txs))
+	for _, tx := range txs {
+		if ptx, ok := s.txs[tx.Hash()]; ok && !ptx.public {
+			continue
+		}
+		public = append(public, tx)
+	}
+	return public
+}
+
+// close disconnects from the relays.
+func (s *privateTxSet) close() {
+	for _, relay := range s.relays {
+		relay.close()
+	}
+}
+
+// privateRelay is the RPC endpoint of a validator node accepting the private
+// transactions, it's dialed on the first offer.
+type privateRelay struct {
+	url    string
+	client *rpc.Client
+	lock   sync.Mutex
+}
+
+// send offers the private transactions to the validator node. The connection is
+// dropped on failures, to be dialed again on the next offer.
+func (r *privateRelay) send(txs types.Transactions) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), privateRelayTimeout)
+	defer cancel()
+
+	if r.client == nil {
+		client, err := rpc.DialContext(ctx, r.url)
+		if err != nil {
+			return err
+		}
+		r.client = client
+	}
+	var failed error
+	for _, tx := range txs {
+		data, err := tx.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		err = r.client.CallContext(ctx, nil, "eth_sendPrivateTransaction", hexutil.Bytes(data))
+		if err == nil {
+			continue
+		}
+		// The errors returned by the node, like knowing the transaction already,
+		// leave the connection usable
+		if _, ok := err.(rpc.Error); !ok {
+			r.client.Close()
+			r.client = nil
+			return err
+		}
+		failed = err
+	}
+	return failed
+}
+
+// close disconnects from the validator node.
+func (r *privateRelay) close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.client != nil {
+		r.client.Close()
+		r.client = nil
+	}
+}
+
+// sendPrivateTransaction adds a transaction to the local pool, without gossiping
+// it to the public until the fallback.
+func (h *handler) sendPrivateTransaction(tx *types.Transaction, add func(*types.Transaction) error) error {
+	if h.chain.ChaosEngine == nil {
+		return errPrivateTxUnsupported
+	}
+	// Start tracking before the pool announces the transaction
+	if err := h.privateTxs.add(tx, h.chain.CurrentBlock().NumberU64()); err != nil {
+		return err
+	}
+	if err := add(tx); err != nil {
+		h.privateTxs.remove(tx.Hash())
+		return err
+	}
+	h.offerPrivateTransactions(h.chain.CurrentBlock().Header())
+	return nil
+}
+
+// privateTxLoop offers the private transactions to the new in-turn validators,
+// or broadcasts them publicly on reaching the fallback.
+func (h *handler) privateTxLoop() {
+	defer h.wg.Done()
+
+	headCh := make(chan core.ChainHeadEvent, privateTxChanSize)
+	headSub := h.chain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-headCh:
+			h.offerPrivateTransactions(ev.Block.Header())
+		case <-headSub.Err():
+			return
+		case <-h.quitSync:
+			return
+		}
+	}
+}
+
+// offerPrivateTransactions processes the private transactions on a new head.
+func (h *handler) offerPrivateTransactions(head *types.Header) {
+	if h.chain.ChaosEngine == nil || h.privateTxs.empty() {
+		return
+	}
+	number := head.Number.Uint64()
+	validators := h.inturnValidators(head)
+
+	var (
+		public types.Transactions
+		offers = make(map[*privateRelay]types.Transactions)
+	)
+	h.privateTxs.lock.Lock()
+	for hash, ptx := range h.privateTxs.txs {
+		// Included or dropped, nothing to do anymore
+		if h.txpool.Get(hash) == nil {
+			delete(h.privateTxs.txs, hash)
+			continue
+		}
+		if ptx.public {
+			continue
+		}
+		if number >= ptx.fallback {
+			log.Debug("Private transaction falls back to public", "hash", hash, "submitted", ptx.submitted)
+			ptx.public = true
+			public = append(public, ptx.tx)
+			continue
+		}
+		for _, val := range validators {
+			relay, ok := h.privateTxs.relays[val]
+			if !ok || containsAddress(ptx.validators, val) {
+				continue
+			}
+			offers[relay] = append(offers[relay], ptx.tx)
+			ptx.validators = append(ptx.validators, val)
+		}
+	}
+	h.privateTxs.lock.Unlock()
+
+	for relay, txs := range offers {
+		go func(relay *privateRelay, txs types.Transactions) {
+			if err := relay.send(txs); err != nil {
+				log.Debug("Failed to offer private transactions", "relay", relay.url, "count", len(txs), "err", err)
+			}
+		}(relay, txs)
+	}
+	if len(public) > 0 {
+		h.BroadcastTransactions(public)
+	}
+}
+
+// inturnValidators returns the validators in-turn for the next block and the
+// following in-turn run.
+func (h *handler) inturnValidators(head *types.Header) []common.Address {
+	validators, err := h.chain.ChaosEngine.Validators(h.chain, head.Hash(), head.Number.Uint64())
+	if err != nil || len(validators) == 0 {
+		log.Debug("Failed to retrieve validators for private transactions", "number", head.Number, "err", err)
+		return nil
+	}
+	var (
+		next             = head.Number.Uint64() + 1
+		continuousInturn = h.chain.Config().ChaosContinuousInturn(head.Number)
+		round            = uint64(len(validators)) * continuousInturn
+		current          = validators[(next%round)/continuousInturn]
+		following        = validators[((next/continuousInturn+1)*continuousInturn%round)/continuousInturn]
+	)
+	if current == following {
+		return []common.Address{current}
+	}
+	return []common.Address{current, following}
+}
+
+// containsAddress returns whether the address is included.
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
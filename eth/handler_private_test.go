@@ -0,0 +1,114 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Tests that private transactions are kept away from the public gossip until
+// they fall back to public.
+func TestPrivateTxSetFilter(t *testing.T) {
+	set := newPrivateTxSet(10, nil)
+
+	private := types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	public := types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	if err := set.add(private, 100); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := set.add(private, 100); err != errPrivateTxKnown {
+		t.Fatalf("adding known private transaction error mismatch: have %v, want %v", err, errPrivateTxKnown)
+	}
+	txs := set.filter(types.Transactions{private, public})
+	if len(txs) != 1 || txs[0] != public {
+		t.Fatalf("filtered transactions mismatch: have %v, want %v", txs, types.Transactions{public})
+	}
+	status := set.status(private.Hash())
+	if status == nil || status.Status != ethapi.PrivateTxPrivate || status.Fallback != 110 {
+		t.Fatalf("private transaction status mismatch: have %+v", status)
+	}
+	set.txs[private.Hash()].public = true
+	if txs := set.filter(types.Transactions{private, public}); len(txs) != 2 {
+		t.Fatalf("filtered transactions mismatch: have %d, want %d", len(txs), 2)
+	}
+	if status := set.status(private.Hash()); status.Status != ethapi.PrivateTxPublic {
+		t.Fatalf("private transaction status mismatch: have %s, want %s", status.Status, ethapi.PrivateTxPublic)
+	}
+	set.remove(private.Hash())
+	if status := set.status(private.Hash()); status != nil {
+		t.Fatalf("removed private transaction status mismatch: have %+v, want nil", status)
+	}
+}
+
+// testPrivateService is an RPC service recording the offered private transactions.
+type testPrivateService struct {
+	txs []common.Hash
+}
+
+func (s *testPrivateService) SendPrivateTransaction(input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	for _, hash := range s.txs {
+		if hash == tx.Hash() {
+			return common.Hash{}, errPrivateTxKnown
+		}
+	}
+	s.txs = append(s.txs, tx.Hash())
+	return tx.Hash(), nil
+}
+
+// Tests that private transactions are offered to the relays of the validators
+// through the RPC, and the errors of the relay don't stop the offer.
+func TestPrivateRelay(t *testing.T) {
+	service := new(testPrivateService)
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	defer server.Stop()
+
+	httpsrv := httptest.NewServer(server)
+	defer httpsrv.Close()
+
+	validator := common.HexToAddress("0x01")
+	set := newPrivateTxSet(10, map[common.Address]string{validator: httpsrv.URL})
+	defer set.close()
+
+	txs := types.Transactions{
+		types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil),
+		types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil),
+	}
+	if err := set.relays[validator].send(txs[:1]); err != nil {
+		t.Fatalf("failed to offer private transaction: %v", err)
+	}
+	if err := set.relays[validator].send(txs); err == nil {
+		t.Fatalf("offering known private transaction succeeded")
+	}
+	if len(service.txs) != 2 || service.txs[0] != txs[0].Hash() || service.txs[1] != txs[1].Hash() {
+		t.Fatalf("offered private transactions mismatch: have %v", service.txs)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// registerHealthChecks adds the checks of the node health endpoints.
+func (s *Ethereum) registerHealthChecks(stack *node.Node) {
+	stack.RegisterHealthCheck("sync", true, s.checkSync)
+	stack.RegisterHealthCheck("peers", false, s.checkPeers)
+	stack.RegisterHealthCheck("head", false, s.checkHead)
+	if s.isChaosEngine {
+		stack.RegisterHealthCheck("finality", false, s.checkFinality)
+		stack.RegisterHealthCheck("validator", true, s.checkValidator)
+	}
+}
+
+// checkSync reports the sync progress, the node being unready until synced.
+func (s *Ethereum) checkSync() (interface{}, error) {
+	var (
+		progress = s.handler.downloader.Progress()
+		syncing  = s.handler.downloader.Synchronising()
+		synced   = s.Synced()
+	)
+	status := map[string]interface{}{
+		"syncing":      syncing,
+		"synced":       synced,
+		"currentBlock": progress.CurrentBlock,
+		"highestBlock": progress.HighestBlock,
+	}
+	if syncing || !synced {
+		return status, errors.New("syncing")
+	}
+	return status, nil
+}
+
+// checkPeers reports the numbers of eth and cons peers.
+func (s *Ethereum) checkPeers() (interface{}, error) {
+	status := map[string]int{
+		"eth":  s.handler.peers.len(),
+		"cons": s.handler.peers.consLen(),
+	}
+	if min := s.config.HealthMinPeers; status["eth"] < min {
+		return status, fmt.Errorf("too few peers: have %d, want at least %d", status["eth"], min)
+	}
+	return status, nil
+}
+
+// checkHead reports the head block and its age.
+func (s *Ethereum) checkHead() (interface{}, error) {
+	head := s.blockchain.CurrentBlock()
+	age := time.Since(time.Unix(int64(head.Time()), 0)).Round(time.Second)
+	status := map[string]interface{}{
+		"number": head.NumberU64(),
+		"hash":   head.Hash(),
+		"age":    common.PrettyDuration(age).String(),
+	}
+	if max := s.config.HealthMaxHeadAge; max > 0 && age > max {
+		return status, fmt.Errorf("head too old: %v, allowed %v", common.PrettyDuration(age), common.PrettyDuration(max))
+	}
+	return status, nil
+}
+
+// checkFinality reports the last justified and finalized blocks, and the
+// distance of the head to the finalized one.
+func (s *Ethereum) checkFinality() (interface{}, error) {
+	var (
+		head                 = s.blockchain.CurrentBlock().NumberU64()
+		justified, finalized = s.blockchain.FinalityEdges()
+		lag                  uint64
+	)
+	if number := finalized.Number.Uint64(); head > number {
+		lag = head - number
+	}
+	status := map[string]interface{}{
+		"justified": justified.Number.Uint64(),
+		"finalized": finalized.Number.Uint64(),
+		"lag":       lag,
+	}
+	if max := s.config.HealthMaxFinalityLag; max > 0 && lag > max {
+		return status, fmt.Errorf("finality lagging: %d blocks, allowed %d", lag, max)
+	}
+	return status, nil
+}
+
+// checkValidator reports the attestation status of the local validator, a
+// mining validator being unready until it attests.
+func (s *Ethereum) checkValidator() (interface{}, error) {
+	if !s.IsMining() {
+		return nil, nil
+	}
+	status := map[string]interface{}{
+		"address":           s.chaosEngine.CurrentValidator(),
+		"readyAttest":       s.chaosEngine.IsReadyAttest(),
+		"attestationStatus": "pending",
+	}
+	if s.chaosEngine.AttestationStatus() == types.AttestationStart {
+		status["attestationStatus"] = "started"
+	}
+	if !s.chaosEngine.IsReadyAttest() {
+		return status, errors.New("validator not attesting")
+	}
+	return status, nil
+}
@@ -0,0 +1,323 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth/protocols/cons"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// meshRefreshInterval is the interval to re-balance the validator mesh without
+	// validator set changes, and to look for the missing validator nodes.
+	meshRefreshInterval = time.Minute
+
+	// meshChanSize is the size of the channels listening to the chain events.
+	meshChanSize = 10
+)
+
+// validatorMesh keeps the local validator directly connected to the other
+// validators, so the attestations and the new blocks don't depend on random
+// public peers. The nodes of the validators are found by the `consv` ENR entries
+// signed by the validator keys, and the mesh is re-balanced as the validator
+// set changes.
+type validatorMesh struct {
+	chain  *core.BlockChain
+	server *p2p.Server
+	iter   enode.Iterator // Candidate nodes possibly carrying the validator entries
+
+	nodes   map[common.Address]*enode.Node // Latest known node of the validators
+	mesh    map[common.Address]*enode.Node // Validator nodes kept connected
+	peers   map[string]struct{}            // IDs of the nodes kept connected
+	missing int                            // Number of validators without known nodes
+	signed  common.Address                 // Validator advertised in the local record
+	lock    sync.RWMutex
+
+	found chan struct{} // Notification channel of the newly found validator nodes
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newValidatorMesh(chain *core.BlockChain, server *p2p.Server) *validatorMesh {
+	return &validatorMesh{
+		chain:  chain,
+		server: server,
+		nodes:  make(map[common.Address]*enode.Node),
+		mesh:   make(map[common.Address]*enode.Node),
+		peers:  make(map[string]struct{}),
+		found:  make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+}
+
+// start begins looking for the validator nodes from the candidates, and keeping
+// the mesh.
+func (m *validatorMesh) start(iter enode.Iterator) {
+	m.iter = iter
+
+	m.wg.Add(2)
+	go m.discoverLoop()
+	go m.loop()
+}
+
+// stop terminates the mesh maintenance, the connections are left to the server.
+func (m *validatorMesh) stop() {
+	if m.iter != nil {
+		m.iter.Close()
+	}
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// contains reports whether the peer is a validator node kept in the mesh.
+func (m *validatorMesh) contains(id string) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	_, ok := m.peers[id]
+	return ok
+}
+
+// discoverLoop collects the validator nodes from the candidates, it backs off
+// while all the validator nodes are known.
+func (m *validatorMesh) discoverLoop() {
+	defer m.wg.Done()
+
+	for m.iter.Next() {
+		node := m.iter.Node()
+		validator, ok := cons.NodeValidator(node)
+		if ok {
+			m.lock.Lock()
+			old := m.nodes[validator]
+			if old == nil || old.ID() != node.ID() || old.Seq() < node.Seq() {
+				m.nodes[validator] = node
+				select {
+				case m.found <- struct{}{}:
+				default:
+				}
+			}
+			m.lock.Unlock()
+		}
+		m.lock.RLock()
+		complete := m.missing == 0
+		m.lock.RUnlock()
+
+		if complete {
+			select {
+			case <-time.After(meshRefreshInterval):
+			case <-m.quit:
+				return
+			}
+		}
+	}
+}
+
+// loop re-balances the mesh on the validator set changes. The joining validators
+// are connected ahead, and the leaving ones are kept until the new set takes effect.
+func (m *validatorMesh) loop() {
+	defer m.wg.Done()
+
+	setCh := make(chan core.ValidatorSetChangeEvent, meshChanSize)
+	setSub := m.chain.SubscribeValidatorSetChangeEvent(setCh)
+	defer setSub.Unsubscribe()
+
+	headCh := make(chan core.ChainHeadEvent, meshChanSize)
+	headSub := m.chain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	refresh := time.NewTicker(meshRefreshInterval)
+	defer refresh.Stop()
+
+	var (
+		joined    []common.Address // Validators joining at the effective block
+		effective uint64           // Block from which the new validator set takes effect
+	)
+	m.rebalance(nil)
+	for {
+		select {
+		case ev := <-setCh:
+			joined, effective = ev.Joined, ev.Effective
+			m.rebalance(joined)
+
+		case ev := <-headCh:
+			if effective != 0 && ev.Block.NumberU64() >= effective {
+				joined, effective = nil, 0
+				m.rebalance(nil)
+			}
+
+		case <-m.found:
+			m.rebalance(joined)
+
+		case <-refresh.C:
+			m.rebalance(joined)
+
+		case <-setSub.Err():
+			return
+		case <-headSub.Err():
+			return
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// rebalance connects the nodes of the current validators, plus the given extra
+// ones, and drops the nodes no longer validators. Nodes which are not validators
+// themselves keep no mesh.
+func (m *validatorMesh) rebalance(extra []common.Address) {
+	var (
+		engine = m.chain.ChaosEngine
+		self   = engine.CurrentValidator()
+		head   = m.chain.CurrentHeader()
+	)
+	validators, err := engine.Validators(m.chain, head.Hash(), head.Number.Uint64())
+	if err != nil {
+		log.Debug("Failed to retrieve validators for the mesh", "number", head.Number, "err", err)
+		return
+	}
+	validators = append(validators, extra...)
+
+	var member bool
+	for _, val := range validators {
+		if self != (common.Address{}) && val == self {
+			member = true
+		}
+	}
+	if member {
+		m.advertise(self)
+	}
+	var drops, adds []*enode.Node
+
+	m.lock.Lock()
+	want := make(map[common.Address]*enode.Node)
+	missing := 0
+	if member {
+		for _, val := range validators {
+			if val == self {
+				continue
+			}
+			if node, ok := m.nodes[val]; ok {
+				want[val] = node
+			} else {
+				missing++
+			}
+		}
+	}
+	for val, node := range m.mesh {
+		if n, ok := want[val]; !ok || n.ID() != node.ID() {
+			drops = append(drops, node)
+			delete(m.mesh, val)
+			delete(m.peers, node.ID().String())
+		}
+	}
+	for val, node := range want {
+		if _, ok := m.mesh[val]; !ok {
+			adds = append(adds, node)
+			m.mesh[val] = node
+			m.peers[node.ID().String()] = struct{}{}
+		}
+	}
+	m.missing = missing
+	connected := len(m.mesh)
+	m.lock.Unlock()
+
+	for _, node := range drops {
+		m.server.RemoveTrustedPeer(node)
+		m.server.RemovePeer(node)
+	}
+	for _, node := range adds {
+		m.server.AddTrustedPeer(node)
+		m.server.AddPeer(node)
+	}
+	if len(drops) > 0 || len(adds) > 0 {
+		log.Info("Validator mesh re-balanced", "validators", len(validators), "connected", connected, "missing", missing, "added", len(adds), "dropped", len(drops))
+	}
+}
+
+// advertise sets the signed validator entry into the local node record.
+func (m *validatorMesh) advertise(validator common.Address) {
+	m.lock.RLock()
+	signed := m.signed
+	m.lock.RUnlock()
+
+	if signed == validator {
+		return
+	}
+	ln := m.server.LocalNode()
+	entry, err := cons.NewValidatorEntry(ln.ID(), validator, m.chain.ChaosEngine.SignData)
+	if err != nil {
+		log.Warn("Failed to sign the validator node record", "validator", validator, "err", err)
+		return
+	}
+	ln.Set(entry)
+
+	m.lock.Lock()
+	m.signed = validator
+	m.lock.Unlock()
+
+	log.Info("Advertising validator node record", "validator", validator, "id", ln.ID())
+}
+
+// resolveIterator retrieves the full records of the nodes found by the discovery
+// v4, which only carries the endpoints of them.
+type resolveIterator struct {
+	enode.Iterator
+	v4   *discover.UDPv4
+	node *enode.Node
+}
+
+// Next moves to the next node whose record can be retrieved.
+func (it *resolveIterator) Next() bool {
+	for it.Iterator.Next() {
+		node, err := it.v4.RequestENR(it.Iterator.Node())
+		if err != nil {
+			continue
+		}
+		it.node = node
+		return true
+	}
+	return false
+}
+
+// Node returns the resolved node.
+func (it *resolveIterator) Node() *enode.Node {
+	return it.node
+}
+
+// appendMeshPeers appends the validator peers in the mesh to the subset of the
+// peers, the subset must be a prefix of the peers.
+func (h *handler) appendMeshPeers(subset []*ethPeer, peers []*ethPeer) []*ethPeer {
+	if h.mesh == nil {
+		return subset
+	}
+	// Cap the subset so appending doesn't overwrite the peers
+	subset = subset[:len(subset):len(subset)]
+	for _, peer := range peers[len(subset):] {
+		if h.mesh.contains(peer.ID()) {
+			subset = append(subset, peer)
+		}
+	}
+	return subset
+}
@@ -22,6 +22,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 )
@@ -77,7 +78,12 @@ func (p *snapPeer) info() *snapPeerInfo {
 // consPeerInfo represents a short summary of the `cons` sub-protocol metadata known
 // about a connected peer.
 type consPeerInfo struct {
-	Version uint `json:"version"` // cons protocol version negotiated
+	Version   uint              `json:"version"`   // cons protocol version negotiated
+	Score     int               `json:"score"`     // Reputation score of the peer
+	Drops     map[string]uint64 `json:"drops"`     // Dropped messages by reason
+	Justified *types.RangeEdge  `json:"justified"` // Latest justified block of the peer
+	Finalized *types.RangeEdge  `json:"finalized"` // Latest finalized block of the peer
+	Validator bool              `json:"validator"` // Whether the peer claims to be a validator
 }
 
 // consPeer is a wrapper around cons.Peer to maintain a few extra metadata.
@@ -87,7 +93,13 @@ type consPeer struct {
 
 // info gathers and returns some `cons` protocol metadata known about a peer.
 func (p *consPeer) info() *consPeerInfo {
+	justified, finalized, validator := p.Status()
 	return &consPeerInfo{
-		Version: p.Version(),
+		Version:   p.Version(),
+		Score:     p.Score(),
+		Drops:     p.Drops(),
+		Justified: justified,
+		Finalized: finalized,
+		Validator: validator,
 	}
 }
@@ -19,6 +19,7 @@ package eth
 import (
 	"errors"
 	"math/big"
+	"math/rand"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -268,6 +269,21 @@ func (ps *peerSet) snapLen() int {
 	return ps.snapPeers
 }
 
+// consLen returns the current number of peers in the set with the `cons`
+// extension.
+func (ps *peerSet) consLen() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	var n int
+	for _, p := range ps.peers {
+		if p.consExt != nil {
+			n++
+		}
+	}
+	return n
+}
+
 // peerWithHighestTD retrieves the known peer with the currently highest total
 // difficulty.
 func (ps *peerSet) peerWithHighestTD() *eth.Peer {
@@ -286,6 +302,23 @@ func (ps *peerSet) peerWithHighestTD() *eth.Peer {
 	return bestPeer
 }
 
+// randomPeer retrieves a random known peer, except the given ones.
+func (ps *peerSet) randomPeer(exclude map[string]struct{}) *eth.Peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	candidates := make([]*eth.Peer, 0, len(ps.peers))
+	for id, p := range ps.peers {
+		if _, ok := exclude[id]; !ok {
+			candidates = append(candidates, p.Peer)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
 // close disconnects all peers.
 func (ps *peerSet) close() {
 	ps.lock.Lock()
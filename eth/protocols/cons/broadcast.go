@@ -16,7 +16,28 @@
 
 package cons
 
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+	// maxAttestationAnnounces is the maximum number of attestation hashes to
+	// announce in a single message.
+	maxAttestationAnnounces = 256
+
+	// attestationAnnounceDelay is the time to collect the attestations before
+	// announcing them in a batch.
+	attestationAnnounceDelay = 100 * time.Millisecond
+)
+
 func (p *Peer) broadcastAttestationsLoop() {
+	if p.version >= cons2 {
+		p.announceAttestationsLoop()
+		return
+	}
 	for {
 		select {
 		case a := <-p.queuedAttestations:
@@ -48,3 +69,42 @@ func (p *Peer) broadcastJustifiedOrFinalizedBlockLoop() {
 		}
 	}
 }
+
+// announceAttestationsLoop collects the queued attestations and announces their
+// hashes in batches, the peer pulls the ones it doesn't know yet.
+func (p *Peer) announceAttestationsLoop() {
+	var (
+		queue []common.Hash
+		flush <-chan time.Time
+	)
+	announce := func() error {
+		hashes := queue
+		queue, flush = nil, nil
+		return p2p.Send(p.rw, NewAttestationHashesMsg, NewAttestationHashesPacket(hashes))
+	}
+	for {
+		select {
+		case a := <-p.queuedAttestations:
+			queue = append(queue, a.Hash())
+			if len(queue) >= maxAttestationAnnounces {
+				if err := announce(); err != nil {
+					p.Log().Trace(err.Error())
+					return
+				}
+			} else if flush == nil {
+				flush = time.After(attestationAnnounceDelay)
+			}
+
+		case <-flush:
+			count := len(queue)
+			if err := announce(); err != nil {
+				p.Log().Trace(err.Error())
+				return
+			}
+			p.Log().Trace("Announced attestations", "count", count)
+
+		case <-p.term:
+			return
+		}
+	}
+}
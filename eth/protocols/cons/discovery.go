@@ -17,6 +17,12 @@
 package cons
 
 import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -30,3 +36,68 @@ type enrEntry struct {
 func (e enrEntry) ENRKey() string {
 	return "cons"
 }
+
+// validatorEntry is the ENR entry which advertises the node of a validator. It's
+// signed by the validator key over the node ID, so the validators can find each
+// other and make sure a record is not forged by others.
+type validatorEntry struct {
+	Validator common.Address
+	Signature []byte
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e validatorEntry) ENRKey() string {
+	return "consv"
+}
+
+// validatorSignData returns the data signed by a validator to claim the node.
+func validatorSignData(id enode.ID) []byte {
+	return append([]byte("cons validator node"), id[:]...)
+}
+
+// NewValidatorEntry creates the ENR entry claiming the node of the given ID
+// belongs to the validator, sign signs the data with the validator key.
+func NewValidatorEntry(id enode.ID, validator common.Address, sign func(data []byte) ([]byte, error)) (enr.Entry, error) {
+	sig, err := sign(validatorSignData(id))
+	if err != nil {
+		return nil, err
+	}
+	// Some signers return the legacy recovery id
+	if len(sig) == crypto.SignatureLength && sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	entry := &validatorEntry{Validator: validator, Signature: sig}
+	if signer, err := entry.signer(id); err != nil || signer != validator {
+		return nil, errors.New("validator signature mismatch")
+	}
+	return entry, nil
+}
+
+// signer recovers the address signing the entry for the node.
+func (e *validatorEntry) signer(id enode.ID) (common.Address, error) {
+	if len(e.Signature) != crypto.SignatureLength {
+		return common.Address{}, errors.New("invalid signature length")
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(validatorSignData(id)), e.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// NodeValidator returns the validator advertised by the node record, the second
+// value is false if there is none or the signature doesn't match.
+func NodeValidator(n *enode.Node) (common.Address, bool) {
+	var entry validatorEntry
+	if n.Load(&entry) != nil {
+		return common.Address{}, false
+	}
+	signer, err := entry.signer(n.ID())
+	if err != nil || signer != entry.Validator {
+		return common.Address{}, false
+	}
+	return entry.Validator, true
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cons
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// signedNode creates a node record of the node key carrying the entry.
+func signedNode(t *testing.T, key *ecdsa.PrivateKey, entry enr.Entry) *enode.Node {
+	var r enr.Record
+	if entry != nil {
+		r.Set(entry)
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	return n
+}
+
+// Tests that the validator entries are verified against the node they're
+// advertised by.
+func TestValidatorEntry(t *testing.T) {
+	var (
+		valKey, _   = crypto.GenerateKey()
+		validator   = crypto.PubkeyToAddress(valKey.PublicKey)
+		nodeKey, _  = crypto.GenerateKey()
+		otherKey, _ = crypto.GenerateKey()
+		id          = enode.PubkeyToIDV4(&nodeKey.PublicKey)
+		sign        = func(data []byte) ([]byte, error) { return crypto.Sign(crypto.Keccak256(data), valKey) }
+	)
+	entry, err := NewValidatorEntry(id, validator, sign)
+	if err != nil {
+		t.Fatalf("failed to create validator entry: %v", err)
+	}
+	if val, ok := NodeValidator(signedNode(t, nodeKey, entry)); !ok || val != validator {
+		t.Errorf("validator mismatch: have %x (%v), want %x", val, ok, validator)
+	}
+	// The entry copied into another node record is rejected
+	if _, ok := NodeValidator(signedNode(t, otherKey, entry)); ok {
+		t.Errorf("forged validator entry accepted")
+	}
+	// The entry claiming another validator is rejected
+	forged := *entry.(*validatorEntry)
+	forged.Validator[0]++
+	if _, ok := NodeValidator(signedNode(t, nodeKey, &forged)); ok {
+		t.Errorf("validator entry with mismatching signer accepted")
+	}
+	if _, ok := NodeValidator(signedNode(t, nodeKey, nil)); ok {
+		t.Errorf("node without validator entry accepted")
+	}
+}
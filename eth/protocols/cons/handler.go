@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
@@ -48,6 +50,14 @@ const (
 	// If we spend too much time, then it's a fairly high chance of timing out
 	// at the remote side, which means all the work is in vain.
 	maxTrieNodeTimeSpent = 5 * time.Second
+
+	// maxAttestationLookups is the maximum number of attestations to serve by
+	// hash in a single request.
+	maxAttestationLookups = 256
+
+	// maxAttestationRange is the maximum number of blocks to serve the attestations
+	// of in a single request.
+	maxAttestationRange = 128
 )
 
 // Handler is a callback to invoke from an outside runner after the boilerplate
@@ -77,6 +87,17 @@ type Backend interface {
 	// AcceptAttestation retrieves whether attestation processing is enabled on the node
 	// or if inbound attestations should simply be dropped.
 	AcceptAttestation() bool
+
+	// BanPeer disconnects a misbehaving peer and rejects it for the given duration.
+	BanPeer(id string, duration time.Duration)
+
+	// PooledAttestation retrieves a recently accepted attestation by hash, to
+	// serve the peers pulling the announced ones.
+	PooledAttestation(hash common.Hash) *types.Attestation
+
+	// UnknownAttestations filters the announced attestation hashes down to the
+	// ones neither known nor already requested from another peer.
+	UnknownAttestations(hashes []common.Hash) []common.Hash
 }
 
 // MakeProtocols constructs the P2P protocol definitions for `cons`.
@@ -99,6 +120,10 @@ func MakeProtocols(backend Backend, dnsDisc enode.Iterator) []p2p.Protocol {
 				peer := newPeer(version, p, rw)
 				defer peer.Close()
 
+				if err := peer.Handshake(localStatus(backend.Chain(), version)); err != nil {
+					peer.Log().Debug("Cons handshake failed", "err", err)
+					return err
+				}
 				return backend.RunPeer(peer, func(peer *Peer) error {
 					return handle(backend, peer)
 				})
@@ -118,11 +143,20 @@ func MakeProtocols(backend Backend, dnsDisc enode.Iterator) []p2p.Protocol {
 
 // NodeInfo represents a short summary of the `cons` sub-protocol metadata
 // known about the host peer.
-type NodeInfo struct{}
+type NodeInfo struct {
+	Justified *types.RangeEdge `json:"justified"` // Latest justified block
+	Finalized *types.RangeEdge `json:"finalized"` // Latest finalized block
+	Validator bool             `json:"validator"` // Whether the node gives attestations
+}
 
 // nodeInfo retrieves some `cons` protocol metadata about the running host node.
 func nodeInfo(chain *core.BlockChain) *NodeInfo {
-	return &NodeInfo{}
+	status := localStatus(chain, ProtocolVersions[0])
+	return &NodeInfo{
+		Justified: status.Justified,
+		Finalized: status.Finalized,
+		Validator: status.Validator,
+	}
 }
 
 // handle is the callback invoked to manage the life cycle of a `cons` peer.
@@ -133,6 +167,12 @@ func handle(backend Backend, peer *Peer) error {
 			peer.Log().Error("Message handling failed in `cons`", "err", err)
 			return err
 		}
+		if peer.score.misbehaving() {
+			peer.Log().Warn("Banning misbehaving peer", "score", peer.Score(), "drops", peer.Drops())
+			bannedMeter.Mark(1)
+			backend.BanPeer(peer.ID(), banDuration)
+			return errPeerMisbehaving
+		}
 	}
 }
 
@@ -142,13 +182,25 @@ type Decoder interface {
 	Time() time.Time
 }
 
-var consHandle = map[uint64]msgHandler{
+var cons1Handlers = map[uint64]msgHandler{
 	NewAttestationMsg:               handleNewAttestation,
 	NewJustifiedOrFinalizedBlockMsg: handleNewJustifiedOrFinalizedBlock,
 	GetAttestationsMsg:              handleGetAttestations,
 	AttestationsMsg:                 handleAttestations,
 }
 
+var cons2Handlers = map[uint64]msgHandler{
+	NewAttestationMsg:               handleNewAttestation,
+	NewJustifiedOrFinalizedBlockMsg: handleNewJustifiedOrFinalizedBlock,
+	GetAttestationsMsg:              handleGetAttestations,
+	AttestationsMsg:                 handleAttestations,
+	NewAttestationHashesMsg:         handleNewAttestationHashes,
+	GetPooledAttestationsMsg:        handleGetPooledAttestations,
+	PooledAttestationsMsg:           handlePooledAttestations,
+	GetAttestationRangeMsg:          handleGetAttestationRange,
+	AttestationRangeMsg:             handleAttestationRange,
+}
+
 // handleMessage is invoked whenever an inbound message is received from a remote
 // peer. The remote connection is torn down upon returning any error.
 func handleMessage(backend Backend, peer *Peer) error {
@@ -158,12 +210,25 @@ func handleMessage(backend Backend, peer *Peer) error {
 		peer.Log().Error("ReadMsg error:", "err", err.Error())
 		return err
 	}
-	if msg.Size > maxMessageSize {
-		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	limit := uint32(maxMessageSize)
+	if peer.Version() >= cons2 {
+		limit = maxBatchMessageSize
+	}
+	if msg.Size > limit {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, limit)
 	}
 	defer msg.Discard()
 
-	var handlers = consHandle
+	// Drop the messages exceeding the rate limit without handling, the responses
+	// are only limited by their handlers if they don't answer our requests
+	if !responseMsgs[msg.Code] && !peer.score.allow(msg.Code) {
+		peer.score.drop(dropRateLimited)
+		return nil
+	}
+	var handlers = cons1Handlers
+	if peer.Version() >= cons2 {
+		handlers = cons2Handlers
+	}
 
 	// Track the amount of time it takes to serve the request and run the handler
 	if metrics.Enabled {
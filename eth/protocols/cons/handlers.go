@@ -19,36 +19,70 @@ package cons
 import (
 	"errors"
 	"fmt"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"math/big"
+	"math/rand"
 )
 
-// TODO In case of DDoS attack, the corresponding peer will be disconnected automatically
+// handleNewAttestation handles an attestation gossiped by the peer, the peer is
+// penalized for the useless ones, and will be disconnected once its score drops
+// to the bottom.
 func handleNewAttestation(backend Backend, msg Decoder, peer *Peer) error {
 	a := new(types.Attestation)
 	if err := msg.Decode(a); err != nil {
-		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		peer.Log().Debug("Dropping undecodable attestation", "err", err)
+		peer.score.drop(dropUndecodable)
+		return nil
 	}
-	if !peer.knownAttestations.Contains(a.Hash()) {
+	handleAttestation(backend, a, peer, backend.AcceptAttestation())
+	return nil
+}
+
+// handleAttestation processes an attestation received from the peer, and updates
+// the peer's score by the result if score is set. The scoring is skipped before
+// the sync completes and for the history attestations, which are checked against
+// a local chain possibly far from the peer's.
+func handleAttestation(backend Backend, a *types.Attestation, peer *Peer, score bool) {
+	// Sanity check before hashing, the hash relies on the range edges
+	if err := a.SanityCheck(); err != nil {
+		peer.Log().Debug("Dropping invalid attestation", "err", err)
+		if score {
+			peer.score.drop(dropInvalid)
+		}
+		return
+	}
+	known := peer.knownAttestations.Contains(a.Hash())
+	if !known {
 		peer.knownAttestations.Add(a.Hash())
 	}
-	err := backend.Chain().HandleAttestation(a)
-	if err != nil {
-		log.Warn(err.Error())
+	if err := backend.Chain().HandleAttestation(a); err != nil {
+		reason := attestationDropReason(err)
+		log.Debug("Dropping attestation", "peer", peer.ID(), "reason", reason, "err", err)
+		if score {
+			peer.score.drop(reason)
+		}
+		return
+	}
+	if score && !known {
+		peer.score.reward()
 	}
-	return nil
 }
 
 func handleNewJustifiedOrFinalizedBlock(backend Backend, msg Decoder, peer *Peer) error {
 	var bs types.BlockStatus
 	if err := msg.Decode(&bs); err != nil {
-		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		peer.Log().Debug("Dropping undecodable block status", "err", err)
+		peer.score.drop(dropUndecodable)
+		return nil
 	}
 	if bs.Status != types.BasJustified && bs.Status != types.BasFinalized {
 		return fmt.Errorf("status is error  %d", bs.Status)
 	}
+	peer.markFinality(&bs)
+
 	status, hash := backend.Chain().GetBlockStatusByNum(bs.BlockNumber.Uint64())
 	if status == types.BasUnknown { // not found
 		// need to request the current block
@@ -84,17 +118,140 @@ func handleGetAttestations(backend Backend, msg Decoder, peer *Peer) error {
 func handleAttestations(backend Backend, msg Decoder, peer *Peer) error {
 	var as []types.Attestation
 	if err := msg.Decode(&as); err != nil {
-		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+		peer.Log().Debug("Dropping undecodable attestations", "err", err)
+		peer.score.drop(dropUndecodable)
+		return nil
 	}
 	maxCount := backend.Chain().MaxValidators()
 	if len(as) > int(maxCount) {
 		return errors.New("the total number of attestations exceeds the maximum number of validators")
 	}
-	for _, a := range as {
-		if !peer.knownAttestations.Contains(a.Hash()) {
-			peer.knownAttestations.Add(a.Hash())
+	// The history attestations answer our own requests, they're never scored
+	for i := range as {
+		handleAttestation(backend, &as[i], peer, false)
+	}
+	return nil
+}
+
+// handleNewAttestationHashes handles a batch of attestation announcements, the
+// unknown ones are pulled from the announcing peer.
+func handleNewAttestationHashes(backend Backend, msg Decoder, peer *Peer) error {
+	// Attestations arriving before the sync completes are useless
+	if !backend.AcceptAttestation() {
+		return nil
+	}
+	var ann NewAttestationHashesPacket
+	if err := msg.Decode(&ann); err != nil {
+		peer.Log().Debug("Dropping undecodable attestation announcements", "err", err)
+		peer.score.drop(dropUndecodable)
+		return nil
+	}
+	if len(ann) > maxAttestationLookups {
+		return fmt.Errorf("%w: %d attestations announced", errBadRequest, len(ann))
+	}
+	peer.knownAttestations.Add(ann...)
+
+	unknown := backend.UnknownAttestations(ann)
+	if len(unknown) == 0 {
+		return nil
+	}
+	return peer.RequestAttestations(rand.Uint64(), unknown)
+}
+
+// handleGetPooledAttestations serves the attestations announced to the peer.
+func handleGetPooledAttestations(backend Backend, msg Decoder, peer *Peer) error {
+	var req GetPooledAttestationsPacket
+	if err := msg.Decode(&req); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	if len(req.Hashes) > maxAttestationLookups {
+		req.Hashes = req.Hashes[:maxAttestationLookups]
+	}
+	as := make([]*types.Attestation, 0, len(req.Hashes))
+	for _, hash := range req.Hashes {
+		if a := backend.PooledAttestation(hash); a != nil {
+			as = append(as, a)
 		}
-		_ = backend.Chain().HandleAttestation(a.DeepCopy())
+	}
+	return p2p.Send(peer.rw, PooledAttestationsMsg, &PooledAttestationsPacket{
+		ID:           req.ID,
+		Attestations: as,
+	})
+}
+
+// handlePooledAttestations handles the attestations pulled from the peer.
+func handlePooledAttestations(backend Backend, msg Decoder, peer *Peer) error {
+	var res PooledAttestationsPacket
+	if err := msg.Decode(&res); err != nil {
+		peer.Log().Debug("Dropping undecodable attestations", "err", err)
+		peer.score.drop(dropUndecodable)
+		return nil
+	}
+	requestTracker.Fulfil(peer.id, peer.version, PooledAttestationsMsg, res.ID)
+	if !peer.allowResponse(PooledAttestationsMsg, res.ID) {
+		return nil
+	}
+
+	if len(res.Attestations) > maxAttestationLookups {
+		return fmt.Errorf("%w: %d attestations delivered", errBadRequest, len(res.Attestations))
+	}
+	score := backend.AcceptAttestation()
+	for _, a := range res.Attestations {
+		handleAttestation(backend, a, peer, score)
 	}
 	return nil
 }
+
+// handleGetAttestationRange serves the attestations of a range of canonical
+// blocks, the blocks without attestations known are skipped.
+func handleGetAttestationRange(backend Backend, msg Decoder, peer *Peer) error {
+	var req GetAttestationRangePacket
+	if err := msg.Decode(&req); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	if req.Amount > maxAttestationRange {
+		req.Amount = maxAttestationRange
+	}
+	var (
+		chain  = backend.Chain()
+		head   = chain.CurrentBlock().NumberU64()
+		blocks []*BlockAttestations
+	)
+	for number := req.Origin; number < req.Origin+req.Amount && number <= head; number++ {
+		hash := chain.GetCanonicalHash(number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		as, err := chain.GetHistoryAttestations(new(big.Int).SetUint64(number), hash)
+		if err != nil || len(as) == 0 {
+			continue
+		}
+		blocks = append(blocks, &BlockAttestations{
+			Number:       number,
+			Hash:         hash,
+			Attestations: as,
+		})
+	}
+	return p2p.Send(peer.rw, AttestationRangeMsg, &AttestationRangePacket{
+		ID:     req.ID,
+		Blocks: blocks,
+	})
+}
+
+// handleAttestationRange delivers the attestations of a range of blocks to the
+// backend, they're requested by the syncer.
+func handleAttestationRange(backend Backend, msg Decoder, peer *Peer) error {
+	res := new(AttestationRangePacket)
+	if err := msg.Decode(res); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	requestTracker.Fulfil(peer.id, peer.version, AttestationRangeMsg, res.ID)
+	if !peer.allowResponse(AttestationRangeMsg, res.ID) {
+		return nil
+	}
+
+	if len(res.Blocks) > maxAttestationRange {
+		return fmt.Errorf("%w: %d blocks delivered", errBadRequest, len(res.Blocks))
+	}
+	return backend.Handle(peer, res)
+}
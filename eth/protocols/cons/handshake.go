@@ -0,0 +1,102 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cons
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+const (
+	// handshakeTimeout is the maximum allowed time for the `cons` handshake to
+	// complete before dropping the connection.
+	handshakeTimeout = 5 * time.Second
+)
+
+// localStatus assembles the finality status of the local node.
+func localStatus(chain *core.BlockChain, version uint) *StatusPacket {
+	justified, finalized := chain.FinalityEdges()
+	return &StatusPacket{
+		ProtocolVersion: uint32(version),
+		Justified:       justified,
+		Finalized:       finalized,
+		Validator:       chain.ChaosEngine != nil && chain.ChaosEngine.CurrentValidator() != (common.Address{}),
+	}
+}
+
+// Handshake executes the `cons` protocol handshake, exchanging the finality
+// status. Only `cons2` and later versions have a handshake.
+func (p *Peer) Handshake(status *StatusPacket) error {
+	if p.version < cons2 {
+		return nil
+	}
+	// Send out own handshake in a new thread
+	errc := make(chan error, 2)
+
+	var remote StatusPacket // safe to read after two values have been received from errc
+
+	go func() {
+		errc <- p2p.Send(p.rw, StatusMsg, status)
+	}()
+	go func() {
+		errc <- p.readStatus(&remote)
+	}()
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		}
+	}
+	p.setStatus(remote.Justified, remote.Finalized, remote.Validator)
+	return nil
+}
+
+// readStatus reads the remote handshake message.
+func (p *Peer) readStatus(status *StatusPacket) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Code != StatusMsg {
+		return fmt.Errorf("%w: first msg has code %x (!= %x)", errNoStatusMsg, msg.Code, StatusMsg)
+	}
+	if msg.Size > maxMessageSize {
+		return fmt.Errorf("%w: %v > %v", errMsgTooLarge, msg.Size, maxMessageSize)
+	}
+	if err := msg.Decode(status); err != nil {
+		return fmt.Errorf("%w: message %v: %v", errDecode, msg, err)
+	}
+	if uint(status.ProtocolVersion) != p.version {
+		return fmt.Errorf("%w: %d (!= %d)", errVersionMismatch, status.ProtocolVersion, p.version)
+	}
+	if status.Justified == nil || status.Justified.Number == nil || status.Finalized == nil || status.Finalized.Number == nil {
+		return fmt.Errorf("%w: missing finality edges", errDecode)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cons
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Tests that the finality status is exchanged in the handshake, and the failures
+// are detected and reported correctly.
+func TestHandshake(t *testing.T) {
+	var (
+		justified = &types.RangeEdge{Number: big.NewInt(10), Hash: common.Hash{0x0a}}
+		finalized = &types.RangeEdge{Number: big.NewInt(9), Hash: common.Hash{0x09}}
+		local     = &StatusPacket{ProtocolVersion: cons2, Justified: justified, Finalized: finalized}
+	)
+	tests := []struct {
+		code uint64
+		data interface{}
+		want error
+	}{
+		{
+			code: NewAttestationHashesMsg, data: NewAttestationHashesPacket{},
+			want: errNoStatusMsg,
+		},
+		{
+			code: StatusMsg, data: &StatusPacket{ProtocolVersion: cons1, Justified: justified, Finalized: finalized},
+			want: errVersionMismatch,
+		},
+		{
+			code: StatusMsg, data: &StatusPacket{ProtocolVersion: cons2, Justified: justified, Finalized: finalized, Validator: true},
+			want: nil,
+		},
+	}
+	for i, test := range tests {
+		app, net := p2p.MsgPipe()
+
+		peer := newPeer(cons2, p2p.NewPeer(enode.ID{}, "peer", nil), net)
+
+		go p2p.Send(app, test.code, test.data)
+		go p2p.ExpectMsg(app, StatusMsg, local)
+
+		err := peer.Handshake(local)
+		if !errors.Is(err, test.want) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.want)
+		}
+		if err == nil {
+			j, f, validator := peer.Status()
+			if j.Hash != justified.Hash || f.Hash != finalized.Hash || !validator {
+				t.Errorf("test %d: status mismatch: have %v %v %v", i, j, f, validator)
+			}
+		}
+		peer.Close()
+		app.Close()
+		net.Close()
+	}
+}
+
+// Tests that the peer's finality status only moves forward on announcements.
+func TestMarkFinality(t *testing.T) {
+	_, net := p2p.MsgPipe()
+	defer net.Close()
+
+	peer := newPeer(cons2, p2p.NewPeer(enode.ID{}, "peer", nil), net)
+	defer peer.Close()
+
+	peer.markFinality(&types.BlockStatus{BlockNumber: big.NewInt(5), Hash: common.Hash{5}, Status: types.BasFinalized})
+	peer.markFinality(&types.BlockStatus{BlockNumber: big.NewInt(7), Hash: common.Hash{7}, Status: types.BasJustified})
+	peer.markFinality(&types.BlockStatus{BlockNumber: big.NewInt(3), Hash: common.Hash{3}, Status: types.BasFinalized})
+
+	justified, finalized, _ := peer.Status()
+	if justified.Number.Uint64() != 7 {
+		t.Errorf("justified mismatch: have %d, want %d", justified.Number, 7)
+	}
+	if finalized.Number.Uint64() != 5 {
+		t.Errorf("finalized mismatch: have %d, want %d", finalized.Number, 5)
+	}
+}
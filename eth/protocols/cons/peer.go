@@ -17,6 +17,9 @@
 package cons
 
 import (
+	"math/big"
+	"sync"
+
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -47,6 +50,15 @@ type Peer struct {
 	knownJustifiedOrFinalizedBlock  *knownCache
 	queuedJustifiedOrFinalizedBlock chan *types.BlockStatus
 
+	score    *peerScore                 // Reputation score and rate limits of the inbound messages
+	requests map[uint64]*pendingRequest // Requests sent to the peer and not yet answered
+	reqLock  sync.Mutex                 // Mutex protecting the pending requests
+
+	justified *types.RangeEdge // Latest justified block of the peer (cons2 handshake or announcements)
+	finalized *types.RangeEdge // Latest finalized block of the peer (cons2 handshake or announcements)
+	validator bool             // Whether the peer claims to be a validator
+	lock      sync.RWMutex     // Mutex protecting the finality status
+
 	term chan struct{} // Termination channel to stop the broadcasters
 }
 
@@ -64,6 +76,10 @@ func newPeer(version uint, p *p2p.Peer, rw p2p.MsgReadWriter) *Peer {
 		queuedAttestations:              make(chan *types.Attestation, maxQueuedAttestations),
 		knownJustifiedOrFinalizedBlock:  newKnownCache(maxKnownJustifiedOrFinalizedBlock),
 		queuedJustifiedOrFinalizedBlock: make(chan *types.BlockStatus, maxQueuedJustifiedOrFinalizedBlock),
+		score:                           newPeerScore(),
+		requests:                        make(map[uint64]*pendingRequest),
+		justified:                       &types.RangeEdge{Number: new(big.Int)},
+		finalized:                       &types.RangeEdge{Number: new(big.Int)},
 		term:                            make(chan struct{}),
 	}
 	// Start up all the broadcasters
@@ -94,6 +110,38 @@ func (p *Peer) Log() log.Logger {
 	return p.logger
 }
 
+// Status retrieves the latest justified and finalized blocks of the peer, and
+// whether it's a validator.
+func (p *Peer) Status() (justified *types.RangeEdge, finalized *types.RangeEdge, validator bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.justified, p.finalized, p.validator
+}
+
+// setStatus updates the finality status of the peer.
+func (p *Peer) setStatus(justified, finalized *types.RangeEdge, validator bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.justified, p.finalized, p.validator = justified, finalized, validator
+}
+
+// markFinality updates the finality status of the peer by a block status it
+// announced, the status never moves backward.
+func (p *Peer) markFinality(bs *types.BlockStatus) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	edge := &types.RangeEdge{Number: new(big.Int).Set(bs.BlockNumber), Hash: bs.Hash}
+	if bs.BlockNumber.Cmp(p.justified.Number) > 0 {
+		p.justified = edge
+	}
+	if bs.Status == types.BasFinalized && bs.BlockNumber.Cmp(p.finalized.Number) > 0 {
+		p.finalized = edge
+	}
+}
+
 // max is a helper function which returns the larger of the two given integers.
 func max(a, b int) int {
 	if a > b {
@@ -185,3 +233,29 @@ func (p *Peer) AsyncSendNewJustifiedOrFinalizedBlock(bs *types.BlockStatus) {
 			bs.BlockNumber.Uint64(), "hash", bs.Hash)
 	}
 }
+
+// RequestAttestations fetches a batch of announced attestations from the peer.
+func (p *Peer) RequestAttestations(id uint64, hashes []common.Hash) error {
+	p.Log().Trace("Fetching batch of attestations", "reqid", id, "count", len(hashes))
+
+	requestTracker.Track(p.id, p.version, GetPooledAttestationsMsg, PooledAttestationsMsg, id)
+	p.trackRequest(id, PooledAttestationsMsg)
+	return p2p.Send(p.rw, GetPooledAttestationsMsg, &GetPooledAttestationsPacket{
+		ID:     id,
+		Hashes: hashes,
+	})
+}
+
+// RequestAttestationRange fetches the attestations of a range of canonical blocks
+// from the peer.
+func (p *Peer) RequestAttestationRange(id uint64, origin, amount uint64) error {
+	p.Log().Trace("Fetching range of attestations", "reqid", id, "origin", origin, "amount", amount)
+
+	requestTracker.Track(p.id, p.version, GetAttestationRangeMsg, AttestationRangeMsg, id)
+	p.trackRequest(id, AttestationRangeMsg)
+	return p2p.Send(p.rw, GetAttestationRangeMsg, &GetAttestationRangePacket{
+		ID:     id,
+		Origin: origin,
+		Amount: amount,
+	})
+}
@@ -2,6 +2,7 @@ package cons
 
 import (
 	"errors"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"math/big"
 )
@@ -9,6 +10,7 @@ import (
 // Constants to match up protocol versions and messages
 const (
 	cons1 = 1
+	cons2 = 2
 )
 
 // ProtocolName is the official short name of the `cons` protocol used during
@@ -17,31 +19,45 @@ const ProtocolName = "cons"
 
 // ProtocolVersions are the supported versions of the `cons` protocol (first
 // is primary).
-var ProtocolVersions = []uint{cons1}
+var ProtocolVersions = []uint{cons2, cons1}
 
 // protocolLengths are the number of implemented message corresponding to
 // different protocol versions.
 // The length here refers to the code of the message, or the largest type, rather than the length occupied by the data of the message
 // Specific view code p2p/peer.go 「msg.Code >= rw.Length」
 // If you need to support new types, remember to increase this value
-var protocolLengths = map[uint]uint64{cons1: 4}
+var protocolLengths = map[uint]uint64{cons1: 4, cons2: 10}
 
 // maxMessageSize is the maximum cap on the size of a protocol message.
 // A single attestation packet is about 110 bytes.
 const maxMessageSize = 8 * 1024
 
+// maxBatchMessageSize is the maximum cap on the size of a `cons2` message, the
+// responses carry the attestations of many blocks.
+const maxBatchMessageSize = 2 * 1024 * 1024
+
 const (
 	NewAttestationMsg               = 0x00 // A single attestation of a block
 	NewJustifiedOrFinalizedBlockMsg = 0x01 // The current node tells other nodes that it has a block with state Justified or Finalized
 	GetAttestationsMsg              = 0x02 // Request to get all attestations of a given block
 	AttestationsMsg                 = 0x03 // Response of the GetAttestationsMsg
+
+	// Protocol messages introduced in cons2
+	StatusMsg                = 0x04 // Handshake of the finality status
+	NewAttestationHashesMsg  = 0x05 // Batched announcement of the attestation hashes
+	GetPooledAttestationsMsg = 0x06 // Request to get the announced attestations
+	PooledAttestationsMsg    = 0x07 // Response of the GetPooledAttestationsMsg
+	GetAttestationRangeMsg   = 0x08 // Request to get the attestations of a range of blocks
+	AttestationRangeMsg      = 0x09 // Response of the GetAttestationRangeMsg
 )
 
 var (
-	errMsgTooLarge    = errors.New("message too long")
-	errDecode         = errors.New("invalid message")
-	errInvalidMsgCode = errors.New("invalid message code")
-	errBadRequest     = errors.New("bad request")
+	errMsgTooLarge     = errors.New("message too long")
+	errDecode          = errors.New("invalid message")
+	errInvalidMsgCode  = errors.New("invalid message code")
+	errBadRequest      = errors.New("bad request")
+	errNoStatusMsg     = errors.New("no status message")
+	errVersionMismatch = errors.New("protocol version mismatch")
 )
 
 // Packet represents a p2p message in the `cons` protocol.
@@ -61,3 +77,66 @@ type NewAttestationPacket struct {
 
 func (*NewAttestationPacket) Name() string { return "NewAttestation" }
 func (*NewAttestationPacket) Kind() byte   { return NewAttestationMsg }
+
+// StatusPacket is the network packet for the finality status handshake of `cons2`.
+type StatusPacket struct {
+	ProtocolVersion uint32
+	Justified       *types.RangeEdge // Latest justified (or finalized) block
+	Finalized       *types.RangeEdge // Latest finalized block
+	Validator       bool             // Whether the node is a validator giving attestations
+}
+
+// NewAttestationHashesPacket is the network packet for the attestation announcements.
+type NewAttestationHashesPacket []common.Hash
+
+// GetPooledAttestationsPacket represents an attestation query by hash.
+type GetPooledAttestationsPacket struct {
+	ID     uint64        // Request ID to match up responses with
+	Hashes []common.Hash // Attestation hashes to retrieve
+}
+
+// PooledAttestationsPacket is the network packet for the attestations queried by hash.
+type PooledAttestationsPacket struct {
+	ID           uint64               // ID of the request this is a response for
+	Attestations []*types.Attestation // Attestations known of the requested hashes
+}
+
+// GetAttestationRangePacket represents an attestation query of a range of
+// canonical blocks.
+type GetAttestationRangePacket struct {
+	ID     uint64 // Request ID to match up responses with
+	Origin uint64 // Number of the first block to retrieve
+	Amount uint64 // Maximum number of blocks to retrieve
+}
+
+// BlockAttestations is the attestations of a single block.
+type BlockAttestations struct {
+	Number       uint64
+	Hash         common.Hash
+	Attestations []*types.Attestation
+}
+
+// AttestationRangePacket is the network packet for the attestations of a range
+// of blocks, the blocks without attestations known are omitted.
+type AttestationRangePacket struct {
+	ID     uint64               // ID of the request this is a response for
+	Blocks []*BlockAttestations // Attestations of the blocks
+}
+
+func (*StatusPacket) Name() string { return "Status" }
+func (*StatusPacket) Kind() byte   { return StatusMsg }
+
+func (*NewAttestationHashesPacket) Name() string { return "NewAttestationHashes" }
+func (*NewAttestationHashesPacket) Kind() byte   { return NewAttestationHashesMsg }
+
+func (*GetPooledAttestationsPacket) Name() string { return "GetPooledAttestations" }
+func (*GetPooledAttestationsPacket) Kind() byte   { return GetPooledAttestationsMsg }
+
+func (*PooledAttestationsPacket) Name() string { return "PooledAttestations" }
+func (*PooledAttestationsPacket) Kind() byte   { return PooledAttestationsMsg }
+
+func (*GetAttestationRangePacket) Name() string { return "GetAttestationRange" }
+func (*GetAttestationRangePacket) Kind() byte   { return GetAttestationRangeMsg }
+
+func (*AttestationRangePacket) Name() string { return "AttestationRange" }
+func (*AttestationRangePacket) Kind() byte   { return AttestationRangeMsg }
@@ -0,0 +1,194 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cons
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/metrics"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxScore is the reputation score of a new peer, well behaving peers can't
+	// accumulate more than this.
+	maxScore = 100
+
+	// disconnectScore is the reputation score under which the peer is disconnected
+	// and banned for banDuration.
+	disconnectScore = 0
+
+	// banDuration is how long a misbehaving peer is not allowed to reconnect.
+	banDuration = 30 * time.Minute
+)
+
+// Reasons of dropping a message, also used as the keys of the drop counters.
+const (
+	dropRateLimited  = "ratelimited"
+	dropUndecodable  = "undecodable"
+	dropInvalid      = "invalid"
+	dropSignature    = "signature"
+	dropNonValidator = "nonvalidator"
+	dropOutOfRange   = "outofrange"
+	dropMismatch     = "mismatch"
+	dropRejected     = "rejected"
+)
+
+// penalties are the reputation score deducted for each drop reason. Only the
+// provably bad messages are penalized, the attestations out of the local range,
+// mismatching the local chain or rejected otherwise may be honest ones from a
+// peer with a different view, they are counted but cost no score.
+var penalties = map[string]int{
+	dropRateLimited:  1,
+	dropUndecodable:  20,
+	dropInvalid:      20,
+	dropSignature:    20,
+	dropNonValidator: 10,
+}
+
+// messageLimit is the token bucket parameters of a message code.
+type messageLimit struct {
+	rate  rate.Limit // Messages allowed per second
+	burst int        // Max messages allowed at once
+}
+
+// messageLimits are the rate limits of the inbound messages. A validator gives an
+// attestation per block, so the limits are generous for a few dozens of validators.
+var messageLimits = map[uint64]messageLimit{
+	NewAttestationMsg:               {rate: 100, burst: 200},
+	NewJustifiedOrFinalizedBlockMsg: {rate: 10, burst: 20},
+	GetAttestationsMsg:              {rate: 10, burst: 20},
+	AttestationsMsg:                 {rate: 10, burst: 20},
+	NewAttestationHashesMsg:         {rate: 20, burst: 40},
+	GetPooledAttestationsMsg:        {rate: 20, burst: 40},
+	PooledAttestationsMsg:           {rate: 20, burst: 40},
+	GetAttestationRangeMsg:          {rate: 5, burst: 10},
+	AttestationRangeMsg:             {rate: 5, burst: 10},
+}
+
+// responseMsgs are the messages answering the requests, they are rate limited
+// only if unsolicited.
+var responseMsgs = map[uint64]bool{
+	PooledAttestationsMsg: true,
+	AttestationRangeMsg:   true,
+}
+
+var (
+	errPeerMisbehaving = errors.New("peer misbehaving")
+
+	droppedMeter = metrics.NewRegisteredMeter("cons/dropped", nil)
+	bannedMeter  = metrics.NewRegisteredMeter("cons/banned", nil)
+)
+
+// peerScore tracks the reputation score, the rate limits and the dropped messages
+// of a `cons` peer.
+type peerScore struct {
+	score    int
+	limiters map[uint64]*rate.Limiter
+	drops    map[string]uint64
+	lock     sync.Mutex
+}
+
+func newPeerScore() *peerScore {
+	limiters := make(map[uint64]*rate.Limiter, len(messageLimits))
+	for code, limit := range messageLimits {
+		limiters[code] = rate.NewLimiter(limit.rate, limit.burst)
+	}
+	return &peerScore{
+		score:    maxScore,
+		limiters: limiters,
+		drops:    make(map[string]uint64),
+	}
+}
+
+// allow consumes a token of the message code, and reports whether the message
+// is within the rate limit.
+func (s *peerScore) allow(code uint64) bool {
+	if limiter, ok := s.limiters[code]; ok {
+		return limiter.Allow()
+	}
+	return true
+}
+
+// drop records a dropped message and deducts the score by the reason.
+func (s *peerScore) drop(reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.drops[reason]++
+	s.score -= penalties[reason]
+	droppedMeter.Mark(1)
+}
+
+// reward raises the score for a useful message.
+func (s *peerScore) reward() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.score < maxScore {
+		s.score++
+	}
+}
+
+// misbehaving reports whether the peer should be disconnected.
+func (s *peerScore) misbehaving() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.score <= disconnectScore
+}
+
+// Score returns the current reputation score of the peer.
+func (p *Peer) Score() int {
+	p.score.lock.Lock()
+	defer p.score.lock.Unlock()
+
+	return p.score.score
+}
+
+// Drops returns the counters of the dropped messages by reason.
+func (p *Peer) Drops() map[string]uint64 {
+	p.score.lock.Lock()
+	defer p.score.lock.Unlock()
+
+	drops := make(map[string]uint64, len(p.score.drops))
+	for reason, n := range p.score.drops {
+		drops[reason] = n
+	}
+	return drops
+}
+
+// attestationDropReason classifies the error of handling an attestation.
+func attestationDropReason(err error) string {
+	switch {
+	case errors.Is(err, core.ErrAttestationSignature):
+		return dropSignature
+	case errors.Is(err, core.ErrAttestationNonValidator):
+		return dropNonValidator
+	case errors.Is(err, core.ErrAttestationOutOfRange):
+		return dropOutOfRange
+	case errors.Is(err, core.ErrAttestationMismatch):
+		return dropMismatch
+	case errors.Is(err, core.ErrAttestationInvalid):
+		return dropInvalid
+	default:
+		return dropRejected
+	}
+}
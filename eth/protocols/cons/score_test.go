@@ -0,0 +1,186 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cons
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that the peer is misbehaving after enough penalties, and the rewards
+// can't raise the score over the max.
+func TestPeerScore(t *testing.T) {
+	s := newPeerScore()
+	s.reward()
+	if s.score != maxScore {
+		t.Fatalf("score mismatch: have %d, want %d", s.score, maxScore)
+	}
+	for i := 0; i < maxScore/penalties[dropSignature]; i++ {
+		if s.misbehaving() {
+			t.Fatalf("peer misbehaving after %d penalties", i)
+		}
+		s.drop(dropSignature)
+	}
+	if !s.misbehaving() {
+		t.Fatalf("peer not misbehaving with score %d", s.score)
+	}
+	if s.drops[dropSignature] != uint64(maxScore/penalties[dropSignature]) {
+		t.Fatalf("drop counter mismatch: have %d, want %d", s.drops[dropSignature], maxScore/penalties[dropSignature])
+	}
+}
+
+// Tests that the messages exceeding the burst are rate limited.
+func TestPeerScoreRateLimit(t *testing.T) {
+	s := newPeerScore()
+	limit := messageLimits[GetAttestationsMsg]
+	for i := 0; i < limit.burst; i++ {
+		if !s.allow(GetAttestationsMsg) {
+			t.Fatalf("message %d rate limited within burst", i)
+		}
+	}
+	if s.allow(GetAttestationsMsg) {
+		t.Fatalf("message beyond burst not rate limited")
+	}
+	// Other message codes have their own buckets
+	if !s.allow(NewAttestationMsg) {
+		t.Fatalf("message of other code rate limited")
+	}
+}
+
+func TestAttestationDropReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: bad v", core.ErrAttestationSignature), dropSignature},
+		{core.ErrAttestationNonValidator, dropNonValidator},
+		{fmt.Errorf("%w: target 1, current 100", core.ErrAttestationOutOfRange), dropOutOfRange},
+		{core.ErrAttestationMismatch, dropMismatch},
+		{fmt.Errorf("%w: invalid", core.ErrAttestationInvalid), dropInvalid},
+		{errors.New("not in the same branch"), dropRejected},
+	}
+	for i, tt := range tests {
+		if got := attestationDropReason(tt.err); got != tt.want {
+			t.Errorf("test %d: reason mismatch: have %s, want %s", i, got, tt.want)
+		}
+	}
+}
+
+// Tests that the attestations possibly sent by honest peers are counted, but
+// cost no score.
+func TestPeerScoreHonestDrops(t *testing.T) {
+	s := newPeerScore()
+	for _, reason := range []string{dropOutOfRange, dropMismatch, dropRejected} {
+		s.drop(reason)
+		if s.drops[reason] != 1 {
+			t.Errorf("drop counter of %s mismatch: have %d, want %d", reason, s.drops[reason], 1)
+		}
+	}
+	if s.score != maxScore {
+		t.Fatalf("score mismatch: have %d, want %d", s.score, maxScore)
+	}
+}
+
+// scoreTestBackend is a `cons` backend only telling whether the attestations are
+// accepted.
+type scoreTestBackend struct {
+	Backend
+	accept bool
+}
+
+func (b *scoreTestBackend) AcceptAttestation() bool { return b.accept }
+
+// Tests that the invalid attestations are only penalized once the attestations
+// are accepted.
+func TestAttestationScoring(t *testing.T) {
+	invalid := &types.Attestation{
+		SourceRangeEdge: &types.RangeEdge{Number: big.NewInt(2), Hash: common.Hash{0x02}},
+		TargetRangeEdge: &types.RangeEdge{Number: big.NewInt(1), Hash: common.Hash{0x01}},
+		R:               big.NewInt(1),
+		S:               big.NewInt(1),
+	}
+	tests := []struct {
+		code    uint64
+		data    interface{}
+		handler msgHandler
+		accept  bool
+		penalty int
+	}{
+		{NewAttestationMsg, invalid, handleNewAttestation, false, 0},
+		{NewAttestationMsg, invalid, handleNewAttestation, true, penalties[dropInvalid]},
+		{PooledAttestationsMsg, &PooledAttestationsPacket{Attestations: []*types.Attestation{invalid}}, handlePooledAttestations, false, 0},
+		{PooledAttestationsMsg, &PooledAttestationsPacket{Attestations: []*types.Attestation{invalid}}, handlePooledAttestations, true, penalties[dropInvalid]},
+	}
+	for i, tt := range tests {
+		enc, err := rlp.EncodeToBytes(tt.data)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode message: %v", i, err)
+		}
+		msg := p2p.Msg{Code: tt.code, Size: uint32(len(enc)), Payload: bytes.NewReader(enc), ReceivedAt: time.Now()}
+		peer := newPeer(cons2, p2p.NewPeer(enode.ID{}, "peer", nil), nil)
+
+		if err := tt.handler(&scoreTestBackend{accept: tt.accept}, msg, peer); err != nil {
+			t.Fatalf("test %d: failed to handle message: %v", i, err)
+		}
+		if have := maxScore - peer.Score(); have != tt.penalty {
+			t.Errorf("test %d: penalty mismatch: have %d, want %d", i, have, tt.penalty)
+		}
+		peer.Close()
+	}
+}
+
+// Tests that the responses answering our requests are exempt from the rate
+// limits, while the unsolicited ones are limited.
+func TestPeerScoreResponses(t *testing.T) {
+	peer := newPeer(cons2, p2p.NewPeer(enode.ID{}, "peer", nil), nil)
+	defer peer.Close()
+
+	limit := messageLimits[PooledAttestationsMsg]
+	for i := 0; i < limit.burst; i++ {
+		if !peer.allowResponse(PooledAttestationsMsg, uint64(i)) {
+			t.Fatalf("unsolicited response %d rate limited within burst", i)
+		}
+	}
+	if peer.allowResponse(PooledAttestationsMsg, 0) {
+		t.Fatalf("unsolicited response beyond burst not rate limited")
+	}
+	peer.trackRequest(1, PooledAttestationsMsg)
+	peer.trackRequest(2, AttestationRangeMsg)
+	if !peer.allowResponse(PooledAttestationsMsg, 1) {
+		t.Fatalf("solicited response rate limited")
+	}
+	if peer.allowResponse(PooledAttestationsMsg, 1) {
+		t.Fatalf("repeated response not rate limited")
+	}
+	if peer.allowResponse(PooledAttestationsMsg, 2) {
+		t.Fatalf("response of mismatching code not rate limited")
+	}
+	if drops := peer.Drops()[dropRateLimited]; drops != 3 {
+		t.Fatalf("rate limited drops mismatch: have %d, want %d", drops, 3)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package cons
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// rangeRequestTimeout is the maximum time to wait for an attestation range
+	// response before trying another peer.
+	rangeRequestTimeout = 10 * time.Second
+
+	// headerRecheckInterval is the time to wait for the headers to be imported
+	// before requesting the attestations of them.
+	headerRecheckInterval = 500 * time.Millisecond
+)
+
+var (
+	// ErrCancelled is returned from the syncer if the operation was cancelled.
+	ErrCancelled = errors.New("sync cancelled")
+
+	errRangeTimeout = errors.New("attestation range request timed out")
+)
+
+// rangeRequest tracks a pending attestation range request.
+type rangeRequest struct {
+	peer    string                    // Peer to which this request is assigned
+	deliver chan []*BlockAttestations // Channel to deliver the response on
+}
+
+// Syncer retrieves the attestations of the blocks imported by the downloader, so
+// the finality of the synced chain is restored without waiting for the network
+// to gossip them again. Attestations are only kept for a while by the peers, so
+// the sync is done on a best effort basis.
+type Syncer struct {
+	chain *core.BlockChain
+
+	peers map[string]*Peer         // Peers supporting the range requests
+	reqs  map[uint64]*rangeRequest // Pending range requests
+	lock  sync.RWMutex
+}
+
+// NewSyncer creates a new finality syncer importing into the given chain.
+func NewSyncer(chain *core.BlockChain) *Syncer {
+	return &Syncer{
+		chain: chain,
+		peers: make(map[string]*Peer),
+		reqs:  make(map[uint64]*rangeRequest),
+	}
+}
+
+// Register injects a new data source into the syncer's peerset. The peers not
+// supporting the range requests are ignored.
+func (s *Syncer) Register(peer *Peer) error {
+	if peer.Version() < cons2 {
+		return nil
+	}
+	id := peer.ID()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.peers[id]; ok {
+		log.Error("Cons peer already registered", "id", id)
+		return errors.New("already registered")
+	}
+	s.peers[id] = peer
+	return nil
+}
+
+// Unregister removes a data source from the syncer's peerset.
+func (s *Syncer) Unregister(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.peers, id)
+	return nil
+}
+
+// Sync retrieves the attestations of the blocks in the [from, to] range as their
+// headers get imported. It returns when the range is done, there's no peer left
+// to retrieve from, or cancel is closed.
+func (s *Syncer) Sync(from, to uint64, cancel chan struct{}) error {
+	failed := make(map[string]struct{})
+	for next := from; next <= to; {
+		head := s.chain.CurrentHeader().Number.Uint64()
+		if head < next {
+			select {
+			case <-time.After(headerRecheckInterval):
+				continue
+			case <-cancel:
+				return ErrCancelled
+			}
+		}
+		peer := s.pickPeer(failed)
+		if peer == nil {
+			log.Debug("No peers to sync finality from", "next", next, "to", to)
+			return nil
+		}
+		last := head
+		if last > to {
+			last = to
+		}
+		if last-next+1 > maxAttestationRange {
+			last = next + maxAttestationRange - 1
+		}
+		blocks, err := s.request(peer, next, last-next+1, cancel)
+		if err == ErrCancelled {
+			return err
+		}
+		if err != nil {
+			peer.Log().Debug("Failed to retrieve attestation range", "from", next, "to", last, "err", err)
+			failed[peer.ID()] = struct{}{}
+			continue
+		}
+		s.process(peer, next, last, blocks)
+		next = last + 1
+	}
+	return nil
+}
+
+// pickPeer returns the peer with the highest justified block, except the ones
+// failed before.
+func (s *Syncer) pickPeer(failed map[string]struct{}) *Peer {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var (
+		best   *Peer
+		number uint64
+	)
+	for id, peer := range s.peers {
+		if _, ok := failed[id]; ok {
+			continue
+		}
+		justified, _, _ := peer.Status()
+		if best == nil || justified.Number.Uint64() > number {
+			best, number = peer, justified.Number.Uint64()
+		}
+	}
+	return best
+}
+
+// request sends an attestation range request to the peer and waits for the response.
+func (s *Syncer) request(peer *Peer, origin, amount uint64, cancel chan struct{}) ([]*BlockAttestations, error) {
+	req := &rangeRequest{
+		peer:    peer.ID(),
+		deliver: make(chan []*BlockAttestations, 1),
+	}
+	s.lock.Lock()
+	var id uint64
+	for {
+		id = rand.Uint64()
+		if _, ok := s.reqs[id]; !ok {
+			break
+		}
+	}
+	s.reqs[id] = req
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.reqs, id)
+		s.lock.Unlock()
+	}()
+	if err := peer.RequestAttestationRange(id, origin, amount); err != nil {
+		return nil, err
+	}
+	timeout := time.NewTimer(rangeRequestTimeout)
+	defer timeout.Stop()
+
+	select {
+	case blocks := <-req.deliver:
+		return blocks, nil
+	case <-timeout.C:
+		return nil, errRangeTimeout
+	case <-cancel:
+		return nil, ErrCancelled
+	}
+}
+
+// process imports the attestations of a range of blocks into the chain, the
+// peer is penalized for the invalid ones.
+func (s *Syncer) process(peer *Peer, from, to uint64, blocks []*BlockAttestations) {
+	var justified int
+	for _, block := range blocks {
+		if block.Number < from || block.Number > to {
+			peer.score.drop(dropInvalid)
+			continue
+		}
+		status, err := s.chain.InsertFinalityAttestations(block.Number, block.Hash, block.Attestations)
+		if err != nil {
+			reason := attestationDropReason(err)
+			peer.Log().Debug("Dropping synced attestations", "number", block.Number, "reason", reason, "err", err)
+			peer.score.drop(reason)
+			continue
+		}
+		if status != types.BasUnknown {
+			justified++
+		}
+	}
+	log.Debug("Imported finality data", "peer", peer.ID(), "from", from, "to", to, "blocks", len(blocks), "justified", justified)
+}
+
+// OnAttestationRange is a callback method to invoke when a range of attestations
+// are received from a remote peer.
+func (s *Syncer) OnAttestationRange(peer *Peer, id uint64, blocks []*BlockAttestations) error {
+	s.lock.Lock()
+	req, ok := s.reqs[id]
+	if ok && req.peer == peer.ID() {
+		delete(s.reqs, id)
+	}
+	s.lock.Unlock()
+
+	if !ok || req.peer != peer.ID() {
+		peer.Log().Warn("Unexpected attestation range packet", "reqid", id)
+		return nil
+	}
+	req.deliver <- blocks
+	return nil
+}
@@ -22,5 +22,56 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/tracker"
 )
 
+// requestTimeout is the time after which a request is considered lost.
+const requestTimeout = time.Minute
+
 // requestTracker is a singleton tracker for request times.
-var requestTracker = tracker.New(ProtocolName, time.Minute)
+var requestTracker = tracker.New(ProtocolName, requestTimeout)
+
+// pendingRequest is a request sent to a peer and not yet answered.
+type pendingRequest struct {
+	code uint64    // Message code of the expected response
+	time time.Time // Timestamp when the request was sent
+}
+
+// trackRequest records a request sent to the peer, so the response is served
+// regardless of the rate limits. The lost requests are forgotten.
+func (p *Peer) trackRequest(id uint64, code uint64) {
+	p.reqLock.Lock()
+	defer p.reqLock.Unlock()
+
+	now := time.Now()
+	for rid, req := range p.requests {
+		if now.Sub(req.time) > requestTimeout {
+			delete(p.requests, rid)
+		}
+	}
+	p.requests[id] = &pendingRequest{code: code, time: now}
+}
+
+// fulfilRequest reports whether the response answers a request sent to the peer,
+// and stops tracking the request.
+func (p *Peer) fulfilRequest(id uint64, code uint64) bool {
+	p.reqLock.Lock()
+	defer p.reqLock.Unlock()
+
+	req, ok := p.requests[id]
+	if !ok || req.code != code || time.Since(req.time) > requestTimeout {
+		return false
+	}
+	delete(p.requests, id)
+	return true
+}
+
+// allowResponse reports whether a response is within the rate limit, the ones
+// answering the requests sent to the peer are always allowed.
+func (p *Peer) allowResponse(code uint64, id uint64) bool {
+	if p.fulfilRequest(id, code) {
+		return true
+	}
+	if !p.score.allow(code) {
+		p.score.drop(dropRateLimited)
+		return false
+	}
+	return true
+}
@@ -47,6 +47,7 @@ func (h *handler) syncTransactions(p *eth.Peer) {
 	for _, batch := range pending {
 		txs = append(txs, batch...)
 	}
+	txs = h.privateTxs.filter(txs)
 	if len(txs) == 0 {
 		return
 	}
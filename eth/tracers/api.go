@@ -659,17 +659,27 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 			}
 		}()
 	}
+	// Sort out the system transactions, which follow the system calls of the
+	// block finalization
+	isDoubleSignPunishTxs, isProposalTxs, commonTxs := api.systemTxs(block)
+
 	// Feed the transactions into the tracers and return
-	var failed error
+	var (
+		failed    error
+		finalized = !api.isChaosEngine
+	)
 	for i, tx := range txs {
 		var (
-			isDoubleSignPunishTx bool
-			isProposalTxs        bool
+			isDoubleSignPunishTx = isDoubleSignPunishTxs[i]
+			isProposalTxs        = isProposalTxs[i]
 		)
-		if api.isChaosEngine {
-			sender, _ := types.Sender(signer, tx)
-			isDoubleSignPunishTx = api.chaosEngine.IsDoubleSignPunishTransaction(sender, tx, header)
-			isProposalTxs = api.chaosEngine.IsSysTransaction(sender, tx, header)
+		// The system calls are only applied here, they're traced separately by
+		// TraceBlockFinalization
+		if (isDoubleSignPunishTx || isProposalTxs) && !finalized {
+			if failed = api.finalize(header, statedb, commonTxs); failed != nil {
+				break
+			}
+			finalized = true
 		}
 		// Send the trace task over for execution
 		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i, isDoubleSignPunishTx: isDoubleSignPunishTx, isProposalTxs: isProposalTxs}
@@ -0,0 +1,339 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// finalizationTraceType is the type of the synthetic trace entry of the state
+// changes made by the consensus engine while finalizing a block.
+const finalizationTraceType = "finalization"
+
+// finalizationTrace is the trace of the system calls made by the consensus engine
+// while finalizing a block, outside of the block transactions.
+type finalizationTrace struct {
+	Type  string             `json:"type"`
+	Calls []*systemCallTrace `json:"calls"`
+}
+
+// systemCallTrace is the trace of a single system call of the block finalization,
+// with the state changes it made, including the ones made outside of the EVM.
+type systemCallTrace struct {
+	Call      string                          `json:"call"`
+	Result    interface{}                     `json:"result,omitempty"`
+	StateDiff map[common.Address]*accountDiff `json:"stateDiff"`
+
+	tracer *touchTracer   // Tracer of the call, recording the touched state
+	pre    *state.StateDB // State before the call
+	cancel context.CancelFunc
+}
+
+// accountDiff is the change of an account made by a system call, the unchanged
+// fields are omitted.
+type accountDiff struct {
+	Balance *valueDiff                 `json:"balance,omitempty"`
+	Nonce   *valueDiff                 `json:"nonce,omitempty"`
+	Code    *valueDiff                 `json:"code,omitempty"`
+	Storage map[common.Hash]*valueDiff `json:"storage,omitempty"`
+}
+
+// valueDiff is a value changed by a system call.
+type valueDiff struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// diff computes the state changes of the call, from its pre state to the given
+// post state.
+func (t *systemCallTrace) diff(post *state.StateDB) {
+	t.StateDiff = make(map[common.Address]*accountDiff)
+	for addr, slots := range t.tracer.touched {
+		diff := new(accountDiff)
+		if from, to := t.pre.GetBalance(addr), post.GetBalance(addr); from.Cmp(to) != 0 {
+			diff.Balance = &valueDiff{From: (*hexutil.Big)(from), To: (*hexutil.Big)(to)}
+		}
+		if from, to := t.pre.GetNonce(addr), post.GetNonce(addr); from != to {
+			diff.Nonce = &valueDiff{From: hexutil.Uint64(from), To: hexutil.Uint64(to)}
+		}
+		if from, to := t.pre.GetCode(addr), post.GetCode(addr); !bytes.Equal(from, to) {
+			diff.Code = &valueDiff{From: hexutil.Bytes(from), To: hexutil.Bytes(to)}
+		}
+		for slot := range slots {
+			if from, to := t.pre.GetState(addr, slot), post.GetState(addr, slot); from != to {
+				if diff.Storage == nil {
+					diff.Storage = make(map[common.Hash]*valueDiff)
+				}
+				diff.Storage[slot] = &valueDiff{From: from, To: to}
+			}
+		}
+		if diff.Balance != nil || diff.Nonce != nil || diff.Code != nil || diff.Storage != nil {
+			t.StateDiff[addr] = diff
+		}
+	}
+}
+
+// touchTracer wraps the tracer of a system call, recording the accounts and the
+// storage slots the call may change.
+type touchTracer struct {
+	vm.EVMLogger
+	touched map[common.Address]map[common.Hash]struct{}
+}
+
+func newTouchTracer(tracer vm.EVMLogger, accounts ...common.Address) *touchTracer {
+	t := &touchTracer{
+		EVMLogger: tracer,
+		touched:   make(map[common.Address]map[common.Hash]struct{}),
+	}
+	for _, addr := range accounts {
+		t.touch(addr)
+	}
+	return t
+}
+
+func (t *touchTracer) touch(addr common.Address) map[common.Hash]struct{} {
+	slots, ok := t.touched[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		t.touched[addr] = slots
+	}
+	return slots
+}
+
+// CaptureStart implements vm.EVMLogger.
+func (t *touchTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+	t.EVMLogger.CaptureStart(env, from, to, create, input, gas, value)
+}
+
+// CaptureState implements vm.EVMLogger.
+func (t *touchTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	switch {
+	case op == vm.SSTORE && len(scope.Stack.Data()) >= 1:
+		t.touch(scope.Contract.Address())[common.Hash(scope.Stack.Back(0).Bytes32())] = struct{}{}
+	case op == vm.SELFDESTRUCT && len(scope.Stack.Data()) >= 1:
+		t.touch(common.Address(scope.Stack.Back(0).Bytes20()))
+	}
+	t.EVMLogger.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+}
+
+// CaptureEnter implements vm.EVMLogger.
+func (t *touchTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.touch(from)
+	t.touch(to)
+	t.EVMLogger.CaptureEnter(typ, from, to, input, gas, value)
+}
+
+// newTracer assembles the tracer configured for a trace, the struct logger by
+// default. The returned function releases the tracer.
+func (api *API) newTracer(ctx context.Context, config *TraceConfig, txctx *Context) (vm.EVMLogger, context.CancelFunc, error) {
+	switch {
+	case config == nil:
+		return vm.NewStructLogger(nil), func() {}, nil
+
+	case config.Tracer != nil:
+		// Define a meaningful timeout of a single trace
+		timeout := defaultTraceTimeout
+		if config.Timeout != nil {
+			var err error
+			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+				return nil, nil, err
+			}
+		}
+		tracer, err := New(*config.Tracer, txctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+		go func() {
+			<-deadlineCtx.Done()
+			if errors.Is(deadlineCtx.Err(), context.DeadlineExceeded) {
+				tracer.Stop(errors.New("execution timeout"))
+			}
+		}()
+		return tracer, cancel, nil
+
+	default:
+		return vm.NewStructLogger(config.LogConfig), func() {}, nil
+	}
+}
+
+// TraceBlockFinalization returns the traces of the system calls made by the Chaos
+// engine while finalizing a block, outside of the block transactions, along with
+// the state changes they made. Nil is returned if the engine made no system call.
+func (api *API) TraceBlockFinalization(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, config *TraceConfig) (*finalizationTrace, error) {
+	if !api.isChaosEngine {
+		return nil, errors.New("block finalization is only traced with the Chaos engine")
+	}
+	var (
+		err   error
+		block *types.Block
+	)
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, err = api.blockByHash(ctx, hash)
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		block, err = api.blockByNumber(ctx, number)
+	} else {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	statedb, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		header   = block.Header()
+		signer   = types.MakeSigner(api.backend.ChainConfig(), block.Number())
+		blockCtx = core.NewEVMBlockContext(header, api.chainContext(ctx), nil)
+	)
+	if err := api.chaosEngine.PreHandle(api.backend.ChainHeaderReader(), header, statedb); err != nil {
+		return nil, err
+	}
+	blockCtx.AccessFilter = api.chaosEngine.CreateEvmAccessFilter(header, statedb)
+
+	// Apply the transactions preceding the system calls without tracing them
+	isDoubleSignPunishTxs, isProposalTxs, commonTxs := api.systemTxs(block)
+	for i, tx := range block.Transactions() {
+		if isDoubleSignPunishTxs[i] || isProposalTxs[i] {
+			break
+		}
+		msg, _ := tx.AsMessage(signer, block.BaseFee())
+		vmenv := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vm.Config{})
+		statedb.Prepare(tx.Hash(), i)
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+			return nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+	}
+	return api.traceFinalize(ctx, header, statedb, commonTxs, config)
+}
+
+// systemTxs sorts out the system transactions of a block, the double sign
+// punishments and the proposal transactions, which follow the system calls of
+// the block finalization. It returns the number of the other transactions too.
+func (api *API) systemTxs(block *types.Block) (isDoubleSignPunishTxs []bool, isProposalTxs []bool, commonTxs int) {
+	var (
+		txs    = block.Transactions()
+		header = block.Header()
+		signer = types.MakeSigner(api.backend.ChainConfig(), block.Number())
+	)
+	isDoubleSignPunishTxs = make([]bool, len(txs))
+	isProposalTxs = make([]bool, len(txs))
+	if !api.isChaosEngine {
+		return isDoubleSignPunishTxs, isProposalTxs, len(txs)
+	}
+	for i, tx := range txs {
+		sender, _ := types.Sender(signer, tx)
+		isDoubleSignPunishTxs[i] = api.chaosEngine.IsDoubleSignPunishTransaction(sender, tx, header)
+		isProposalTxs[i] = api.chaosEngine.IsSysTransaction(sender, tx, header)
+		if !isDoubleSignPunishTxs[i] && !isProposalTxs[i] {
+			commonTxs++
+		}
+	}
+	return isDoubleSignPunishTxs, isProposalTxs, commonTxs
+}
+
+// finalize applies the system calls of the block finalization on the state,
+// without tracing them.
+func (api *API) finalize(header *types.Header, statedb *state.StateDB, commonTxs int) error {
+	return api.chaosEngine.TraceFinalize(api.backend.ChainHeaderReader(), header, statedb, commonTxs, func(string) vm.EVMLogger {
+		return nil
+	})
+}
+
+// traceFinalize applies the system calls of the block finalization on the state,
+// tracing each of them with the configured tracer. The block has commonTxs non-
+// system transactions. Nil is returned if the engine made no system call.
+func (api *API) traceFinalize(ctx context.Context, header *types.Header, statedb *state.StateDB, commonTxs int, config *TraceConfig) (*finalizationTrace, error) {
+	// Check the tracer upfront, the engine can't be failed from the callback
+	txctx := &Context{BlockHash: header.Hash(), TxIndex: -1}
+	if _, cancel, err := api.newTracer(ctx, config, txctx); err != nil {
+		return nil, err
+	} else {
+		cancel()
+	}
+	var calls []*systemCallTrace
+	defer func() {
+		for _, call := range calls {
+			call.cancel()
+		}
+	}()
+	trace := func(name string) vm.EVMLogger {
+		// A call starts where the previous one ended
+		if len(calls) > 0 {
+			calls[len(calls)-1].diff(statedb)
+		}
+		tracer, cancel, err := api.newTracer(ctx, config, txctx)
+		if err != nil {
+			tracer, cancel = vm.NewStructLogger(nil), func() {}
+		}
+		// The block fees are moved outside of the EVM, from the fee recorder to
+		// the coinbase
+		call := &systemCallTrace{
+			Call:   name,
+			tracer: newTouchTracer(tracer, header.Coinbase, consensus.FeeRecoder),
+			pre:    statedb.Copy(),
+			cancel: cancel,
+		}
+		calls = append(calls, call)
+		return call.tracer
+	}
+	if err := api.chaosEngine.TraceFinalize(api.backend.ChainHeaderReader(), header, statedb, commonTxs, trace); err != nil {
+		return nil, err
+	}
+	if len(calls) == 0 {
+		return nil, nil
+	}
+	calls[len(calls)-1].diff(statedb)
+
+	for _, call := range calls {
+		result, err := api.traceResult(call.tracer.EVMLogger, &core.ExecutionResult{})
+		if err != nil {
+			return nil, err
+		}
+		call.Result = result
+	}
+	return &finalizationTrace{Type: finalizationTraceType, Calls: calls}, nil
+}
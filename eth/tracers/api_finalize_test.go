@@ -0,0 +1,75 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestSystemCallStateDiff(t *testing.T) {
+	var (
+		coinbase = common.HexToAddress("0xc0")
+		recorder = common.HexToAddress("0xfe")
+		contract = common.HexToAddress("0xf000")
+		slot     = common.HexToHash("0x01")
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetBalance(recorder, big.NewInt(100))
+	statedb.SetState(contract, common.HexToHash("0x02"), common.HexToHash("0x02"))
+
+	// Move the fees outside of the EVM and store a slot from the call
+	call := &systemCallTrace{
+		Call:   "distributeBlockFee",
+		tracer: newTouchTracer(vm.NewStructLogger(nil), coinbase, recorder),
+		pre:    statedb.Copy(),
+	}
+	statedb.AddBalance(coinbase, big.NewInt(100))
+	statedb.SetBalance(recorder, common.Big0)
+	statedb.SetState(contract, slot, common.HexToHash("0x03"))
+	call.tracer.touch(contract)[slot] = struct{}{}
+	call.tracer.touch(contract)[common.HexToHash("0x02")] = struct{}{}
+
+	call.diff(statedb)
+	have, _ := json.Marshal(call.StateDiff)
+	want := `{` +
+		`"0x00000000000000000000000000000000000000c0":{"balance":{"from":"0x0","to":"0x64"}},` +
+		`"0x00000000000000000000000000000000000000fe":{"balance":{"from":"0x64","to":"0x0"}},` +
+		`"0x000000000000000000000000000000000000f000":{"storage":{"0x0000000000000000000000000000000000000000000000000000000000000001":{"from":"0x0000000000000000000000000000000000000000000000000000000000000000","to":"0x0000000000000000000000000000000000000000000000000000000000000003"}}}` +
+		`}`
+	if string(have) != want {
+		t.Errorf("state diff mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+func TestTraceBlockFinalizationWithoutChaos(t *testing.T) {
+	t.Parallel()
+
+	api := NewAPI(newTestBackend(t, 1, &core.Genesis{}, func(i int, b *core.BlockGen) {}))
+	if _, err := api.TraceBlockFinalization(context.Background(), rpc.BlockNumberOrHashWithNumber(1), nil); err == nil {
+		t.Fatal("block finalization traced without the Chaos engine")
+	}
+}
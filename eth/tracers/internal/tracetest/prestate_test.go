@@ -0,0 +1,275 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+type prestateDiff struct {
+	Pre  map[common.Address]*prestateAccount `json:"pre"`
+	Post map[common.Address]*prestateAccount `json:"post"`
+}
+
+var (
+	senderKey, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	feePayerKey, _ = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+	sender         = crypto.PubkeyToAddress(senderKey.PublicKey)
+	feePayer       = crypto.PubkeyToAddress(feePayerKey.PublicKey)
+	miner          = common.HexToAddress("0x00000000000000000000000000000000000000ff")
+	contract       = common.HexToAddress("0x00000000000000000000000000000000000000cc")
+)
+
+// metaPayload wraps a payload into the data of a meta transaction, having the
+// fee payer cover feePercent ten-thousandths of the transaction fee.
+func metaPayload(t *testing.T, chainID *big.Int, nonce uint64, gasPrice *big.Int, gas uint64, to *common.Address, value *big.Int, payload []byte, feePercent uint64) []byte {
+	const blockNumLimit = 100
+	enc, err := rlp.EncodeToBytes([]interface{}{nonce, gasPrice, gas, to, value, payload, sender, feePercent, uint64(blockNumLimit), chainID})
+	if err != nil {
+		t.Fatalf("failed to encode meta data: %v", err)
+	}
+	sig, err := crypto.Sign(crypto.Keccak256(enc), feePayerKey)
+	if err != nil {
+		t.Fatalf("failed to sign meta data: %v", err)
+	}
+	v := new(big.Int).Add(new(big.Int).Mul(chainID, big.NewInt(2)), big.NewInt(int64(sig[64])+35))
+	meta, err := rlp.EncodeToBytes(&types.MetaData{
+		BlockNumLimit: blockNumLimit,
+		FeePercent:    feePercent,
+		V:             v,
+		R:             new(big.Int).SetBytes(sig[:32]),
+		S:             new(big.Int).SetBytes(sig[32:64]),
+		Payload:       payload,
+	})
+	if err != nil {
+		t.Fatalf("failed to encode meta data: %v", err)
+	}
+	return append(common.FromHex(types.MetaPrefix), meta...)
+}
+
+func TestPrestateDiffTracer(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.LondonBlock = nil
+
+	var (
+		gasPrice = big.NewInt(100)
+		value    = big.NewInt(1000)
+		alloc    = core.GenesisAlloc{
+			sender:   {Balance: big.NewInt(params.Ether)},
+			feePayer: {Balance: big.NewInt(params.Ether)},
+			// Stores the caller into the slot 1
+			contract: {Balance: big.NewInt(1), Code: common.FromHex("0x3360015500")},
+		}
+		signer = types.LatestSigner(&config)
+	)
+	cases := []struct {
+		name     string
+		data     func(nonce uint64, gas uint64) []byte
+		feeShare int64 // Share of the fee paid by the fee payer, in percents
+	}{
+		{"ordinary", func(uint64, uint64) []byte { return nil }, 0},
+		{"meta", func(nonce uint64, gas uint64) []byte {
+			return metaPayload(t, config.ChainID, nonce, gasPrice, gas, &contract, value, nil, 2500)
+		}, 25},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+
+			gas := uint64(100000)
+			tx, err := types.SignTx(types.NewTransaction(0, contract, value, gas, gasPrice, tt.data(0, gas)), signer, senderKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			msg, err := tx.AsMessage(signer, nil)
+			if err != nil {
+				t.Fatalf("failed to prepare transaction for tracing: %v", err)
+			}
+			tracer, err := tracers.New("prestateDiffTracer", new(tracers.Context))
+			if err != nil {
+				t.Fatalf("failed to create prestate tracer: %v", err)
+			}
+			context := vm.BlockContext{
+				CanTransfer: core.CanTransfer,
+				Transfer:    core.Transfer,
+				Coinbase:    miner,
+				BlockNumber: big.NewInt(1),
+				Time:        big.NewInt(1),
+				Difficulty:  big.NewInt(1),
+				GasLimit:    gas,
+			}
+			evm := vm.NewEVM(context, core.NewEVMTxContext(msg), statedb, &config, vm.Config{Debug: true, Tracer: tracer})
+			result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(gas))
+			if err != nil {
+				t.Fatalf("failed to execute transaction: %v", err)
+			}
+			if result.Failed() {
+				t.Fatalf("transaction failed: %v", result.Err)
+			}
+			res, err := tracer.GetResult()
+			if err != nil {
+				t.Fatalf("failed to retrieve trace result: %v", err)
+			}
+			var diff prestateDiff
+			if err := json.Unmarshal(res, &diff); err != nil {
+				t.Fatalf("failed to unmarshal trace result: %v", err)
+			}
+			// The fee is split between the sender and the fee payer
+			var (
+				fee       = new(big.Int).Mul(new(big.Int).SetUint64(result.UsedGas), gasPrice)
+				payerFee  = new(big.Int).Div(new(big.Int).Mul(fee, big.NewInt(tt.feeShare)), big.NewInt(100))
+				senderFee = new(big.Int).Sub(fee, payerFee)
+				ether     = big.NewInt(params.Ether)
+			)
+			checkBalance := func(addr common.Address, pre, post *big.Int) {
+				t.Helper()
+				if diff.Pre[addr] == nil || diff.Post[addr] == nil {
+					t.Fatalf("account %x missing from the diff: %s", addr, res)
+				}
+				if have := diff.Pre[addr].Balance.ToInt(); have.Cmp(pre) != 0 {
+					t.Errorf("account %x pre balance mismatch: have %v, want %v", addr, have, pre)
+				}
+				if have := diff.Post[addr].Balance.ToInt(); have.Cmp(post) != 0 {
+					t.Errorf("account %x post balance mismatch: have %v, want %v", addr, have, post)
+				}
+			}
+			checkBalance(sender, ether, new(big.Int).Sub(ether, new(big.Int).Add(senderFee, value)))
+			checkBalance(contract, big.NewInt(1), new(big.Int).Add(big.NewInt(1), value))
+			// The miner account is created by the fee payment
+			if _, ok := diff.Pre[miner]; ok {
+				t.Errorf("missing miner in the pre state: %s", res)
+			}
+			if have := diff.Post[miner]; have == nil || have.Balance.ToInt().Cmp(fee) != 0 {
+				t.Errorf("miner post state mismatch: have %s, want balance %v", res, fee)
+			}
+			if tt.feeShare == 0 {
+				if _, ok := diff.Post[feePayer]; ok {
+					t.Errorf("untouched fee payer in the diff: %s", res)
+				}
+			} else {
+				checkBalance(feePayer, ether, new(big.Int).Sub(ether, payerFee))
+			}
+			if pre, post := diff.Pre[sender].Nonce, diff.Post[sender].Nonce; pre != 0 || post != 1 {
+				t.Errorf("sender nonce mismatch: have %d -> %d, want 0 -> 1", pre, post)
+			}
+			slot := common.BigToHash(big.NewInt(1))
+			if have, want := diff.Post[contract].Storage[slot], common.BytesToHash(sender.Bytes()); have != want {
+				t.Errorf("contract storage mismatch: have %x, want %x", have, want)
+			}
+			if have := diff.Pre[contract].Storage[slot]; have != (common.Hash{}) {
+				t.Errorf("contract pre storage mismatch: have %x, want empty", have)
+			}
+			if diff.Post[contract].Code != nil {
+				t.Errorf("unchanged contract code in the post state: %s", res)
+			}
+		})
+	}
+}
+
+// Tests that the native prestateTracer, replacing the JS one, keeps the shape
+// of its results.
+func TestPrestateTracerLegacy(t *testing.T) {
+	config := *params.AllEthashProtocolChanges
+	config.LondonBlock = nil
+
+	var (
+		gas   = uint64(100000)
+		alloc = core.GenesisAlloc{
+			sender:   {Balance: big.NewInt(params.Ether)},
+			contract: {Balance: big.NewInt(1), Code: common.FromHex("0x3360015500")},
+		}
+		signer = types.LatestSigner(&config)
+	)
+	tx, err := types.SignTx(types.NewTransaction(0, contract, big.NewInt(1000), gas, big.NewInt(100), nil), signer, senderKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	msg, err := tx.AsMessage(signer, nil)
+	if err != nil {
+		t.Fatalf("failed to prepare transaction for tracing: %v", err)
+	}
+	trace := func(name string) map[common.Address]map[string]json.RawMessage {
+		_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+
+		tracer, err := tracers.New(name, new(tracers.Context))
+		if err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		context := vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			Coinbase:    miner,
+			BlockNumber: big.NewInt(1),
+			Time:        big.NewInt(1),
+			Difficulty:  big.NewInt(1),
+			GasLimit:    gas,
+		}
+		evm := vm.NewEVM(context, core.NewEVMTxContext(msg), statedb, &config, vm.Config{Debug: true, Tracer: tracer})
+		if _, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(gas)); err != nil {
+			t.Fatalf("failed to execute transaction: %v", err)
+		}
+		res, err := tracer.GetResult()
+		if err != nil {
+			t.Fatalf("failed to retrieve %s result: %v", name, err)
+		}
+		var accounts map[common.Address]map[string]json.RawMessage
+		if err := json.Unmarshal(res, &accounts); err != nil {
+			t.Fatalf("failed to unmarshal %s result: %v", name, err)
+		}
+		return accounts
+	}
+	var (
+		native = trace("prestateTracer")
+		legacy = trace("prestateTracerLegacy")
+	)
+	for addr, want := range legacy {
+		have, ok := native[addr]
+		if !ok {
+			t.Errorf("account %x missing from the native result", addr)
+			continue
+		}
+		for _, field := range []string{"nonce", "code", "storage"} {
+			if string(have[field]) != string(want[field]) {
+				t.Errorf("account %x %s mismatch: have %s, want %s", addr, field, have[field], want[field])
+			}
+		}
+		if _, ok := have["balance"]; !ok {
+			t.Errorf("account %x balance missing from the native result", addr)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+// The native prestateTracer replaces the JS one under the same name, its pre
+// state is shaped like the JS one's. The JS tracer is still available as the
+// prestateTracerLegacy.
+func init() {
+	register("prestateTracer", newPrestateTracer)
+	register("prestateDiffTracer", newPrestateDiffTracer)
+}
+
+type account struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   uint64                      `json:"nonce,omitempty"`
+	Code    *hexutil.Bytes              `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+
+	code  []byte // Code of the account, also if empty
+	exist bool   // Whether the account existed before the transaction
+}
+
+type prestate map[common.Address]*account
+
+// legacyAccount is an account of the pre state outside of the diff mode, with
+// all the fields present as in the results of the JS prestate tracer.
+type legacyAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// prestateDiff is the result of the diff mode, the pre and post state of the
+// accounts modified by a transaction. The post state holds only the modified
+// fields, and misses the deleted accounts.
+type prestateDiff struct {
+	Pre  prestate `json:"pre"`
+	Post prestate `json:"post"`
+}
+
+type prestateTracer struct {
+	env       *vm.EVM
+	pre       prestate
+	diffMode  bool
+	diff      *prestateDiff
+	inTx      bool   // Whether the transaction boundaries are captured
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newPrestateTracer returns a native go tracer which collects the state
+// accessed by a tx, as it was before the tx, and implements vm.EVMTxLogger.
+func newPrestateTracer() tracers.Tracer {
+	return &prestateTracer{pre: make(prestate)}
+}
+
+// newPrestateDiffTracer returns a native go tracer which collects the state
+// modified by a tx, as it was before and after the tx.
+func newPrestateDiffTracer() tracers.Tracer {
+	return &prestateTracer{pre: make(prestate), diffMode: true}
+}
+
+// CaptureTxStart implements the EVMTxLogger interface to record the accounts a
+// transaction changes outside of the EVM, as the gas payers and fee receiver of
+// the transactions or the validator of the system transactions.
+func (t *prestateTracer) CaptureTxStart(env *vm.EVM, accounts []common.Address) {
+	t.env = env
+	t.inTx = true
+	for _, addr := range accounts {
+		t.lookupAccount(addr)
+	}
+}
+
+// CaptureTxEnd implements the EVMTxLogger interface to collect the post state
+// of the transaction, including the fee payment.
+func (t *prestateTracer) CaptureTxEnd(env *vm.EVM) {
+	t.env = env
+	if t.diffMode {
+		t.processDiff()
+	}
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.lookupAccount(from)
+
+	// The contract is already created with the endowment, it's an empty
+	// account before
+	if create {
+		if _, ok := t.pre[to]; !ok {
+			t.pre[to] = &account{
+				Balance: (*hexutil.Big)(new(big.Int).Sub(env.StateDB.GetBalance(to), value)),
+				Storage: make(map[common.Hash]common.Hash),
+			}
+		}
+		return
+	}
+	t.lookupAccount(to)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	// Without transaction boundaries, the call is the whole traced operation
+	if t.diffMode && !t.inTx {
+		t.processDiff()
+	}
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		t.env.Cancel()
+		return
+	}
+	var (
+		stack  = scope.Stack
+		caller = scope.Contract.Address()
+	)
+	switch {
+	case (op == vm.SLOAD || op == vm.SSTORE) && len(stack.Data()) >= 1:
+		t.lookupStorage(caller, common.Hash(stack.Back(0).Bytes32()))
+
+	case (op == vm.EXTCODECOPY || op == vm.EXTCODESIZE || op == vm.EXTCODEHASH || op == vm.BALANCE || op == vm.SELFDESTRUCT) && len(stack.Data()) >= 1:
+		t.lookupAccount(common.Address(stack.Back(0).Bytes20()))
+
+	case (op == vm.CALL || op == vm.CALLCODE || op == vm.DELEGATECALL || op == vm.STATICCALL) && len(stack.Data()) >= 2:
+		// The callee is looked up before the value transfer
+		t.lookupAccount(common.Address(stack.Back(1).Bytes20()))
+
+	case op == vm.CREATE:
+		t.lookupAccount(crypto.CreateAddress(caller, t.env.StateDB.GetNonce(caller)))
+
+	case op == vm.CREATE2 && len(stack.Data()) >= 4:
+		offset, size := stack.Back(1).Uint64(), stack.Back(2).Uint64()
+		if offset+size < offset || offset+size > uint64(scope.Memory.Len()) {
+			return
+		}
+		init := scope.Memory.GetPtr(int64(offset), int64(size))
+		salt := stack.Back(3).Bytes32()
+		t.lookupAccount(crypto.CreateAddress2(caller, salt, crypto.Keccak256(init)))
+	}
+}
+
+// CaptureFault implements the EVMLogger interface to trace an execution fault.
+func (t *prestateTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, _ *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *prestateTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *prestateTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+}
+
+// GetResult returns the json-encoded pre state, or the pre and post state in
+// the diff mode.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	var (
+		res []byte
+		err error
+	)
+	if t.diffMode {
+		if t.diff == nil && t.env != nil {
+			t.processDiff()
+		}
+		res, err = json.Marshal(t.diff)
+	} else {
+		// The accounts missing before the transaction are not part of its pre state
+		pre := make(map[common.Address]*legacyAccount)
+		for addr, acc := range t.pre {
+			if acc.exist {
+				pre[addr] = &legacyAccount{
+					Balance: acc.Balance,
+					Nonce:   acc.Nonce,
+					Code:    acc.code,
+					Storage: acc.Storage,
+				}
+			}
+		}
+		res, err = json.Marshal(pre)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *prestateTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// lookupAccount records the current state of an account, unless it's recorded
+// already.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.pre[addr]; ok {
+		return
+	}
+	db := t.env.StateDB
+	acc := &account{
+		Balance: (*hexutil.Big)(new(big.Int).Set(db.GetBalance(addr))),
+		Nonce:   db.GetNonce(addr),
+		Storage: make(map[common.Hash]common.Hash),
+		code:    common.CopyBytes(db.GetCode(addr)),
+		exist:   db.Exist(addr),
+	}
+	if len(acc.code) > 0 {
+		acc.Code = (*hexutil.Bytes)(&acc.code)
+	}
+	t.pre[addr] = acc
+}
+
+// lookupStorage records the current value of a storage slot, unless it's
+// recorded already.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.pre[addr].Storage[key]; ok {
+		return
+	}
+	t.pre[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
+}
+
+// processDiff compares the recorded pre state with the current state, keeping
+// the modified accounts only.
+func (t *prestateTracer) processDiff() {
+	diff := &prestateDiff{Pre: make(prestate), Post: make(prestate)}
+	db := t.env.StateDB
+	for addr, pre := range t.pre {
+		// A deleted account is in the pre state only
+		if !db.Exist(addr) || db.HasSuicided(addr) {
+			if pre.exist {
+				diff.Pre[addr] = pre
+			}
+			continue
+		}
+		var (
+			modified bool
+			post     = new(account)
+		)
+		if balance := db.GetBalance(addr); balance.Cmp(pre.Balance.ToInt()) != 0 {
+			post.Balance, modified = (*hexutil.Big)(new(big.Int).Set(balance)), true
+		}
+		if nonce := db.GetNonce(addr); nonce != pre.Nonce {
+			post.Nonce, modified = nonce, true
+		}
+		// The code may be erased by a proposal, an empty post code is kept
+		if code := db.GetCode(addr); !bytes.Equal(code, pre.code) {
+			post.code = common.CopyBytes(code)
+			post.Code, modified = (*hexutil.Bytes)(&post.code), true
+		}
+		storage := make(map[common.Hash]common.Hash)
+		for key, val := range pre.Storage {
+			if newVal := db.GetState(addr, key); newVal != val {
+				if post.Storage == nil {
+					post.Storage = make(map[common.Hash]common.Hash)
+				}
+				storage[key], post.Storage[key], modified = val, newVal, true
+			}
+		}
+		if !modified {
+			continue
+		}
+		diff.Post[addr] = post
+		if pre.exist {
+			diff.Pre[addr] = &account{
+				Balance: pre.Balance,
+				Nonce:   pre.Nonce,
+				Code:    pre.Code,
+				Storage: storage,
+			}
+		}
+	}
+	t.diff = diff
+}
@@ -1182,6 +1182,10 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 			return 0, errors.New("block not found")
 		}
 		hi = block.GasLimit()
+		// Chaos shapes the gas budget of blocks, the max budget is the real ceiling.
+		if chaosEngine, isChaosEngine := b.Engine().(consensus.ChaosEngine); isChaosEngine {
+			hi = chaosEngine.MaxGasBudget(new(big.Int).Add(block.Number(), common.Big1), hi)
+		}
 	}
 	// Normalize the max fee per gas the call is willing to spend.
 	var feeCap *big.Int
@@ -1791,12 +1795,28 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	// Derive the sender.
 	bigblock := new(big.Int).SetUint64(blockNumber)
 	signer := types.MakeSigner(s.b.ChainConfig(), bigblock)
+
+	// The effective gas price paid depends on the base fee after London
+	var baseFee *big.Int
+	if s.b.ChainConfig().IsLondon(bigblock) {
+		header, err := s.b.HeaderByHash(ctx, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		baseFee = header.BaseFee
+	}
+	return marshalReceipt(receipt, blockHash, blockNumber, signer, tx, index, baseFee), nil
+}
+
+// marshalReceipt converts a receipt into the RPC representation, the base fee
+// of the block being nil before London.
+func marshalReceipt(receipt *types.Receipt, blockHash common.Hash, blockNumber uint64, signer types.Signer, tx *types.Transaction, index uint64, baseFee *big.Int) map[string]interface{} {
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
-		"transactionHash":   hash,
+		"transactionHash":   tx.Hash(),
 		"transactionIndex":  hexutil.Uint64(index),
 		"from":              from,
 		"to":                tx.To(),
@@ -1808,14 +1828,10 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 		"type":              hexutil.Uint(tx.Type()),
 	}
 	// Assign the effective gas price paid
-	if !s.b.ChainConfig().IsLondon(bigblock) {
+	if baseFee == nil {
 		fields["effectiveGasPrice"] = hexutil.Uint64(tx.GasPrice().Uint64())
 	} else {
-		header, err := s.b.HeaderByHash(ctx, blockHash)
-		if err != nil {
-			return nil, err
-		}
-		gasPrice := new(big.Int).Add(header.BaseFee, tx.EffectiveGasTipValue(header.BaseFee))
+		gasPrice := new(big.Int).Add(baseFee, tx.EffectiveGasTipValue(baseFee))
 		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
 	}
 	// Assign receipt status or post state.
@@ -1831,7 +1847,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
-	return fields, nil
+	return fields
 }
 
 // sign is a helper function that signs a transaction with the private key of the given address.
@@ -1849,6 +1865,11 @@ func (s *PublicTransactionPoolAPI) sign(addr common.Address, tx *types.Transacti
 
 // SubmitTransaction is a helper function that submits tx to txPool and logs a message.
 func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
+	return submitTransaction(ctx, b, tx, b.SendTx)
+}
+
+// submitTransaction is a helper function that submits tx with the given send function and logs a message.
+func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction, send func(context.Context, *types.Transaction) error) (common.Hash, error) {
 	// If the transaction fee cap is already specified, ensure the
 	// fee of the given transaction is _reasonable_.
 	if err := checkTxFee(tx.GasPrice(), tx.Gas(), b.RPCTxFeeCap()); err != nil {
@@ -1858,7 +1879,7 @@ func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 		// Ensure only eip155 signed transactions are submitted if EIP155Required is set.
 		return common.Hash{}, errors.New("only replay-protected (EIP-155) transactions allowed over RPC")
 	}
-	if err := b.SendTx(ctx, tx); err != nil {
+	if err := send(ctx, tx); err != nil {
 		return common.Hash{}, err
 	}
 	// Print a log with full tx details for manual investigations and interventions
@@ -1939,6 +1960,58 @@ func (s *PublicTransactionPoolAPI) SendRawTransaction(ctx context.Context, input
 	return SubmitTransaction(ctx, s.b, tx)
 }
 
+// Status of the privately submitted transactions
+const (
+	PrivateTxPrivate  = "private"  // Only offered to the in-turn validators
+	PrivateTxPublic   = "public"   // Broadcast publicly after the fallback block
+	PrivateTxIncluded = "included" // Included in the canonical chain
+)
+
+// PrivateTxStatus is the status of a privately submitted transaction.
+type PrivateTxStatus struct {
+	Status      string           `json:"status"`
+	Submitted   hexutil.Uint64   `json:"submitted,omitempty"`   // Head block number when it's submitted
+	Fallback    hexutil.Uint64   `json:"fallback,omitempty"`    // Block number from which it's broadcast publicly
+	Validators  []common.Address `json:"validators,omitempty"`  // Validators it has been offered to
+	BlockHash   *common.Hash     `json:"blockHash,omitempty"`   // Block including the transaction
+	BlockNumber *hexutil.Uint64  `json:"blockNumber,omitempty"` // Number of the block including the transaction
+}
+
+// SendPrivateTransaction will add the signed transaction to the transaction pool without
+// gossiping it, it's only offered to the relays of the in-turn validators configured
+// by --txpool.privaterelays until the fallback block.
+func (s *PublicTransactionPoolAPI) SendPrivateTransaction(ctx context.Context, input hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if err := metaTransactionCheck(ctx, tx, s.b); err != nil {
+		return common.Hash{}, err
+	}
+	return submitTransaction(ctx, s.b, tx, s.b.SendPrivateTx)
+}
+
+// GetPrivateTransactionStatus returns the status of a privately submitted transaction,
+// nil will be returned if the transaction is unknown.
+func (s *PublicTransactionPoolAPI) GetPrivateTransactionStatus(ctx context.Context, hash common.Hash) (*PrivateTxStatus, error) {
+	if status := s.b.PrivateTxStatus(hash); status != nil {
+		return status, nil
+	}
+	// Private transactions are not tracked anymore once included
+	tx, blockHash, blockNumber, _, err := s.b.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, nil
+	}
+	return &PrivateTxStatus{
+		Status:      PrivateTxIncluded,
+		BlockHash:   &blockHash,
+		BlockNumber: (*hexutil.Uint64)(&blockNumber),
+	}, nil
+}
+
 /**
 check tx meta transaction format.
 */
@@ -2264,9 +2337,10 @@ func (api *PrivateDebugAPI) SendTransactions(ctx context.Context, signedTxs []*t
 	return txsHash, nil
 }
 
-// SetHead rewinds the head of the blockchain to a previous block.
-func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64) {
-	api.b.SetHead(uint64(number))
+// SetHead rewinds the head of the blockchain to a previous block. Rewinding
+// below the last finalized block is refused unless force is set.
+func (api *PrivateDebugAPI) SetHead(number hexutil.Uint64, force *bool) error {
+	return api.b.SetHead(uint64(number), force != nil && *force)
 }
 
 // PublicNetAPI offers network related RPC methods
@@ -0,0 +1,224 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Kinds of the transactions of a block bundle.
+const (
+	TxKindUser     = "user"     // Ordinary transaction
+	TxKindMeta     = "meta"     // Meta transaction, its fee shared with a fee payer
+	TxKindSystem   = "system"   // System transaction of the validator
+	TxKindProposal = "proposal" // System transaction executing a passed proposal
+	TxKindPunish   = "punish"   // System transaction punishing a double sign
+)
+
+// maxBundleRange is the maximum number of blocks a block bundle stream may span.
+const maxBundleRange = 5000
+
+// BlockBundle is everything an indexer needs of a block, in a single call: its
+// header, transactions and receipts, the kind of each transaction and the
+// attestation status of the block.
+type BlockBundle struct {
+	Header       map[string]interface{}   `json:"header"`
+	Transactions []*RPCTransaction        `json:"transactions"`
+	Receipts     []map[string]interface{} `json:"receipts"`
+	Kinds        []string                 `json:"kinds"`
+	Status       string                   `json:"status"`
+}
+
+// BlockBundleError is the terminal notification of a block bundle stream which
+// failed to deliver the bundle of a block, no notification follows it.
+type BlockBundleError struct {
+	Number hexutil.Uint64 `json:"number"`
+	Error  string         `json:"error"`
+}
+
+// GetBlockReceipts returns the receipts of all the transactions of a block.
+func (s *PublicBlockChainAPI) GetBlockReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	return s.blockReceipts(ctx, block)
+}
+
+// GetBlockBundle returns the header, transactions and receipts of a block, with
+// the kind of each transaction and the attestation status of the block.
+func (s *PublicBlockChainAPI) GetBlockBundle(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*BlockBundle, error) {
+	block, err := s.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	return s.blockBundle(ctx, block)
+}
+
+// BlockBundles streams the bundles of the canonical blocks from the first to the
+// last given block, in ascending order, the last one being capped at the current
+// head. No notification follows the bundle of the last block, the client
+// unsubscribes once it's received. If a bundle can't be delivered, the stream
+// ends with a BlockBundleError instead.
+func (s *PublicBlockChainAPI) BlockBundles(ctx context.Context, from rpc.BlockNumber, to rpc.BlockNumber) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	first, last := s.resolveBlockNumber(from), s.resolveBlockNumber(to)
+	if head := s.b.CurrentHeader().Number.Uint64(); last > head {
+		last = head
+	}
+	if first > last {
+		return nil, fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	if last-first >= maxBundleRange {
+		return nil, fmt.Errorf("exceed maximum block range: %d", maxBundleRange)
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		for number := first; number <= last; number++ {
+			select {
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			default:
+			}
+			bundle, err := s.canonicalBundle(number)
+			if err != nil {
+				log.Debug("Block bundle unavailable", "number", number, "err", err)
+				notifier.Notify(rpcSub.ID, &BlockBundleError{Number: hexutil.Uint64(number), Error: err.Error()})
+				return
+			}
+			if err := notifier.Notify(rpcSub.ID, bundle); err != nil {
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// canonicalBundle assembles the bundle of a canonical block by number.
+func (s *PublicBlockChainAPI) canonicalBundle(number uint64) (*BlockBundle, error) {
+	block, err := s.b.BlockByNumber(context.Background(), rpc.BlockNumber(number))
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return s.blockBundle(context.Background(), block)
+}
+
+// resolveBlockNumber resolves the latest and pending block numbers to the
+// number of the current head.
+func (s *PublicBlockChainAPI) resolveBlockNumber(number rpc.BlockNumber) uint64 {
+	if number < 0 {
+		return s.b.CurrentHeader().Number.Uint64()
+	}
+	return uint64(number)
+}
+
+// blockReceipts returns the RPC representation of the receipts of a block.
+func (s *PublicBlockChainAPI) blockReceipts(ctx context.Context, block *types.Block) ([]map[string]interface{}, error) {
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	txs := block.Transactions()
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("receipts length mismatch: %d transactions, %d receipts", len(txs), len(receipts))
+	}
+	signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+	fields := make([]map[string]interface{}, len(receipts))
+	for i, receipt := range receipts {
+		fields[i] = marshalReceipt(receipt, block.Hash(), block.NumberU64(), signer, txs[i], uint64(i), block.BaseFee())
+	}
+	return fields, nil
+}
+
+// blockBundle assembles the bundle of a block.
+func (s *PublicBlockChainAPI) blockBundle(ctx context.Context, block *types.Block) (*BlockBundle, error) {
+	receipts, err := s.blockReceipts(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	status, err := s.b.BlockPredictStatus(ctx, block.Hash(), rpc.BlockNumber(block.NumberU64()))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		header    = block.Header()
+		txs       = block.Transactions()
+		signer    = types.MakeSigner(s.b.ChainConfig(), block.Number())
+		engine, _ = s.b.Engine().(consensus.ChaosEngine)
+		bundle    = &BlockBundle{
+			Header:       s.rpcMarshalHeader(ctx, header),
+			Transactions: make([]*RPCTransaction, len(txs)),
+			Receipts:     receipts,
+			Kinds:        make([]string, len(txs)),
+			Status:       blockStatusName(status),
+		}
+	)
+	for i, tx := range txs {
+		bundle.Transactions[i] = newRPCTransaction(tx, block.Hash(), block.NumberU64(), uint64(i), block.BaseFee(), s.b.ChainConfig())
+		bundle.Kinds[i] = txKind(engine, signer, tx, header)
+	}
+	return bundle, nil
+}
+
+// txKind classifies a transaction of a block, the system transactions being
+// recognized by the Chaos engine only.
+func txKind(engine consensus.ChaosEngine, signer types.Signer, tx *types.Transaction, header *types.Header) string {
+	if engine != nil {
+		sender, _ := types.Sender(signer, tx)
+		switch {
+		case engine.IsDoubleSignPunishTransaction(sender, tx, header):
+			return TxKindPunish
+		case engine.IsProposalTransaction(sender, tx, header):
+			return TxKindProposal
+		case engine.IsSysTransaction(sender, tx, header):
+			return TxKindSystem
+		}
+	}
+	if types.IsMetaTransaction(tx.Data()) {
+		return TxKindMeta
+	}
+	return TxKindUser
+}
+
+// blockStatusName returns the readable name of a block attestation status.
+func blockStatusName(status uint8) string {
+	switch status {
+	case types.BasJustified:
+		return "justified"
+	case types.BasFinalized:
+		return "finalized"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,412 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// maxSimulateCalls is the maximum number of calls of a simulated bundle.
+const maxSimulateCalls = 256
+
+// SimulateCall is a call of a simulated bundle: a call as of eth_call, a signed
+// transaction, or on Chaos the call of a passed OnChainDao proposal.
+type SimulateCall struct {
+	TransactionArgs
+
+	// Raw is a signed transaction to run instead of the call fields.
+	Raw *hexutil.Bytes `json:"raw"`
+
+	// Proposal runs the call the way a passed proposal is executed: exempt from
+	// the access filter, without paying for gas nor increasing the nonce.
+	Proposal bool `json:"proposal"`
+}
+
+// BlockOverrides overrides the block context the calls of a bundle run in.
+type BlockOverrides struct {
+	Number   *hexutil.Big    `json:"number"`
+	Time     *hexutil.Uint64 `json:"time"`
+	Coinbase *common.Address `json:"coinbase"`
+}
+
+// Apply overrides the fields of the given block context.
+func (o *BlockOverrides) Apply(blockCtx *vm.BlockContext) {
+	if o == nil {
+		return
+	}
+	if o.Number != nil {
+		blockCtx.BlockNumber = o.Number.ToInt()
+	}
+	if o.Time != nil {
+		blockCtx.Time = new(big.Int).SetUint64(uint64(*o.Time))
+	}
+	if o.Coinbase != nil {
+		blockCtx.Coinbase = *o.Coinbase
+	}
+}
+
+// SimulateOptions are the options of a bundle simulation.
+type SimulateOptions struct {
+	StateOverrides *StateOverride  `json:"stateOverrides"`
+	BlockOverrides *BlockOverrides `json:"blockOverrides"`
+	StateDiff      bool            `json:"stateDiff"` // Whether to return the state changed by the bundle
+}
+
+// SimulateCallResult is the outcome of a call of a simulated bundle.
+type SimulateCallResult struct {
+	TxHash     *common.Hash   `json:"txHash,omitempty"`
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	Logs       []*types.Log   `json:"logs"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Status     hexutil.Uint64 `json:"status"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// SimulateBundleResult is the outcome of a simulated bundle.
+type SimulateBundleResult struct {
+	BlockNumber hexutil.Uint64        `json:"blockNumber"`
+	BlockHash   common.Hash           `json:"blockHash"`
+	GasUsed     hexutil.Uint64        `json:"gasUsed"`
+	Results     []*SimulateCallResult `json:"results"`
+	StateDiff   *SimulateStateDiff    `json:"stateDiff,omitempty"`
+}
+
+// SimulatedAccount is the state of an account changed by a simulated bundle.
+// The post state holds the changed fields only.
+type SimulatedAccount struct {
+	Balance *hexutil.Big                `json:"balance,omitempty"`
+	Nonce   *hexutil.Uint64             `json:"nonce,omitempty"`
+	Code    *hexutil.Bytes              `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// SimulateStateDiff is the state changed by a simulated bundle, as it was before
+// and after the bundle. The created accounts are missing from the pre state and
+// the deleted ones from the post state.
+type SimulateStateDiff struct {
+	Pre  map[common.Address]*SimulatedAccount `json:"pre"`
+	Post map[common.Address]*SimulatedAccount `json:"post"`
+}
+
+// SimulateBundle runs the given calls in order on the state of the given block,
+// each call seeing the changes of the previous ones. It returns the outcome of
+// every call; a failed call doesn't stop the bundle. The calls share the gas cap
+// of a single eth_call, the bundle is aborted once it's used up.
+//
+// Like eth_call, nothing is changed in the state or the blockchain.
+func (s *PublicBlockChainAPI) SimulateBundle(ctx context.Context, calls []SimulateCall, blockNrOrHash rpc.BlockNumberOrHash, options *SimulateOptions) (*SimulateBundleResult, error) {
+	return DoSimulateBundle(ctx, s.b, calls, blockNrOrHash, options, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
+}
+
+func DoSimulateBundle(ctx context.Context, b Backend, calls []SimulateCall, blockNrOrHash rpc.BlockNumberOrHash, options *SimulateOptions, timeout time.Duration, globalGasCap uint64) (*SimulateBundleResult, error) {
+	defer func(start time.Time) {
+		log.Debug("Simulating bundle finished", "calls", len(calls), "runtime", time.Since(start))
+	}(time.Now())
+
+	if len(calls) == 0 {
+		return nil, errors.New("empty bundle")
+	}
+	if len(calls) > maxSimulateCalls {
+		return nil, fmt.Errorf("too many calls in bundle: %d, limit %d", len(calls), maxSimulateCalls)
+	}
+	if options == nil {
+		options = new(SimulateOptions)
+	}
+	statedb, header, err := b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	if err := options.StateOverrides.Apply(statedb); err != nil {
+		return nil, err
+	}
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var (
+		vmConfig = &vm.Config{NoBaseFee: true}
+		recorder *accessRecorder
+		pre      *state.StateDB
+	)
+	if options.StateDiff {
+		recorder = newAccessRecorder()
+		vmConfig.Debug, vmConfig.Tracer = true, recorder
+		pre = statedb.Copy()
+	}
+	// The EVM is created once with the access filter of the block, the
+	// transaction context is reset for every call
+	msg := types.NewMessage(common.Address{}, nil, 0, new(big.Int), 0, new(big.Int), new(big.Int), new(big.Int), nil, nil, true)
+	evm, vmError, err := b.GetEVM(ctx, msg, statedb, header, vmConfig)
+	if err != nil {
+		return nil, err
+	}
+	options.BlockOverrides.Apply(&evm.Context)
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+
+	var (
+		config = b.ChainConfig()
+		signer = types.MakeSigner(config, evm.Context.BlockNumber)
+		gp     = new(core.GasPool).AddGas(math.MaxUint64)
+		result = &SimulateBundleResult{
+			BlockNumber: hexutil.Uint64(evm.Context.BlockNumber.Uint64()),
+			BlockHash:   header.Hash(),
+			Results:     make([]*SimulateCallResult, len(calls)),
+		}
+	)
+	if globalGasCap != 0 {
+		gp = new(core.GasPool).AddGas(globalGasCap)
+	}
+	for i, call := range calls {
+		if gp.Gas() == 0 {
+			return nil, fmt.Errorf("call %d: bundle gas cap %d used up", i, globalGasCap)
+		}
+		res, err := simulateCall(evm, statedb, signer, &call, i, header.BaseFee, gp, globalGasCap != 0)
+		if err := vmError(); err != nil {
+			return nil, err
+		}
+		if evm.Cancelled() {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		for _, l := range res.Logs {
+			l.BlockNumber = evm.Context.BlockNumber.Uint64()
+		}
+		result.GasUsed += res.GasUsed
+		result.Results[i] = res
+		statedb.Finalise(config.IsEIP158(evm.Context.BlockNumber))
+	}
+	if recorder != nil {
+		result.StateDiff = recorder.diff(pre, statedb)
+	}
+	return result, nil
+}
+
+// simulateCall runs a call of a bundle, taking its gas from the pool of the
+// bundle. The errors of the call are part of its result, only malformed calls
+// are returned as errors.
+func simulateCall(evm *vm.EVM, statedb *state.StateDB, signer types.Signer, call *SimulateCall, index int, baseFee *big.Int, gp *core.GasPool, capped bool) (*SimulateCallResult, error) {
+	var (
+		msg    types.Message
+		txHash common.Hash
+		res    = new(SimulateCallResult)
+		err    error
+	)
+	if call.Raw != nil {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(*call.Raw); err != nil {
+			return nil, err
+		}
+		if msg, err = tx.AsMessage(signer, baseFee); err != nil {
+			return nil, err
+		}
+		txHash = tx.Hash()
+		res.TxHash = &txHash
+	} else {
+		// The calls default to the gas left of the bundle if it's capped
+		var gasCap uint64
+		if capped {
+			gasCap = gp.Gas()
+		}
+		if msg, err = call.ToMessage(gasCap, baseFee); err != nil {
+			return nil, err
+		}
+		// The logs of the calls are told apart by a hash of their own
+		txHash = common.BigToHash(big.NewInt(int64(index + 1)))
+	}
+	statedb.Prepare(txHash, index)
+
+	var (
+		ret     []byte
+		gasUsed uint64
+		vmerr   error
+	)
+	if call.Proposal {
+		if evm.ChainConfig().Chaos == nil {
+			return nil, errors.New("proposals are only supported on Chaos")
+		}
+		if msg.To() == nil {
+			return nil, errors.New("proposal without recipient")
+		}
+		if err := gp.SubGas(msg.Gas()); err != nil {
+			res.Error = err.Error()
+			res.Logs = []*types.Log{}
+			return res, nil
+		}
+		// Proposals are exempt from the access filter of the block
+		filter := evm.Context.AccessFilter
+		evm.Context.AccessFilter = nil
+		evm.Reset(vm.TxContext{Origin: msg.From(), GasPrice: new(big.Int)}, statedb)
+		var left uint64
+		ret, left, vmerr = evm.Call(vm.AccountRef(msg.From()), *msg.To(), msg.Data(), msg.Gas(), msg.Value())
+		gasUsed = msg.Gas() - left
+		gp.AddGas(left)
+		evm.Context.AccessFilter = filter
+	} else {
+		evm.Reset(core.NewEVMTxContext(msg), statedb)
+		result, err := core.ApplyMessage(evm, msg, gp)
+		if err != nil {
+			// The call is invalid on the state, it changed nothing
+			res.Error = err.Error()
+			res.Logs = []*types.Log{}
+			return res, nil
+		}
+		ret, gasUsed, vmerr = result.ReturnData, result.UsedGas, result.Err
+		if len(result.Revert()) > 0 {
+			vmerr = newRevertError(result)
+		}
+	}
+	res.ReturnData = ret
+	res.GasUsed = hexutil.Uint64(gasUsed)
+	if vmerr != nil {
+		res.Error = vmerr.Error()
+	} else {
+		res.Status = hexutil.Uint64(types.ReceiptStatusSuccessful)
+	}
+	res.Logs = statedb.GetLogs(txHash, common.Hash{})
+	if res.Logs == nil {
+		res.Logs = []*types.Log{}
+	}
+	if call.Raw == nil {
+		for _, l := range res.Logs {
+			l.TxHash = common.Hash{}
+		}
+	}
+	return res, nil
+}
+
+// accessRecorder records the accounts and storage slots a bundle may change,
+// implementing vm.EVMTxLogger.
+type accessRecorder struct {
+	env      *vm.EVM
+	accounts map[common.Address]map[common.Hash]struct{}
+}
+
+func newAccessRecorder() *accessRecorder {
+	return &accessRecorder{accounts: make(map[common.Address]map[common.Hash]struct{})}
+}
+
+func (r *accessRecorder) touch(addr common.Address) map[common.Hash]struct{} {
+	slots, ok := r.accounts[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		r.accounts[addr] = slots
+	}
+	return slots
+}
+
+func (r *accessRecorder) CaptureTxStart(env *vm.EVM, accounts []common.Address) {
+	for _, addr := range accounts {
+		r.touch(addr)
+	}
+}
+
+func (r *accessRecorder) CaptureTxEnd(env *vm.EVM) {}
+
+func (r *accessRecorder) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	r.touch(from)
+	r.touch(to)
+}
+
+func (r *accessRecorder) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if op == vm.SSTORE && len(scope.Stack.Data()) >= 1 {
+		r.touch(scope.Contract.Address())[common.Hash(scope.Stack.Back(0).Bytes32())] = struct{}{}
+	}
+}
+
+func (r *accessRecorder) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	r.touch(from)
+	r.touch(to)
+}
+
+func (r *accessRecorder) CaptureExit(output []byte, gasUsed uint64, err error) {}
+
+func (r *accessRecorder) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (r *accessRecorder) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) {}
+
+// diff compares the recorded accounts between the state before and after the
+// bundle, keeping the changed ones.
+func (r *accessRecorder) diff(pre *state.StateDB, post *state.StateDB) *SimulateStateDiff {
+	diff := &SimulateStateDiff{
+		Pre:  make(map[common.Address]*SimulatedAccount),
+		Post: make(map[common.Address]*SimulatedAccount),
+	}
+	for addr, slots := range r.accounts {
+		var (
+			existed = pre.Exist(addr)
+			exists  = post.Exist(addr) && !post.HasSuicided(addr)
+			before  = new(SimulatedAccount)
+			after   = new(SimulatedAccount)
+		)
+		if !existed && !exists {
+			continue
+		}
+		if balance := post.GetBalance(addr); !exists || !existed || balance.Cmp(pre.GetBalance(addr)) != 0 {
+			before.Balance = (*hexutil.Big)(new(big.Int).Set(pre.GetBalance(addr)))
+			after.Balance = (*hexutil.Big)(new(big.Int).Set(balance))
+		}
+		if nonce, preNonce := post.GetNonce(addr), pre.GetNonce(addr); !exists || !existed || nonce != preNonce {
+			before.Nonce, after.Nonce = (*hexutil.Uint64)(&preNonce), (*hexutil.Uint64)(&nonce)
+		}
+		if code, preCode := post.GetCode(addr), pre.GetCode(addr); !bytes.Equal(code, preCode) {
+			before.Code, after.Code = (*hexutil.Bytes)(&preCode), (*hexutil.Bytes)(&code)
+		}
+		for key := range slots {
+			if val, preVal := post.GetState(addr, key), pre.GetState(addr, key); val != preVal {
+				if before.Storage == nil {
+					before.Storage, after.Storage = make(map[common.Hash]common.Hash), make(map[common.Hash]common.Hash)
+				}
+				before.Storage[key], after.Storage[key] = preVal, val
+			}
+		}
+		if before.Balance == nil && before.Nonce == nil && before.Code == nil && before.Storage == nil {
+			continue
+		}
+		if existed {
+			diff.Pre[addr] = before
+		}
+		if exists {
+			diff.Post[addr] = after
+		}
+	}
+	return diff
+}
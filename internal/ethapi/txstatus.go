@@ -0,0 +1,167 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Lifecycle stages of a transaction
+const (
+	TxStatusUnknown   = "unknown"   // Neither pooled, dropped nor included
+	TxStatusQueued    = "queued"    // Pooled, waiting for a nonce gap or funds
+	TxStatusPending   = "pending"   // Pooled, executable
+	TxStatusDropped   = "dropped"   // Dropped by the pool
+	TxStatusIncluded  = "included"  // Included in the canonical chain
+	TxStatusJustified = "justified" // Included in a justified block
+	TxStatusFinalized = "finalized" // Included in a finalized block
+)
+
+// TransactionStatus is the lifecycle status of a transaction.
+type TransactionStatus struct {
+	Status         string          `json:"status"`
+	DropReason     string          `json:"dropReason,omitempty"`  // Why the pool dropped the transaction
+	ReplacedBy     *common.Hash    `json:"replacedBy,omitempty"`  // Transaction replacing the dropped one
+	BlockHash      *common.Hash    `json:"blockHash,omitempty"`   // Block including the transaction
+	BlockNumber    *hexutil.Uint64 `json:"blockNumber,omitempty"` // Number of the block including the transaction
+	Confirmations  hexutil.Uint64  `json:"confirmations"`         // Finalized blocks on top of and including the block
+	FinalizedBlock hexutil.Uint64  `json:"finalizedBlock"`        // Number of the last finalized block
+}
+
+// GetTransactionStatus returns the lifecycle status of the given transaction:
+// whether it's pooled or why it was dropped, and once included, the attestation
+// status of its block and its confirmations against the finalized head.
+func (s *PublicTransactionPoolAPI) GetTransactionStatus(ctx context.Context, hash common.Hash) (*TransactionStatus, error) {
+	return transactionStatus(ctx, s.b, hash)
+}
+
+// TransactionStatus notifies the lifecycle status of the given transaction each
+// time it changes, starting with the current one. No notification follows the
+// finalized status.
+func (s *PublicTransactionPoolAPI) TransactionStatus(ctx context.Context, hash common.Hash) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			heads    = make(chan core.ChainHeadEvent, 16)
+			txs      = make(chan core.NewTxsEvent, 128)
+			statuses = make(chan core.NewJustifiedOrFinalizedBlockEvent, 16)
+		)
+		headSub := s.b.SubscribeChainHeadEvent(heads)
+		defer headSub.Unsubscribe()
+		txSub := s.b.SubscribeNewTxsEvent(txs)
+		defer txSub.Unsubscribe()
+		statusSub := s.b.SubscribeBlockPredictStatusEvent(statuses)
+		defer statusSub.Unsubscribe()
+
+		var last *TransactionStatus
+		for {
+			status, err := transactionStatus(context.Background(), s.b, hash)
+			if err == nil && status.changed(last) {
+				if err := notifier.Notify(rpcSub.ID, status); err != nil {
+					return
+				}
+				if status.Status == TxStatusFinalized {
+					return
+				}
+				last = status
+			}
+			select {
+			case <-heads:
+			case <-txs:
+			case <-statuses:
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// changed reports whether the status differs from the last notified one. The
+// finalized head moving on alone doesn't change the status of the transaction.
+func (s *TransactionStatus) changed(last *TransactionStatus) bool {
+	if last == nil {
+		return true
+	}
+	cur, prev := *s, *last
+	cur.FinalizedBlock, prev.FinalizedBlock = 0, 0
+	return !reflect.DeepEqual(cur, prev)
+}
+
+// transactionStatus assembles the lifecycle status of a transaction. The chain
+// is looked up first, the pool records the mined transactions as dropped for
+// their nonce being too low.
+func transactionStatus(ctx context.Context, b Backend, hash common.Hash) (*TransactionStatus, error) {
+	finalized := b.LastFinalizedBlockNumber(ctx)
+	status := &TransactionStatus{
+		Status:         TxStatusUnknown,
+		FinalizedBlock: hexutil.Uint64(finalized),
+	}
+	tx, blockHash, blockNumber, _, err := b.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		status.Status = TxStatusIncluded
+		status.BlockHash = &blockHash
+		status.BlockNumber = (*hexutil.Uint64)(&blockNumber)
+
+		predict, err := b.BlockPredictStatus(ctx, blockHash, rpc.BlockNumber(blockNumber))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case predict == types.BasFinalized || blockNumber <= finalized:
+			status.Status = TxStatusFinalized
+		case predict == types.BasJustified:
+			status.Status = TxStatusJustified
+		}
+		if blockNumber <= finalized {
+			status.Confirmations = hexutil.Uint64(finalized - blockNumber + 1)
+		}
+		return status, nil
+	}
+	pooled, drop := b.GetPoolTransactionStatus(hash)
+	switch {
+	case pooled == core.TxStatusPending:
+		status.Status = TxStatusPending
+	case pooled == core.TxStatusQueued:
+		status.Status = TxStatusQueued
+	case drop != nil:
+		status.Status = TxStatusDropped
+		status.DropReason = string(drop.Reason)
+		if drop.ReplacedBy != (common.Hash{}) {
+			status.ReplacedBy = &drop.ReplacedBy
+		}
+	}
+	return status, nil
+}
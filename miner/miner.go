@@ -52,6 +52,8 @@ type Config struct {
 	GasPrice   *big.Int       // Minimum gas price for mining a transaction
 	Recommit   time.Duration  // The time interval for miner to re-create mining work.
 	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
+
+	PriorityGas uint64 // Percentage of block gas reserved for the priority lane (only useful in chaos).
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -135,6 +135,7 @@ type worker struct {
 
 	chaosEngine   consensus.ChaosEngine
 	isChaosEngine bool
+	carryOvers    map[common.Address]struct{} // Accounts with transactions exceeding the shaped gas budget, packed first in the next full-budget block
 
 	// Feeds
 	pendingLogsFeed event.Feed
@@ -205,6 +206,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		engine:             engine,
 		isChaosEngine:      isChaosEngine,
 		chaosEngine:        chaosEngine,
+		carryOvers:         make(map[common.Address]struct{}),
 		eth:                eth,
 		mux:                mux,
 		chain:              eth.BlockChain(),
@@ -874,6 +876,13 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			log.Trace("Gas limit exceeded for current block", "sender", from)
 			txs.Pop()
 
+			// The transaction can't fit into a block with halved gas budget, carry it over
+			// to the next full-budget block instead of leaving it to the gas price order.
+			if w.isChaosEngine && tx.Gas() <= gasLimit && tx.Gas() > w.chaosEngine.CalculateGasPool(w.current.header) {
+				log.Trace("Carry over transaction exceeding gas budget", "sender", from, "hash", tx.Hash(), "gas", tx.Gas())
+				w.carryOvers[from] = struct{}{}
+			}
+
 		case errors.Is(err, core.ErrNonceTooLow):
 			// New head notification data race between the transaction pool and miner, shift
 			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
@@ -1031,6 +1040,15 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.updateSnapshot()
 		return
 	}
+	// Validator operations in the priority lane go first, within the reserved gas
+	if w.isChaosEngine && w.config.PriorityGas > 0 {
+		if priorityTxs := w.priorityTxs(pending); len(priorityTxs) > 0 {
+			txs := types.NewTransactionsByPriceAndNonce(w.current.signer, priorityTxs, header.BaseFee)
+			if w.commitPriorityTransactions(txs, interrupt) {
+				return
+			}
+		}
+	}
 	// Split the pending transactions into locals and remotes
 	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
 	for _, account := range w.eth.TxPool().Locals() {
@@ -1039,6 +1057,26 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 			localTxs[account] = txs
 		}
 	}
+	// Accounts carried over from the blocks with halved gas budget go first
+	if w.isChaosEngine && len(w.carryOvers) > 0 && w.chaosEngine.CalculateGasPool(header) == header.GasLimit {
+		carryTxs := make(map[common.Address]types.Transactions)
+		for account := range w.carryOvers {
+			if txs := localTxs[account]; len(txs) > 0 {
+				delete(localTxs, account)
+				carryTxs[account] = txs
+			} else if txs := remoteTxs[account]; len(txs) > 0 {
+				delete(remoteTxs, account)
+				carryTxs[account] = txs
+			}
+		}
+		w.carryOvers = make(map[common.Address]struct{})
+		if len(carryTxs) > 0 {
+			txs := types.NewTransactionsByPriceAndNonce(w.current.signer, carryTxs, header.BaseFee)
+			if w.commitTransactions(txs, w.coinbase, interrupt) {
+				return
+			}
+		}
+	}
 	if len(localTxs) > 0 {
 		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs, header.BaseFee)
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
@@ -1054,6 +1092,42 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	w.commit(uncles, w.fullTaskHook, true, tstart)
 }
 
+// priorityTxs returns the leading priority lane transactions of each account.
+// They're kept in the pending transactions too, if any of them doesn't fit in the
+// reserved gas it can be retried with the others, and the committed ones will be
+// skipped as nonce too low.
+func (w *worker) priorityTxs(pending map[common.Address]types.Transactions) map[common.Address]types.Transactions {
+	priorityTxs := make(map[common.Address]types.Transactions)
+	for account, txs := range pending {
+		n := 0
+		for n < len(txs) && w.chaosEngine.IsPriorityTx(account, txs[n], w.current.header) {
+			n++
+		}
+		if n > 0 {
+			priorityTxs[account] = txs[:n]
+		}
+	}
+	return priorityTxs
+}
+
+// commitPriorityTransactions commits the priority lane transactions, which can
+// use no more than the reserved percentage of the block gas.
+func (w *worker) commitPriorityTransactions(txs *types.TransactionsByPriceAndNonce, interrupt *int32) bool {
+	if w.current.gasPool == nil {
+		w.current.gasPool = new(core.GasPool).AddGas(w.chaosEngine.CalculateGasPool(w.current.header))
+	}
+	// Hold the unreserved gas back until the priority lane is done
+	var (
+		gas      = w.current.gasPool.Gas()
+		reserved = gas / 100 * w.config.PriorityGas
+		held     = gas - reserved
+	)
+	w.current.gasPool.SubGas(held)
+	defer w.current.gasPool.AddGas(held)
+
+	return w.commitTransactions(txs, w.coinbase, interrupt)
+}
+
 // commit runs any post-transaction state modifications, assembles the final block
 // and commits new work if consensus engine is running.
 func (w *worker) commit(uncles []*types.Header, interval func(), update bool, start time.Time) error {
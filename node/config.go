@@ -65,6 +65,11 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the key-value storage engine of the node databases, "leveldb"
+	// or "pebble". If empty, the engine of the pre-existing database is used,
+	// leveldb for a new one.
+	DBEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -190,6 +195,10 @@ type Config struct {
 
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
+
+	// RPCRateLimits throttles the calls of the HTTP and WebSocket clients, per
+	// method or namespace. The IPC and in-process clients are never limited.
+	RPCRateLimits *rpc.RateLimits `toml:",omitempty"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -19,6 +19,7 @@ package node
 import (
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -39,14 +40,16 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
+	RateLimits         *rpc.RateLimits
 	prefix             string // path prefix on which to mount http handler
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins []string
-	Modules []string
-	prefix  string // path prefix on which to mount ws handler
+	Origins    []string
+	Modules    []string
+	RateLimits *rpc.RateLimits
+	prefix     string // path prefix on which to mount ws handler
 }
 
 type rpcHandler struct {
@@ -280,6 +283,7 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetRateLimits(config.RateLimits)
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
@@ -312,6 +316,7 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetRateLimits(config.RateLimits)
 	if err := RegisterApis(apis, config.Modules, srv, false); err != nil {
 		return err
 	}
@@ -361,6 +366,79 @@ func isWebsocket(r *http.Request) bool {
 		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
 }
 
+// HealthCheck reports the status of a node component for the health endpoints,
+// returning an error if the component is failing.
+type HealthCheck func() (status interface{}, err error)
+
+type healthCheck struct {
+	name  string
+	ready bool // Whether the check fails the readiness only
+	check HealthCheck
+}
+
+// healthChecks is the set of the checks served by the /health and /ready
+// endpoints. The node is healthy if none of the liveness checks fails, and
+// ready if none of the checks fails.
+type healthChecks struct {
+	lock   sync.RWMutex
+	checks []healthCheck
+}
+
+// healthResult is the response of the health endpoints.
+type healthResult struct {
+	Healthy bool                          `json:"healthy"`
+	Ready   bool                          `json:"ready"`
+	Checks  map[string]*healthCheckResult `json:"checks"`
+}
+
+type healthCheckResult struct {
+	Status interface{} `json:"status,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func (hc *healthChecks) add(name string, ready bool, check HealthCheck) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+
+	hc.checks = append(hc.checks, healthCheck{name: name, ready: ready, check: check})
+}
+
+// run runs all the checks.
+func (hc *healthChecks) run() *healthResult {
+	hc.lock.RLock()
+	defer hc.lock.RUnlock()
+
+	res := &healthResult{Healthy: true, Ready: true, Checks: make(map[string]*healthCheckResult, len(hc.checks))}
+	for _, c := range hc.checks {
+		status, err := c.check()
+		result := &healthCheckResult{Status: status}
+		if err != nil {
+			result.Error = err.Error()
+			if !c.ready {
+				res.Healthy = false
+			}
+			res.Ready = false
+		}
+		res.Checks[c.name] = result
+	}
+	return res
+}
+
+// handler returns the handler of the /health endpoint, or of the /ready one.
+// It responds with the status of all the checks, and the 503 status code if
+// the node is unhealthy, or not ready.
+func (hc *healthChecks) handler(ready bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := hc.run()
+
+		w.Header().Set("content-type", "application/json")
+		if !res.Healthy || (ready && !res.Ready) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
 // NewHTTPHandlerStack returns wrapped http-related handlers
 func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string) http.Handler {
 	// Wrap the CORS-handler within a host-handler
@@ -18,8 +18,11 @@ package node
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strconv"
 	"strings"
@@ -229,6 +232,51 @@ func Test_checkPath(t *testing.T) {
 	}
 }
 
+// TestHealthHandler makes sure the failing checks fail the health and readiness
+// endpoints.
+func TestHealthHandler(t *testing.T) {
+	var (
+		checks      = new(healthChecks)
+		liveErr     error
+		readyErr    error
+		serveHealth = func(ready bool) (int, *healthResult) {
+			rec := httptest.NewRecorder()
+			checks.handler(ready).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+			res := new(healthResult)
+			if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+				t.Fatalf("failed to decode response %q: %v", rec.Body, err)
+			}
+			return rec.Code, res
+		}
+	)
+	checks.add("live", false, func() (interface{}, error) { return 1, liveErr })
+	checks.add("ready", true, func() (interface{}, error) { return nil, readyErr })
+
+	tests := []struct {
+		liveErr, readyErr     error
+		healthCode, readyCode int
+	}{
+		{nil, nil, http.StatusOK, http.StatusOK},
+		{nil, errors.New("syncing"), http.StatusOK, http.StatusServiceUnavailable},
+		{errors.New("stalled"), nil, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+	}
+	for i, tt := range tests {
+		liveErr, readyErr = tt.liveErr, tt.readyErr
+
+		code, res := serveHealth(false)
+		assert.Equal(t, tt.healthCode, code, "test %d: health status code", i)
+		assert.Equal(t, tt.liveErr == nil, res.Healthy, "test %d: healthy", i)
+		assert.Equal(t, tt.liveErr == nil && tt.readyErr == nil, res.Ready, "test %d: ready", i)
+		assert.Equal(t, float64(1), res.Checks["live"].Status, "test %d: live status", i)
+		if tt.readyErr != nil {
+			assert.Equal(t, tt.readyErr.Error(), res.Checks["ready"].Error, "test %d: ready error", i)
+		}
+		code, _ = serveHealth(true)
+		assert.Equal(t, tt.readyCode, code, "test %d: readiness status code", i)
+	}
+}
+
 func createAndStartServer(t *testing.T, conf *httpConfig, ws bool, wsConf *wsConfig) *httpServer {
 	t.Helper()
 
@@ -253,6 +253,14 @@ type ChaosConfig struct {
 	// That is: only attest to a block which height is ≤ `currentHead - AttestationDelay`
 	AttestationDelay uint64 `json:"attestationDelay"`
 
+	// Out-of-turn sealing delays. The backup validators of a block seal one after
+	// the other in a deterministic order, spaced by a step of LatencyFactor times
+	// the observed block propagation latency, bounded by BackupStepMin and
+	// BackupStepMax. Zero values are for the defaults.
+	BackupStepMin uint64 `json:"backupStepMin,omitempty"` // Minimum delay in milliseconds between two backup validators
+	BackupStepMax uint64 `json:"backupStepMax,omitempty"` // Maximum delay in milliseconds between two backup validators
+	LatencyFactor uint64 `json:"latencyFactor,omitempty"` // Multiple of the propagation latency between two backup validators
+
 	Rule                  uint64         `json:"rule"`                  // Version of Chaos, which differ in behavious, 0 is the lastest default one
 	EnableDevVerification bool           `json:"enableDevVerification"` // Enable developer address verification
 	AdminDevnet           common.Address `json:"adminDevnet,omitempty"` // admin address in system contracts of GravitationHardFork for a private chain, ONLY used by develop or private chain.
@@ -32,6 +32,9 @@ const (
 	// considered probably final and its rotated bits are calculated.
 	BloomConfirms = 256
 
+	// LogIndexBlocks is the number of blocks a single log index section contains.
+	LogIndexBlocks uint64 = 1024
+
 	// CHTFrequency is the block frequency for creating CHTs
 	CHTFrequency = 32768
 
@@ -59,6 +62,12 @@ const (
 	// the freezer as the cutoff threshold and by clique as the snapshot trust limit.
 	FullImmutabilityThreshold = 90000
 
+	// FinalityImmutabilityMargin is the number of blocks below the last finalized
+	// block from which a chain segment is considered immutable on chains with Casper
+	// FFG finality. It's used instead of FullImmutabilityThreshold by the freezer,
+	// the downloader and the state pruner, whenever it's the lower boundary.
+	FinalityImmutabilityMargin = 64
+
 	// LightImmutabilityThreshold is the number of blocks after which a header chain
 	// segment is considered immutable for light client(i.e. soft finality). It is used by
 	// the downloader as a hard limit against deep ancestors, by the blockchain against deep
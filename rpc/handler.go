@@ -61,6 +61,8 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	limiter        *rateLimiter // rate limits of the server, nil if unlimited
+	client         string       // identity of the remote end for the rate limits
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -315,8 +317,30 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	}
 }
 
-// handleCall processes method calls.
+// setLimiter applies the rate limits of the server to the calls of the handler.
+func (h *handler) setLimiter(limiter *rateLimiter) {
+	if limiter == nil {
+		return
+	}
+	h.limiter = limiter
+	h.client = limiter.client(h.conn)
+}
+
+// handleCall processes method calls, within the rate limits of the server.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if h.limiter == nil || msg.isUnsubscribe() {
+		return h.serveCall(cp, msg)
+	}
+	limit, err := h.limiter.admit(h.client, msg.Method)
+	if err != nil {
+		return msg.errorResponse(err)
+	}
+	defer limit.done()
+	return limit.checkResponse(msg, h.serveCall(cp, msg))
+}
+
+// serveCall executes method calls.
+func (h *handler) serveCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
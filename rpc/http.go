@@ -222,6 +222,11 @@ func (t *httpServerConn) RemoteAddr() string {
 	return t.r.RemoteAddr
 }
 
+// connInfo returns the information on the client sending the request.
+func (t *httpServerConn) connInfo() connInfo {
+	return newConnInfo(t.r)
+}
+
 // SetWriteDeadline does nothing and always returns nil.
 func (t *httpServerConn) SetWriteDeadline(time.Time) error { return nil }
 
@@ -202,6 +202,15 @@ func (c *jsonCodec) remoteAddr() string {
 	return c.remote
 }
 
+// connInfo returns the information on the remote end of the connection, as
+// far as the transport knows it.
+func (c *jsonCodec) connInfo() connInfo {
+	if conn, ok := c.conn.(interface{ connInfo() connInfo }); ok {
+		return conn.connInfo()
+	}
+	return connInfo{remote: c.remote}
+}
+
 func (c *jsonCodec) readBatch() (messages []*jsonrpcMessage, batch bool, err error) {
 	// Decode the next JSON object in the input stream.
 	// This verifies basic syntax, etc.
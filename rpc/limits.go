@@ -0,0 +1,280 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRateLimit is the name of the limit applying to the methods without
+	// a limit of their own, nor of their namespace.
+	DefaultRateLimit = "*"
+
+	// APIKeyHeader is the HTTP header carrying the key of a client.
+	APIKeyHeader = "X-API-Key"
+
+	limitIdleTimeout = time.Minute // Time after which the usage of an idle client is dropped
+)
+
+// RateLimit is a limit of the calls of a method, applied to every client
+// separately. The zero values disable the limits.
+type RateLimit struct {
+	Rate        float64 // Calls allowed per second
+	Burst       int     // Calls allowed at once above the rate, the rate rounded up by default
+	Concurrency int     // Calls running at the same time
+	MaxResponse int     // Size of a response, in bytes
+}
+
+// RateLimits configures the throttling of the calls of a server. The limit of a
+// call is looked up by the method name first ("eth_getLogs"), then by the
+// namespace ("debug"), falling back to the default limit ("*").
+//
+// The clients are told apart by IP address, or by API key if they send one of
+// the configured keys in the X-API-Key header.
+type RateLimits struct {
+	Limits     map[string]RateLimit
+	APIKeys    []string `toml:",omitempty"`
+	TrustProxy bool     // Whether to take the client address from the X-Forwarded-For header
+}
+
+// ParseRateLimits parses a comma separated list of limits, each of the form
+// name=rate[:burst[:concurrency[:maxresponse]]], an empty field disabling
+// the limit. E.g. "eth_getLogs=5:10:2,debug=1::1,*=100".
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, want name=rate[:burst[:concurrency[:maxresponse]]]", entry)
+		}
+		fields := strings.Split(kv[1], ":")
+		if len(fields) > 4 {
+			return nil, fmt.Errorf("invalid rate limit %q, too many fields", entry)
+		}
+		var (
+			limit RateLimit
+			ints  = []*int{&limit.Burst, &limit.Concurrency, &limit.MaxResponse}
+		)
+		for i, field := range fields {
+			if field == "" {
+				continue
+			}
+			if i == 0 {
+				r, err := strconv.ParseFloat(field, 64)
+				if err != nil || r < 0 {
+					return nil, fmt.Errorf("invalid rate of limit %q", entry)
+				}
+				limit.Rate = r
+				continue
+			}
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid field %d of limit %q", i+1, entry)
+			}
+			*ints[i-1] = n
+		}
+		limits[kv[0]] = limit
+	}
+	return limits, nil
+}
+
+// limitExceededError is returned when a call exceeds a limit of the server.
+type limitExceededError struct{ message string }
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
+
+// connInfo is the information on the remote end of a connection that clients
+// are told apart by.
+type connInfo struct {
+	remote       string
+	forwardedFor string
+	apiKey       string
+}
+
+func newConnInfo(r *http.Request) connInfo {
+	return connInfo{
+		remote:       r.RemoteAddr,
+		forwardedFor: r.Header.Get("X-Forwarded-For"),
+		apiKey:       r.Header.Get(APIKeyHeader),
+	}
+}
+
+// clientLimit is the usage of a limit by a client.
+type clientLimit struct {
+	rate    *rate.Limiter
+	running int
+	used    time.Time
+}
+
+type clientRule struct {
+	client string
+	rule   string
+}
+
+// rateLimiter enforces the rate limits of a server.
+type rateLimiter struct {
+	limits     map[string]RateLimit
+	apiKeys    map[string]bool
+	trustProxy bool
+
+	lock    sync.Mutex
+	clients map[clientRule]*clientLimit
+	swept   time.Time
+}
+
+func newRateLimiter(limits *RateLimits) *rateLimiter {
+	l := &rateLimiter{
+		limits:     make(map[string]RateLimit, len(limits.Limits)),
+		apiKeys:    make(map[string]bool, len(limits.APIKeys)),
+		trustProxy: limits.TrustProxy,
+		clients:    make(map[clientRule]*clientLimit),
+		swept:      time.Now(),
+	}
+	for name, limit := range limits.Limits {
+		l.limits[name] = limit
+	}
+	for _, key := range limits.APIKeys {
+		l.apiKeys[key] = true
+	}
+	return l
+}
+
+// client returns the identity of the client on the remote end of a connection.
+func (l *rateLimiter) client(conn jsonWriter) string {
+	info := connInfo{remote: conn.remoteAddr()}
+	if c, ok := conn.(interface{ connInfo() connInfo }); ok {
+		info = c.connInfo()
+	}
+	if info.apiKey != "" && l.apiKeys[info.apiKey] {
+		return "key:" + info.apiKey
+	}
+	addr := info.remote
+	if l.trustProxy && info.forwardedFor != "" {
+		// The first address is the one of the client, the others of the proxies
+		addr = strings.TrimSpace(strings.Split(info.forwardedFor, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return addr
+}
+
+// rule returns the name and the limit applying to a method.
+func (l *rateLimiter) rule(method string) (string, RateLimit, bool) {
+	if limit, ok := l.limits[method]; ok {
+		return method, limit, true
+	}
+	namespace := strings.SplitN(method, serviceMethodSeparator, 2)[0]
+	if limit, ok := l.limits[namespace]; ok {
+		return namespace, limit, true
+	}
+	limit, ok := l.limits[DefaultRateLimit]
+	return DefaultRateLimit, limit, ok
+}
+
+// callLimit is the limit applied to a running call.
+type callLimit struct {
+	limiter *rateLimiter
+	rule    string
+	usage   *clientLimit
+	limit   RateLimit
+}
+
+// admit checks a call of a client against its limit, returning the limit to
+// release once the call is done. It returns nil if no limit applies.
+func (l *rateLimiter) admit(client string, method string) (*callLimit, error) {
+	rule, limit, ok := l.rule(method)
+	if !ok {
+		return nil, nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) > limitIdleTimeout {
+		l.sweep(now)
+	}
+	key := clientRule{client, rule}
+	usage := l.clients[key]
+	if usage == nil {
+		usage = new(clientLimit)
+		if limit.Rate > 0 {
+			burst := limit.Burst
+			if burst == 0 {
+				burst = int(limit.Rate + 0.999)
+			}
+			usage.rate = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+		}
+		l.clients[key] = usage
+	}
+	usage.used = now
+
+	if usage.rate != nil && !usage.rate.AllowN(now, 1) {
+		markLimited(rule, "rate")
+		return nil, &limitExceededError{fmt.Sprintf("rate limit exceeded for %s", method)}
+	}
+	if limit.Concurrency > 0 && usage.running >= limit.Concurrency {
+		markLimited(rule, "concurrency")
+		return nil, &limitExceededError{fmt.Sprintf("too many concurrent calls of %s", method)}
+	}
+	usage.running++
+	return &callLimit{limiter: l, rule: rule, usage: usage, limit: limit}, nil
+}
+
+// sweep drops the usage of the clients idle for a while.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, usage := range l.clients {
+		if usage.running == 0 && now.Sub(usage.used) > limitIdleTimeout {
+			delete(l.clients, key)
+		}
+	}
+	l.swept = now
+}
+
+// done releases the limit of a finished call.
+func (c *callLimit) done() {
+	if c == nil {
+		return
+	}
+	c.limiter.lock.Lock()
+	c.usage.running--
+	c.limiter.lock.Unlock()
+}
+
+// checkResponse replaces a response exceeding the maximum size by an error.
+func (c *callLimit) checkResponse(msg *jsonrpcMessage, resp *jsonrpcMessage) *jsonrpcMessage {
+	if c == nil || c.limit.MaxResponse == 0 || resp == nil || len(resp.Result) <= c.limit.MaxResponse {
+		return resp
+	}
+	markLimited(c.rule, "size")
+	return msg.errorResponse(&limitExceededError{fmt.Sprintf("response of %s too large: %d bytes, limit %d", msg.Method, len(resp.Result), c.limit.MaxResponse)})
+}
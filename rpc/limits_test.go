@@ -0,0 +1,179 @@
+// Copyright 2021 The Cube Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+package rpc
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits("eth_getLogs=5:10:2, debug=1::1,*=100,test_x=::0:1024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]RateLimit{
+		"eth_getLogs": {Rate: 5, Burst: 10, Concurrency: 2},
+		"debug":       {Rate: 1, Concurrency: 1},
+		"*":           {Rate: 100},
+		"test_x":      {MaxResponse: 1024},
+	}
+	if !reflect.DeepEqual(limits, want) {
+		t.Fatalf("wrong limits: got %v, want %v", limits, want)
+	}
+	for _, spec := range []string{"eth_call", "=1", "a=x", "a=1:-1", "a=1:2:3:4:5"} {
+		if _, err := ParseRateLimits(spec); err == nil {
+			t.Errorf("expected error parsing %q", spec)
+		}
+	}
+}
+
+func newLimitedHTTPServer(t *testing.T, limits *RateLimits) (*Server, *httptest.Server) {
+	s := newTestServer()
+	s.RegisterName("large", largeRespService{1000})
+	s.SetRateLimits(limits)
+	return s, httptest.NewServer(s)
+}
+
+func checkLimitExceeded(t *testing.T, err error) {
+	t.Helper()
+	if err == nil {
+		t.Fatal("expected the call to be limited")
+	}
+	if e, ok := err.(Error); !ok || e.ErrorCode() != -32005 {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s, ts := newLimitedHTTPServer(t, &RateLimits{
+		Limits:  map[string]RateLimit{"test_echo": {Rate: 0.001, Burst: 2}, "test": {Rate: 0.001, Burst: 1}},
+		APIKeys: []string{"secret"},
+	})
+	defer s.Stop()
+	defer ts.Close()
+
+	c, err := DialHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var res echoResult
+	for i := 0; i < 2; i++ {
+		if err := c.Call(&res, "test_echo", "x", 1); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	checkLimitExceeded(t, c.Call(&res, "test_echo", "x", 1))
+
+	// The namespace limit applies to the other methods, separately
+	var r string
+	if err := c.Call(&r, "test_rets"); err != nil {
+		t.Fatal(err)
+	}
+	checkLimitExceeded(t, c.Call(&r, "test_rets"))
+
+	// Methods without a limit are not limited
+	for i := 0; i < 5; i++ {
+		if err := c.Call(&r, "large_largeResp"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Known API keys have limits of their own, unknown ones are ignored
+	c.SetHeader(APIKeyHeader, "unknown")
+	checkLimitExceeded(t, c.Call(&res, "test_echo", "x", 1))
+	c.SetHeader(APIKeyHeader, "secret")
+	if err := c.Call(&res, "test_echo", "x", 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResponseSizeLimit(t *testing.T) {
+	s, ts := newLimitedHTTPServer(t, &RateLimits{
+		Limits: map[string]RateLimit{"*": {MaxResponse: 100}},
+	})
+	defer s.Stop()
+	defer ts.Close()
+
+	c, err := DialHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var r string
+	checkLimitExceeded(t, c.Call(&r, "large_largeResp"))
+	if err := c.Call(&r, "test_rets"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	l := newRateLimiter(&RateLimits{
+		Limits: map[string]RateLimit{"debug": {Concurrency: 2}},
+	})
+	first, err := l.admit("a", "debug_traceBlock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.admit("a", "debug_traceTransaction"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.admit("a", "debug_traceCall"); err == nil {
+		t.Fatal("expected the third call to be limited")
+	}
+	// Other clients and unlimited methods are not affected
+	if _, err := l.admit("b", "debug_traceCall"); err != nil {
+		t.Fatal(err)
+	}
+	if limit, err := l.admit("a", "eth_call"); err != nil || limit != nil {
+		t.Fatalf("unexpected limit of eth_call: %v, %v", limit, err)
+	}
+	first.done()
+	if _, err := l.admit("a", "debug_traceCall"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimitClient(t *testing.T) {
+	l := newRateLimiter(&RateLimits{Limits: map[string]RateLimit{"*": {Rate: 1}}, APIKeys: []string{"k"}})
+	tests := []struct {
+		info       connInfo
+		trustProxy bool
+		want       string
+	}{
+		{connInfo{remote: "10.0.0.1:1234"}, false, "10.0.0.1"},
+		{connInfo{remote: "10.0.0.1:1234", forwardedFor: "1.2.3.4, 10.0.0.2"}, false, "10.0.0.1"},
+		{connInfo{remote: "10.0.0.1:1234", forwardedFor: "1.2.3.4, 10.0.0.2"}, true, "1.2.3.4"},
+		{connInfo{remote: "10.0.0.1:1234", apiKey: "k"}, false, "key:k"},
+		{connInfo{remote: "10.0.0.1:1234", apiKey: "x"}, false, "10.0.0.1"},
+	}
+	for i, test := range tests {
+		l.trustProxy = test.trustProxy
+		if got := l.client(testConnInfo{new(jsonCodec), test.info}); got != test.want {
+			t.Errorf("test %d: wrong client %q, want %q", i, got, test.want)
+		}
+	}
+}
+
+type testConnInfo struct {
+	*jsonCodec
+	info connInfo
+}
+
+func (c testConnInfo) connInfo() connInfo { return c.info }
@@ -27,6 +27,10 @@ var (
 	successfulRequestGauge = metrics.NewRegisteredGauge("rpc/success", nil)
 	failedReqeustGauge     = metrics.NewRegisteredGauge("rpc/failure", nil)
 	rpcServingTimer        = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+
+	rateLimitedMeter        = metrics.NewRegisteredMeter("rpc/limited/rate", nil)
+	concurrencyLimitedMeter = metrics.NewRegisteredMeter("rpc/limited/concurrency", nil)
+	sizeLimitedMeter        = metrics.NewRegisteredMeter("rpc/limited/size", nil)
 )
 
 func newRPCServingTimer(method string, valid bool) metrics.Timer {
@@ -37,3 +41,20 @@ func newRPCServingTimer(method string, valid bool) metrics.Timer {
 	m := fmt.Sprintf("rpc/duration/%s/%s", method, flag)
 	return metrics.GetOrRegisterTimer(m, nil)
 }
+
+// markLimited records a call rejected by the rate limit rule for the given
+// reason. The rules are configured, which keeps the number of meters bounded.
+func markLimited(rule string, reason string) {
+	switch reason {
+	case "rate":
+		rateLimitedMeter.Mark(1)
+	case "concurrency":
+		concurrencyLimitedMeter.Mark(1)
+	case "size":
+		sizeLimitedMeter.Mark(1)
+	}
+	if rule == DefaultRateLimit {
+		rule = "default"
+	}
+	metrics.GetOrRegisterMeter(fmt.Sprintf("rpc/limited/%s/%s", rule, reason), nil).Mark(1)
+}
@@ -46,6 +46,7 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	limiter  *rateLimiter
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -58,6 +59,16 @@ func NewServer() *Server {
 	return server
 }
 
+// SetRateLimits sets the limits of the calls served to every client. It must be
+// called before the server starts serving.
+func (s *Server) SetRateLimits(limits *RateLimits) {
+	if limits == nil || len(limits.Limits) == 0 {
+		s.limiter = nil
+		return
+	}
+	s.limiter = newRateLimiter(limits)
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -83,7 +94,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.limiter)
 	<-codec.closed()
 	c.Close()
 }
@@ -99,6 +110,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services)
 	h.allowSubscribe = false
+	h.setLimiter(s.limiter)
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
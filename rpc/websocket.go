@@ -61,6 +61,7 @@ func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 			return
 		}
 		codec := newWebsocketCodec(conn)
+		codec.(*websocketCodec).info = newConnInfo(r)
 		s.ServeCodec(codec, 0)
 	})
 }
@@ -238,6 +239,7 @@ type websocketCodec struct {
 
 	wg        sync.WaitGroup
 	pingReset chan struct{}
+	info      connInfo // remote end as of the upgrade request, served connections only
 }
 
 func newWebsocketCodec(conn *websocket.Conn) ServerCodec {
@@ -256,6 +258,13 @@ func newWebsocketCodec(conn *websocket.Conn) ServerCodec {
 	return wc
 }
 
+func (wc *websocketCodec) connInfo() connInfo {
+	if wc.info.remote == "" {
+		return wc.jsonCodec.connInfo()
+	}
+	return wc.info
+}
+
 func (wc *websocketCodec) close() {
 	wc.jsonCodec.close()
 	wc.wg.Wait()